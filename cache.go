@@ -2,6 +2,13 @@ package ski
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -22,6 +29,12 @@ func WithCacheTimeout(ctx context.Context, timeout time.Duration) context.Contex
 	return WithValue(ctx, &cacheTimeoutKey, timeout)
 }
 
+// withCacheTimeout returns a child context with the cache timeout, unlike the WithCacheTimeout
+// the timeout is not set to the shared Context, the later cache writes are not affected.
+func withCacheTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, &cacheTimeoutKey, timeout)
+}
+
 // CacheTimeout returns the context cache timeout values.
 func CacheTimeout(ctx context.Context) time.Duration {
 	return cast.ToDuration(ctx.Value(&cacheTimeoutKey))
@@ -78,3 +91,75 @@ func NewCache() Cache {
 		timeout: make(map[string]int64),
 	}
 }
+
+// fileCache is an implementation of Cache that stores bytes in the directory,
+// each key is stored in a file named by the hash of the key.
+type fileCache struct{ dir string }
+
+// NewFileCache returns a new Cache that will store items in the directory,
+// the directory will be created if it does not exist.
+func NewFileCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileCache{dir}, nil
+}
+
+func (c *fileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the []byte, if not existing returns nil.
+func (c *fileCache) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, nil
+	}
+	// the first 8 bytes is the deadline unix seconds, zero means never expire.
+	if ddl := int64(binary.BigEndian.Uint64(data)); ddl > 0 && time.Now().Unix() > ddl {
+		_ = os.Remove(c.path(key))
+		return nil, nil
+	}
+	return data[8:], nil
+}
+
+// Set saves []byte to the cache with key
+func (c *fileCache) Set(ctx context.Context, key string, value []byte) error {
+	data := make([]byte, 8, len(value)+8)
+	if timeout := CacheTimeout(ctx); timeout > 0 {
+		binary.BigEndian.PutUint64(data, uint64(time.Now().Add(timeout).Unix()))
+	}
+	data = append(data, value...)
+
+	// write to the temporary file then rename for atomic replacement
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// Del removes key from the cache
+func (c *fileCache) Del(_ context.Context, key string) error {
+	err := os.Remove(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
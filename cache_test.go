@@ -35,3 +35,35 @@ func TestCache(t *testing.T) {
 	v, _ = c.Get(ctx, key)
 	assert.Empty(t, v, "not expired: %v", key)
 }
+
+func TestFileCache(t *testing.T) {
+	t.Parallel()
+	c, err := NewFileCache(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx := context.Background()
+
+	key, value := "testCacheKey", "testCacheValue"
+	v, err := c.Get(ctx, key)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	assert.NoError(t, c.Set(ctx, key, []byte(value)))
+	v, _ = c.Get(ctx, key)
+	assert.Equal(t, value, string(v))
+
+	assert.NoError(t, c.Del(ctx, key))
+	v, _ = c.Get(ctx, key)
+	assert.Empty(t, v)
+	assert.NoError(t, c.Del(ctx, key))
+
+	assert.NoError(t, c.Set(WithCacheTimeout(ctx, time.Second), key, []byte(value)))
+	v, _ = c.Get(ctx, key)
+	assert.Equal(t, value, string(v))
+
+	time.Sleep(2 * time.Second)
+
+	v, _ = c.Get(ctx, key)
+	assert.Nil(t, v, "not expired: %v", key)
+}
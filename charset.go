@@ -0,0 +1,96 @@
+package ski
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var requestCharsetKey byte
+
+// WithCharset returns a copy of parent context in which the charset label associated with context,
+// which overrides the detected charset of the response body.
+// The label is not set to the shared Context, only the requests of the returned context are affected.
+func WithCharset(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, &requestCharsetKey, label)
+}
+
+// CharsetFromContext returns the charset label on context.
+func CharsetFromContext(ctx context.Context) string {
+	if label, ok := ctx.Value(&requestCharsetKey).(string); ok {
+		return label
+	}
+	return ""
+}
+
+// DecodeCharset transcodes the content to UTF-8. If the label is empty, the charset
+// is detected from the BOM, the charset of Content-Type and the HTML <meta charset>,
+// the content without charset declaration is treated as UTF-8 if it is valid.
+// The label is one of the encodings of https://encoding.spec.whatwg.org, such as
+// gbk, shift_jis, windows-1251.
+func DecodeCharset(content []byte, contentType, label string) ([]byte, error) {
+	var (
+		e    encoding.Encoding
+		name string
+	)
+	if label != "" {
+		if e, name = charset.Lookup(label); e == nil {
+			return nil, fmt.Errorf("unsupported charset %q", label)
+		}
+	} else {
+		var certain bool
+		e, name, certain = charset.DetermineEncoding(content, contentType)
+		if !certain && name == "windows-1252" && utf8.Valid(content) {
+			// the windows-1252 is the fallback if the charset not declared,
+			// but only the first 1024 bytes are examined.
+			name = "utf-8"
+		}
+	}
+
+	if name != "utf-8" {
+		var err error
+		if content, err = e.NewDecoder().Bytes(content); err != nil {
+			return nil, err
+		}
+	}
+	// the BOM is decoded to the UTF-8 BOM
+	return bytes.TrimPrefix(content, utf8BOM), nil
+}
+
+// textMediaTypes the textual media types except the text/*, +json and +xml.
+var textMediaTypes = map[string]bool{
+	"application/json":                  true,
+	"application/xml":                   true,
+	"application/javascript":            true,
+	"application/x-javascript":          true,
+	"application/ecmascript":            true,
+	"application/x-ndjson":              true,
+	"application/yaml":                  true,
+	"application/x-yaml":                true,
+	"application/x-www-form-urlencoded": true,
+}
+
+// IsTextContent reports whether the content is textual by the media type of the Content-Type,
+// such as text/*, JSON, XML and JavaScript. The content is sniffed if the Content-Type is empty.
+// Only the textual content should be transcoded by DecodeCharset.
+func IsTextContent(content []byte, contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		contentType = http.DetectContentType(content)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(strings.ToLower(contentType), ";")
+		mediaType = strings.TrimSpace(mediaType)
+	}
+	return strings.HasPrefix(mediaType, "text/") || textMediaTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
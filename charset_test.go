@@ -0,0 +1,79 @@
+package ski
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	t.Parallel()
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("<p>你好，世界</p>")
+	sjis, _ := japanese.ShiftJIS.NewEncoder().String("こんにちは")
+	cp1251, _ := charmap.Windows1251.NewEncoder().String("Привет")
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("hello")
+	long := strings.Repeat("a", 2048) + "é"
+
+	testCases := []struct {
+		content, contentType, label, want string
+	}{
+		{gbk, "text/html; charset=GBK", "", "<p>你好，世界</p>"},
+		{`<html><head><meta charset="shift_jis"></head><body>` + sjis, "text/html", "", `<html><head><meta charset="shift_jis"></head><body>こんにちは`},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=gb2312">` + gbk, "", "", `<meta http-equiv="Content-Type" content="text/html; charset=gb2312"><p>你好，世界</p>`},
+		{cp1251, "text/html; charset=utf-8", "windows-1251", "Привет"},
+		{"\xEF\xBB\xBFhello", "text/plain; charset=iso-8859-1", "", "hello"},
+		{utf16, "", "", "hello"},
+		{long, "", "", long},
+		{"caf\xe9", "", "", "café"},
+	}
+	for _, c := range testCases {
+		got, err := DecodeCharset([]byte(c.content), c.contentType, c.label)
+		if assert.NoError(t, err) {
+			assert.Equal(t, c.want, string(got))
+		}
+	}
+
+	_, err := DecodeCharset([]byte("hello"), "", "unknown")
+	assert.Error(t, err)
+}
+
+func TestIsTextContent(t *testing.T) {
+	t.Parallel()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	testCases := []struct {
+		content, contentType string
+		want                 bool
+	}{
+		{"", "text/html; charset=gbk", true},
+		{"", "Text/Plain", true},
+		{"", "application/json", true},
+		{"", "application/ld+json; charset=utf-8", true},
+		{"", "application/rss+xml", true},
+		{"", "application/javascript", true},
+		{"", "image/png", false},
+		{"", "application/octet-stream", false},
+		{"", "application/pdf", false},
+		{"", "text/html; charset", true},
+		{"caf\xe9", "", true},
+		{"<html></html>", "", true},
+		{string(png), "", false},
+	}
+	for _, c := range testCases {
+		assert.Equal(t, c.want, IsTextContent([]byte(c.content), c.contentType), c.contentType)
+	}
+}
+
+func TestWithCharset(t *testing.T) {
+	t.Parallel()
+	shared := NewContext(context.Background(), nil)
+	ctx := WithCharset(shared, "gbk")
+	assert.Equal(t, "gbk", CharsetFromContext(ctx))
+	// the label is not set to the shared context
+	assert.Empty(t, CharsetFromContext(shared))
+}
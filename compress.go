@@ -0,0 +1,151 @@
+package ski
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// AcceptEncoding the content encodings can be decoded by the NewFetch.
+const AcceptEncoding = "gzip, deflate, br, zstd"
+
+// Compress returns the compressed data with the content encoding,
+// the encoding is one of gzip, deflate, br, zstd.
+func Compress(data []byte, encoding string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	case "br":
+		w = brotli.NewWriter(buf)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(buf); err != nil {
+			return nil, err
+		}
+	case "identity", "":
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress returns the reader decompresses the body with the content encoding,
+// the encoding is one of gzip, deflate, br, zstd.
+func Decompress(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return &deflateReader{r: body}, nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	case "zstd":
+		d, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case "identity", "":
+		return io.NopCloser(body), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// deflateReader decodes the zlib format, or the raw deflate which sent by some servers.
+type deflateReader struct {
+	r   io.Reader
+	rc  io.ReadCloser
+	err error
+}
+
+func (d *deflateReader) Read(p []byte) (int, error) {
+	if d.rc == nil && d.err == nil {
+		var header [2]byte
+		n, err := io.ReadFull(d.r, header[:])
+		r := io.MultiReader(bytes.NewReader(header[:n]), d.r)
+		switch {
+		case err != nil && n == 0:
+			d.err = err
+		// the zlib header CMF and FLG, CM is 8 and the checksum is multiple of 31
+		case n == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0:
+			d.rc, d.err = zlib.NewReader(r)
+		default:
+			d.rc = flate.NewReader(r)
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.rc.Read(p)
+}
+
+func (d *deflateReader) Close() error {
+	if d.rc != nil {
+		return d.rc.Close()
+	}
+	return nil
+}
+
+// decompressTransport advertises the Accept-Encoding and decodes the response body,
+// the http.Transport only decodes the gzip.
+type decompressTransport struct{ *http.Transport }
+
+func (t *decompressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" || req.Method == http.MethodHead {
+		return t.Transport.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", AcceptEncoding)
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := res.Header.Get("Content-Encoding")
+	if encoding == "" || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
+	body, err := Decompress(res.Body, encoding)
+	if err != nil {
+		// the unknown encoding is returned as is
+		return res, nil //nolint:nilerr
+	}
+	res.Body = &decompressBody{body, res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return res, nil
+}
+
+// decompressBody closes the decompress reader and the original body.
+type decompressBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *decompressBody) Close() error {
+	_ = b.ReadCloser.Close()
+	return b.body.Close()
+}
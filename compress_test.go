@@ -0,0 +1,80 @@
+package ski
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+	data := []byte(strings.Repeat("hello world ", 100))
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd", "identity"} {
+		compressed, err := Compress(data, encoding)
+		if !assert.NoError(t, err, encoding) {
+			continue
+		}
+		r, err := Decompress(bytes.NewReader(compressed), encoding)
+		if !assert.NoError(t, err, encoding) {
+			continue
+		}
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err, encoding)
+		assert.Equal(t, data, got, encoding)
+		assert.NoError(t, r.Close())
+	}
+
+	// raw deflate without zlib header
+	buf := new(bytes.Buffer)
+	w, _ := flate.NewWriter(buf, flate.DefaultCompression)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	r, err := Decompress(buf, "deflate")
+	if assert.NoError(t, err) {
+		got, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, data, got)
+	}
+
+	_, err = Compress(data, "lzma")
+	assert.Error(t, err)
+	_, err = Decompress(bytes.NewReader(data), "lzma")
+	assert.Error(t, err)
+}
+
+func TestFetchDecompress(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+			_, _ = io.WriteString(w, "not accepted")
+			return
+		}
+		body, _ := Compress([]byte("hello "+encoding), encoding)
+		w.Header().Set("Content-Encoding", encoding)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+
+	fetch := NewFetch()
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+"/"+encoding, nil)
+		res, err := fetch.Do(req)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		body, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "hello "+encoding, string(body))
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		assert.True(t, res.Uncompressed)
+	}
+}
@@ -1,9 +1,25 @@
 package ski
 
 import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
 // CookieJar manages storage and use of cookies in HTTP requests.
@@ -16,24 +32,578 @@ type CookieJar interface {
 	RemoveCookie(u *url.URL)
 }
 
-// memoryCookie is an implementation of CookieJar that stores http.Cookie in in-memory.
+// EnumerableCookieJar is a CookieJar which can enumerate its cookies,
+// the CookieJar of NewCookieJar implements it.
+type EnumerableCookieJar interface {
+	CookieJar
+
+	// AllCookies returns all unexpired cookies in the jar.
+	// The Domain of a host-only cookie is the bare host, the Domain of
+	// a domain cookie has a leading dot, session cookies have zero Expires.
+	AllCookies() []*http.Cookie
+}
+
+// memoryCookie is an implementation of CookieJar that stores http.Cookie in in-memory,
+// the cookiejar.Jar stores and sends the cookies, the entries index the stored
+// cookies with their attributes to enumerate them.
 type memoryCookie struct {
 	*cookiejar.Jar
+	mu sync.Mutex
+	// entries is keyed by the domain;path;name of the cookie.
+	entries map[string]cookieEntry
+	// nextSeq is the sequence number of the next new cookie.
+	nextSeq uint64
+}
+
+// cookieEntry the stored cookie, the Domain is the canonical host without the leading dot.
+type cookieEntry struct {
+	Name     string
+	Value    string
+	Domain   string
+	Path     string
+	SameSite http.SameSite
+	Secure   bool
+	HttpOnly bool
+	HostOnly bool
+	Expires  time.Time // zero is the session cookie
+	seq      uint64
+}
+
+// NewCookieJar returns a new CookieJar that will store cookies in in-memory,
+// it implements the EnumerableCookieJar.
+func NewCookieJar() CookieJar {
+	return NewEnumerableCookieJar()
+}
+
+// NewEnumerableCookieJar returns a new EnumerableCookieJar that will store cookies in in-memory.
+func NewEnumerableCookieJar() EnumerableCookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &memoryCookie{Jar: jar, entries: make(map[string]cookieEntry)}
+}
+
+// SetCookies implements the SetCookies method of the http.CookieJar interface.
+// It does nothing if the URL's scheme is not HTTP or HTTPS.
+func (c *memoryCookie) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Jar.SetCookies(u, cookies)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	defPath := defaultPath(u.Path)
+	now := time.Now()
+
+	for _, cookie := range cookies {
+		e, ok := newCookieEntry(cookie, host, defPath)
+		if !ok {
+			continue
+		}
+		id := e.id()
+		// MaxAge takes precedence over Expires.
+		switch {
+		case cookie.MaxAge < 0:
+			delete(c.entries, id)
+			continue
+		case cookie.MaxAge > 0:
+			e.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		case !cookie.Expires.IsZero():
+			if !cookie.Expires.After(now) {
+				delete(c.entries, id)
+				continue
+			}
+			e.Expires = cookie.Expires
+		}
+		if old, ok := c.entries[id]; ok {
+			e.seq = old.seq
+		} else {
+			e.seq = c.nextSeq
+			c.nextSeq++
+		}
+		c.entries[id] = e
+	}
 }
 
-// RemoveCookie remove the cookies for the given URL.
+// RemoveCookie remove the cookies which would be sent to the given URL.
 func (c *memoryCookie) RemoveCookie(u *url.URL) {
-	exists := c.Cookies(u)
-	cookie := make([]*http.Cookie, 0, len(exists))
-	for _, e := range exists {
-		e.MaxAge = -1
-		cookie = append(cookie, e)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, e := range c.entries {
+		if !e.shouldSend(u.Scheme == "https", host, path) {
+			continue
+		}
+		cookie := &http.Cookie{Name: e.Name, Path: e.Path, MaxAge: -1}
+		if !e.HostOnly {
+			cookie.Domain = e.Domain
+		}
+		c.Jar.SetCookies(e.url(), []*http.Cookie{cookie})
+		delete(c.entries, id)
 	}
-	c.SetCookies(u, cookie)
 }
 
-// NewCookieJar returns a new CookieJar that will store cookies in in-memory.
-func NewCookieJar() CookieJar {
-	jar, _ := cookiejar.New(nil)
-	return &memoryCookie{jar}
+// AllCookies returns all unexpired cookies in the jar.
+func (c *memoryCookie) AllCookies() []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]cookieEntry, 0, len(c.entries))
+	for id, e := range c.entries {
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			delete(c.entries, id)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b cookieEntry) int { return cmp.Compare(a.seq, b.seq) })
+
+	cookies := make([]*http.Cookie, 0, len(entries))
+	for _, e := range entries {
+		cookies = append(cookies, e.toCookie())
+	}
+	return cookies
+}
+
+// newCookieEntry returns the entry of the cookie received from the host with the default path,
+// false if the cookiejar.Jar rejects the domain of the cookie.
+func newCookieEntry(cookie *http.Cookie, host, defPath string) (cookieEntry, bool) {
+	e := cookieEntry{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Path:     cookie.Path,
+		SameSite: cookie.SameSite,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+	if e.Path == "" || e.Path[0] != '/' {
+		e.Path = defPath
+	}
+
+	domain, ascii := toLowerASCII(strings.TrimPrefix(cookie.Domain, "."))
+	switch {
+	case domain == "":
+		e.Domain, e.HostOnly = host, true
+		return e, true
+	case !ascii || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, "."):
+		return e, false
+	case isIP(host):
+		// the IP domain is only accepted as the host itself
+		e.Domain, e.HostOnly = host, true
+		return e, domain == host
+	}
+	// the public suffix domain is only accepted as the host itself
+	if ps, _ := publicsuffix.PublicSuffix(domain); ps != "" && !hasDotSuffix(domain, ps) {
+		e.Domain, e.HostOnly = host, true
+		return e, domain == host
+	}
+	e.Domain = domain
+	return e, host == domain || hasDotSuffix(host, domain)
+}
+
+// id returns the domain;path;name of the entry.
+func (e *cookieEntry) id() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// url returns the URL which the entry is stored from.
+func (e *cookieEntry) url() *url.URL {
+	host := e.Domain
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return &url.URL{Scheme: "https", Host: host, Path: e.Path}
+}
+
+// shouldSend reports whether the entry would be sent to the host and path,
+// the caller should check if the entry is expired.
+func (e *cookieEntry) shouldSend(https bool, host, path string) bool {
+	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.Secure)
+}
+
+// domainMatch reports whether the entry would be sent to the host.
+func (e *cookieEntry) domainMatch(host string) bool {
+	return e.Domain == host || !e.HostOnly && hasDotSuffix(host, e.Domain)
+}
+
+// pathMatch reports whether the request path path-match the entry path, RFC 6265 section 5.1.4.
+func (e *cookieEntry) pathMatch(path string) bool {
+	if !strings.HasPrefix(path, e.Path) {
+		return false
+	}
+	return len(path) == len(e.Path) || strings.HasSuffix(e.Path, "/") || path[len(e.Path)] == '/'
+}
+
+// toCookie converts the entry to http.Cookie, the Domain of domain cookie has a leading dot.
+func (e *cookieEntry) toCookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		SameSite: e.SameSite,
+		Expires:  e.Expires,
+	}
+	if !e.HostOnly {
+		cookie.Domain = "." + e.Domain
+	}
+	return cookie
+}
+
+// canonicalHost returns the lower case punycode host without the port and trailing dot.
+func canonicalHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host, err := idna.Punycode.ToASCII(strings.TrimSuffix(host, "."))
+	if err != nil {
+		return "", err
+	}
+	host, _ = toLowerASCII(host)
+	return host, nil
+}
+
+// isIP reports whether the host is an IP address.
+func isIP(host string) bool {
+	return strings.ContainsAny(host, ":%") || net.ParseIP(host) != nil
+}
+
+// defaultPath returns the default-path of the URL path, RFC 6265 section 5.1.4.
+func defaultPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || path[0] != '/' {
+		return "/"
+	}
+	return path[:i]
+}
+
+// hasDotSuffix reports whether s ends in "."+suffix.
+func hasDotSuffix(s, suffix string) bool {
+	return len(s) > len(suffix) && strings.HasSuffix(s, suffix) && s[len(s)-len(suffix)-1] == '.'
+}
+
+// toLowerASCII returns the lower case of s and whether s is ASCII.
+func toLowerASCII(s string) (string, bool) {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return s, false
+		}
+	}
+	return strings.ToLower(s), true
+}
+
+// CookieFormat the cookie file format.
+type CookieFormat int
+
+const (
+	// CookieNetscape the Netscape cookies.txt format, used by curl and wget.
+	CookieNetscape CookieFormat = iota
+	// CookieJSON the JSON array of cookie objects format.
+	CookieJSON
+)
+
+// CookieFormatFromPath returns the CookieJSON if the path extension is .json,
+// otherwise returns the CookieNetscape.
+func CookieFormatFromPath(path string) CookieFormat {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return CookieJSON
+	}
+	return CookieNetscape
+}
+
+// SetCookie stores the cookie which exported by EnumerableCookieJar.AllCookies to the jar.
+// The cookie domain with a leading dot is stored as a domain cookie,
+// otherwise as a host-only cookie.
+func SetCookie(jar http.CookieJar, cookie *http.Cookie) {
+	domain := strings.TrimPrefix(cookie.Domain, ".")
+	if domain == "" {
+		return
+	}
+	u := &url.URL{Scheme: "http", Host: domain, Path: cookie.Path}
+	if cookie.Secure {
+		u.Scheme = "https"
+	}
+	c := *cookie
+	if !strings.HasPrefix(cookie.Domain, ".") {
+		c.Domain = ""
+	} else {
+		c.Domain = domain
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	jar.SetCookies(u, []*http.Cookie{&c})
+}
+
+// MatchCookie reports whether the cookie which exported by EnumerableCookieJar.AllCookies
+// would be sent in a request to the URL.
+func MatchCookie(cookie *http.Cookie, u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return false
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	e := cookieEntry{
+		Domain:   strings.TrimPrefix(cookie.Domain, "."),
+		HostOnly: !strings.HasPrefix(cookie.Domain, "."),
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+	}
+	if e.Path == "" {
+		e.Path = "/"
+	}
+	return e.shouldSend(u.Scheme == "https", host, path)
+}
+
+// ClearCookies removes the cookies of the domain and its subdomains from the jar,
+// if the domain is empty removes all cookies.
+func ClearCookies(jar EnumerableCookieJar, domain string) {
+	domain, _ = toLowerASCII(strings.TrimPrefix(domain, "."))
+	for _, cookie := range jar.AllCookies() {
+		d := strings.TrimPrefix(cookie.Domain, ".")
+		if domain != "" && d != domain && !hasDotSuffix(d, domain) {
+			continue
+		}
+		cookie.MaxAge = -1
+		SetCookie(jar, cookie)
+	}
+}
+
+// ReadCookies reads the cookies with the format from the reader.
+func ReadCookies(r io.Reader, format CookieFormat) ([]*http.Cookie, error) {
+	switch format {
+	case CookieJSON:
+		var items []cookieJSON
+		if err := json.NewDecoder(r).Decode(&items); err != nil {
+			return nil, err
+		}
+		cookies := make([]*http.Cookie, 0, len(items))
+		for _, item := range items {
+			cookies = append(cookies, item.toCookie())
+		}
+		return cookies, nil
+	default:
+		return readNetscapeCookies(r)
+	}
+}
+
+// WriteCookies writes the cookies with the format to the writer.
+func WriteCookies(w io.Writer, cookies []*http.Cookie, format CookieFormat) error {
+	switch format {
+	case CookieJSON:
+		items := make([]cookieJSON, 0, len(cookies))
+		for _, cookie := range cookies {
+			items = append(items, toCookieJSON(cookie))
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(items)
+	default:
+		return writeNetscapeCookies(w, cookies)
+	}
+}
+
+// LoadCookies loads the cookies file to the jar, the file format is determined by CookieFormatFromPath.
+func LoadCookies(jar CookieJar, path string) error {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cookies, err := ReadCookies(file, CookieFormatFromPath(path))
+	if err != nil {
+		return fmt.Errorf("load cookies %s: %w", path, err)
+	}
+	for _, cookie := range cookies {
+		SetCookie(jar, cookie)
+	}
+	return nil
+}
+
+// SaveCookies saves all cookies of the jar to the file, the file format is determined by CookieFormatFromPath.
+func SaveCookies(jar EnumerableCookieJar, path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if err = WriteCookies(file, jar.AllCookies(), CookieFormatFromPath(path)); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// readNetscapeCookies parses the Netscape cookies.txt format,
+// each line has seven tab separated fields:
+// domain, include subdomains, path, secure, expires, name, value.
+func readNetscapeCookies(r io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(text, netscapeHttpOnlyPrefix) {
+			text = text[len(netscapeHttpOnlyPrefix):]
+			httpOnly = true
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, but got %d", line, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expires %s", line, fields[4])
+		}
+		domain := fields[0]
+		if strings.EqualFold(fields[1], "TRUE") {
+			if !strings.HasPrefix(domain, ".") {
+				domain = "." + domain
+			}
+		} else {
+			domain = strings.TrimPrefix(domain, ".")
+		}
+		cookie := &http.Cookie{
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// writeNetscapeCookies writes the Netscape cookies.txt format.
+func writeNetscapeCookies(w io.Writer, cookies []*http.Cookie) error {
+	buf := bufio.NewWriter(w)
+	_, _ = buf.WriteString("# Netscape HTTP Cookie File\n\n")
+	boolString := func(b bool) string {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	for _, cookie := range cookies {
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+		if cookie.HttpOnly {
+			_, _ = buf.WriteString(netscapeHttpOnlyPrefix)
+		}
+		_, _ = fmt.Fprintf(buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			cookie.Domain,
+			boolString(strings.HasPrefix(cookie.Domain, ".")),
+			cookie.Path,
+			boolString(cookie.Secure),
+			expires,
+			cookie.Name,
+			cookie.Value)
+	}
+	return buf.Flush()
+}
+
+// cookieJSON the JSON format of cookie
+type cookieJSON struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Expires  int64  `json:"expires,omitempty"` // unix seconds, zero is session cookie
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"httpOnly"`
+	HostOnly bool   `json:"hostOnly"`
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+var sameSiteNames = [...]string{
+	http.SameSiteDefaultMode: "",
+	http.SameSiteLaxMode:     "lax",
+	http.SameSiteStrictMode:  "strict",
+	http.SameSiteNoneMode:    "none",
+}
+
+func toCookieJSON(cookie *http.Cookie) cookieJSON {
+	c := cookieJSON{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   strings.TrimPrefix(cookie.Domain, "."),
+		Path:     cookie.Path,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		HostOnly: !strings.HasPrefix(cookie.Domain, "."),
+	}
+	if !cookie.Expires.IsZero() {
+		c.Expires = cookie.Expires.Unix()
+	}
+	if int(cookie.SameSite) < len(sameSiteNames) {
+		c.SameSite = sameSiteNames[cookie.SameSite]
+	}
+	return c
+}
+
+func (c cookieJSON) toCookie() *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+	if !c.HostOnly && c.Domain != "" {
+		cookie.Domain = "." + strings.TrimPrefix(c.Domain, ".")
+	}
+	if c.Expires > 0 {
+		cookie.Expires = time.Unix(c.Expires, 0)
+	}
+	switch strings.ToLower(c.SameSite) {
+	case "lax":
+		cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie
 }
@@ -1,8 +1,11 @@
 package ski
 
 import (
+	"bytes"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,7 +13,7 @@ import (
 
 func TestCookie(t *testing.T) {
 	t.Parallel()
-	c := NewCookieJar()
+	c := NewEnumerableCookieJar()
 
 	u, _ := url.Parse("https://github.com")
 
@@ -20,3 +23,182 @@ func TestCookie(t *testing.T) {
 	c.RemoveCookie(u)
 	assert.Nil(t, c.Cookies(u))
 }
+
+func TestCookieRemove(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u, _ := url.Parse("https://www.example.com/a/b")
+	c.SetCookies(u, []*http.Cookie{
+		{Name: "plain", Value: "1", Path: "/"},
+		{Name: "secure", Value: "2", Path: "/", Secure: true},
+		{Name: "domain", Value: "3", Path: "/", Domain: "example.com"},
+		{Name: "other", Value: "4", Path: "/c"},
+	})
+
+	// the secure cookie is not sent to the http URL
+	c.RemoveCookie(&url.URL{Scheme: "http", Host: "www.example.com", Path: "/a"})
+	assert.Equal(t, []*http.Cookie{{Name: "secure", Value: "2"}}, c.Cookies(u))
+	if cookies := c.AllCookies(); assert.Len(t, cookies, 2) {
+		assert.Equal(t, "secure", cookies[0].Name)
+		assert.Equal(t, "other", cookies[1].Name)
+	}
+
+	c.RemoveCookie(u)
+	assert.Nil(t, c.Cookies(u))
+	assert.Len(t, c.AllCookies(), 1)
+}
+
+func TestCookieRejected(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u, _ := url.Parse("https://github.com/")
+	c.SetCookies(u, []*http.Cookie{{Name: "foo", Value: "1", Domain: "github.com"}})
+	// the other host can not overwrite the domain cookie
+	other, _ := url.Parse("https://www.example.com/")
+	c.SetCookies(other, []*http.Cookie{
+		{Name: "foo", Value: "2", Domain: "github.com"},
+		{Name: "bar", Value: "3", Domain: "com"},
+		{Name: "baz", Value: "4", Domain: "example.com."},
+	})
+	assert.Equal(t, []*http.Cookie{{Name: "foo", Value: "1"}}, c.Cookies(u))
+	assert.Nil(t, c.Cookies(other))
+	if cookies := c.AllCookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, "1", cookies[0].Value)
+	}
+
+	ip, _ := url.Parse("http://127.0.0.1:8080/")
+	c.SetCookies(ip, []*http.Cookie{{Name: "ip", Value: "5", Domain: "127.0.0.1"}, {Name: "bad", Value: "6", Domain: "0.0.1"}})
+	assert.Equal(t, []*http.Cookie{{Name: "ip", Value: "5"}}, c.Cookies(ip))
+	assert.Len(t, c.AllCookies(), 2)
+}
+
+func TestCookieIDNA(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u, _ := url.Parse("https://bücher.example/")
+	c.SetCookies(u, []*http.Cookie{{Name: "foo", Value: "bar", Path: "/"}})
+
+	punycode, _ := url.Parse("https://xn--bcher-kva.example/")
+	if assert.Len(t, c.Cookies(punycode), 1) {
+		assert.Equal(t, "bar", c.Cookies(punycode)[0].Value)
+	}
+	assert.Len(t, c.Cookies(u), 1)
+	if cookies := c.AllCookies(); assert.Len(t, cookies, 1) {
+		assert.Equal(t, "xn--bcher-kva.example", cookies[0].Domain)
+		assert.True(t, MatchCookie(cookies[0], u))
+	}
+}
+
+func TestCookieAllCookies(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u, _ := url.Parse("https://www.example.com/path/index")
+	c.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: "example.com", Path: "/", Secure: true},
+		{Name: "persistent", Value: "3", Path: "/", MaxAge: 3600, HttpOnly: true},
+		{Name: "illegal", Value: "4", Domain: "github.com"},
+	})
+
+	cookies := c.AllCookies()
+	if assert.Len(t, cookies, 3) {
+		assert.Equal(t, "www.example.com", cookies[0].Domain)
+		assert.Equal(t, "/path", cookies[0].Path)
+		assert.True(t, cookies[0].Expires.IsZero())
+		assert.Equal(t, ".example.com", cookies[1].Domain)
+		assert.False(t, cookies[2].Expires.IsZero())
+	}
+
+	sub, _ := url.Parse("http://api.example.com/")
+	if assert.Len(t, c.Cookies(sub), 0) {
+		sub.Scheme = "https"
+		assert.Equal(t, "2", c.Cookies(sub)[0].Value)
+	}
+}
+
+func TestCookieClear(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u1, _ := url.Parse("https://www.example.com/")
+	u2, _ := url.Parse("https://github.com/")
+	c.SetCookies(u1, []*http.Cookie{{Name: "foo", Value: "1"}, {Name: "bar", Value: "2", Domain: "example.com"}})
+	c.SetCookies(u2, []*http.Cookie{{Name: "baz", Value: "3"}})
+
+	for _, cookie := range c.AllCookies() {
+		assert.True(t, MatchCookie(cookie, u1) || MatchCookie(cookie, u2))
+	}
+
+	ClearCookies(c, "example.com")
+	assert.Nil(t, c.Cookies(u1))
+	assert.Len(t, c.Cookies(u2), 1)
+
+	ClearCookies(c, "")
+	assert.Empty(t, c.AllCookies())
+}
+
+func TestCookieFormat(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+
+	u, _ := url.Parse("https://www.example.com/")
+	c.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1", Path: "/"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/", Secure: true, MaxAge: 3600},
+		{Name: "http_only", Value: "3", Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode},
+	})
+
+	for _, format := range []CookieFormat{CookieNetscape, CookieJSON} {
+		buf := new(bytes.Buffer)
+		if !assert.NoError(t, WriteCookies(buf, c.AllCookies(), format)) {
+			continue
+		}
+		cookies, err := ReadCookies(buf, format)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		jar := NewEnumerableCookieJar()
+		for _, cookie := range cookies {
+			SetCookie(jar, cookie)
+		}
+		assert.Len(t, jar.Cookies(u), 3)
+		all := jar.AllCookies()
+		if assert.Len(t, all, 3) {
+			assert.Equal(t, ".example.com", all[1].Domain)
+			assert.True(t, all[2].HttpOnly)
+		}
+	}
+
+	cookies, err := ReadCookies(strings.NewReader("# Netscape HTTP Cookie File\n"+
+		".github.com\tTRUE\t/\tTRUE\t0\tlogged_in\tno\n"+
+		"#HttpOnly_github.com\tFALSE\t/\tTRUE\t0\t_gh_sess\tfoo\n"), CookieNetscape)
+	if assert.NoError(t, err) && assert.Len(t, cookies, 2) {
+		assert.Equal(t, ".github.com", cookies[0].Domain)
+		assert.Equal(t, "github.com", cookies[1].Domain)
+		assert.True(t, cookies[1].HttpOnly)
+	}
+
+	_, err = ReadCookies(strings.NewReader("github.com\tFALSE\t/\n"), CookieNetscape)
+	assert.ErrorContains(t, err, "expected 7 tab separated fields")
+}
+
+func TestCookieFile(t *testing.T) {
+	t.Parallel()
+	c := NewEnumerableCookieJar()
+	u, _ := url.Parse("https://github.com")
+	c.SetCookies(u, []*http.Cookie{{Name: "foo", Value: "bar", Path: "/", MaxAge: 3600}})
+
+	for _, name := range []string{"cookies.txt", "cookies.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if assert.NoError(t, SaveCookies(c, path)) {
+			jar := NewEnumerableCookieJar()
+			if assert.NoError(t, LoadCookies(jar, path)) {
+				assert.Equal(t, "bar", jar.Cookies(u)[0].Value)
+			}
+		}
+	}
+}
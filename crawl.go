@@ -0,0 +1,590 @@
+package ski
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// CrawlRequest the URL of the crawl frontier.
+type CrawlRequest struct {
+	URL string `json:"url"`
+	// Depth the number of links from the seed URL.
+	Depth int `json:"depth"`
+	// Priority the higher priority request is crawled first.
+	Priority int `json:"priority"`
+}
+
+// CrawlResult the result of the crawled page.
+type CrawlResult struct {
+	URL    string `json:"url"`
+	Depth  int    `json:"depth"`
+	Status int    `json:"status,omitempty"`
+	// Data the result of the CrawlOptions.Extract.
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// CrawlOptions the Crawler options.
+type CrawlOptions struct {
+	// Links extracts the links of the page content, the result is the URL string or
+	// the object contains url and priority, or the array of them. The relative URL is
+	// resolved with the page URL.
+	Links Executor `yaml:"-" json:"-"`
+	// Extract extracts the data of the page content.
+	Extract Executor `yaml:"-" json:"-"`
+	// MaxDepth the max depth of the links, zero means unlimited.
+	MaxDepth int `yaml:"max-depth" json:"maxDepth"`
+	// MaxPages the max pages to crawl, zero means unlimited.
+	MaxPages int `yaml:"max-pages" json:"maxPages"`
+	// Concurrency the max concurrent requests, default is 1.
+	Concurrency int `yaml:"concurrency" json:"concurrency"`
+	// Delay the min interval between the requests of each host.
+	Delay time.Duration `yaml:"delay" json:"delay"`
+	// SameHost only crawls the links of the seed hosts.
+	SameHost bool `yaml:"same-host" json:"sameHost"`
+	// Seen stores the seen URLs for deduplication, such as shared by the crawls, default is in memory.
+	Seen Cache `yaml:"-" json:"-"`
+	// Checkpoint the file saves the frontier periodically and when the crawl stops, which is resumed by Crawler.Resume.
+	// The seen URLs are appended to the Checkpoint + ".seen" file incrementally.
+	Checkpoint string `yaml:"checkpoint" json:"checkpoint"`
+	// CheckpointInterval saves the checkpoint every number of pages, default is 10.
+	CheckpointInterval int `yaml:"checkpoint-interval" json:"checkpointInterval"`
+}
+
+func (opt CrawlOptions) withDefaults() CrawlOptions {
+	if opt.Concurrency <= 0 {
+		opt.Concurrency = 1
+	}
+	if opt.Seen == nil {
+		opt.Seen = NewCache()
+	}
+	if opt.CheckpointInterval <= 0 {
+		opt.CheckpointInterval = 10
+	}
+	return opt
+}
+
+// Crawler crawls the pages from the seed URLs, the links of the pages
+// are deduplicated and added to the frontier.
+type Crawler struct {
+	fetch    Fetch
+	opt      CrawlOptions
+	mu       sync.Mutex
+	frontier crawlFrontier
+	seq      int
+	pages    int
+	pushing  map[string]bool // the URLs are being checked by the Seen
+	seen     []string        // the seen URLs are not saved to the checkpoint yet
+	seenSize int64           // the size of the seen file of the last checkpoint
+	saveMu   sync.Mutex      // serializes the SaveCheckpoint
+	hosts    map[string]bool // the seed hosts
+	next     map[string]time.Time
+}
+
+// NewCrawler returns a new Crawler with the Fetch and CrawlOptions.
+func NewCrawler(fetch Fetch, opt CrawlOptions) *Crawler {
+	return &Crawler{
+		fetch:   fetch,
+		opt:     opt.withDefaults(),
+		pushing: make(map[string]bool),
+		hosts:   make(map[string]bool),
+		next:    make(map[string]time.Time),
+	}
+}
+
+// Add adds the seed URL to the frontier, the seen URL is ignored.
+func (c *Crawler) Add(ctx context.Context, rawURL string, priority int) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported crawl URL %q", rawURL)
+	}
+	c.mu.Lock()
+	c.hosts[u.Hostname()] = true
+	c.mu.Unlock()
+	_, err = c.push(ctx, CrawlRequest{URL: u.String(), Priority: priority})
+	return err
+}
+
+// Len returns the number of the requests in the frontier.
+func (c *Crawler) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frontier.Len()
+}
+
+// push adds the request to the frontier if it is not seen,
+// the lock is not held during the Seen calls.
+func (c *Crawler) push(ctx context.Context, req CrawlRequest) (bool, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return false, err
+	}
+	u.Fragment = ""
+	req.URL = u.String()
+
+	c.mu.Lock()
+	if c.pushing[req.URL] {
+		c.mu.Unlock()
+		return false, nil
+	}
+	c.pushing[req.URL] = true
+	c.mu.Unlock()
+
+	added, err := c.markSeen(ctx, req.URL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pushing, req.URL)
+	if !added {
+		return false, err
+	}
+	if c.opt.Checkpoint != "" {
+		c.seen = append(c.seen, req.URL)
+	}
+	c.enqueue(req)
+	return true, nil
+}
+
+// markSeen sets the URL to the Seen, reports whether the URL is not seen before.
+func (c *Crawler) markSeen(ctx context.Context, rawURL string) (bool, error) {
+	key := "crawl:" + rawURL
+	seen, err := c.opt.Seen.Get(ctx, key)
+	if err != nil || len(seen) > 0 {
+		return false, err
+	}
+	if err = c.opt.Seen.Set(ctx, key, []byte{1}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// enqueue adds the request to the frontier without deduplication, the lock must be held.
+func (c *Crawler) enqueue(req CrawlRequest) {
+	c.seq++
+	heap.Push(&c.frontier, crawlItem{req, c.seq})
+}
+
+// Run crawls until the frontier is empty, the MaxPages is reached or the context is done.
+// The fn is called with the result of each page, the crawl stops if it returns an error.
+func (c *Crawler) Run(ctx context.Context, fn func(CrawlResult) error) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type done struct {
+		req   CrawlRequest
+		res   CrawlResult
+		links []CrawlRequest
+	}
+	var (
+		results  = make(chan done)
+		inflight = make(map[CrawlRequest]bool)
+		visited  int
+	)
+	defer func() {
+		// the unfinished requests are crawled again after resume
+		cancel()
+		for len(inflight) > 0 {
+			d := <-results
+			delete(inflight, d.req)
+			c.mu.Lock()
+			c.pages--
+			c.enqueue(d.req)
+			c.mu.Unlock()
+		}
+		if c.opt.Checkpoint != "" {
+			err = errors.Join(err, c.SaveCheckpoint(c.opt.Checkpoint))
+		}
+	}()
+
+	for {
+		c.mu.Lock()
+		for len(inflight) < c.opt.Concurrency && c.frontier.Len() > 0 &&
+			(c.opt.MaxPages <= 0 || c.pages < c.opt.MaxPages) {
+			req := heap.Pop(&c.frontier).(crawlItem).CrawlRequest
+			c.pages++
+			inflight[req] = true
+			go func() {
+				res, links := c.visit(ctx, req)
+				results <- done{req, res, links}
+			}()
+		}
+		c.mu.Unlock()
+		if len(inflight) == 0 {
+			return nil
+		}
+
+		var d done
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d = <-results:
+		}
+		delete(inflight, d.req)
+		if ctx.Err() != nil {
+			c.mu.Lock()
+			c.pages--
+			c.enqueue(d.req)
+			c.mu.Unlock()
+			return ctx.Err()
+		}
+
+		for _, link := range d.links {
+			if _, err = c.push(ctx, link); err != nil {
+				return err
+			}
+		}
+		if err = fn(d.res); err != nil {
+			return err
+		}
+		if visited++; c.opt.Checkpoint != "" && visited%c.opt.CheckpointInterval == 0 {
+			if err = c.SaveCheckpoint(c.opt.Checkpoint); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// visit fetches the page, returns the result and the links.
+func (c *Crawler) visit(ctx context.Context, req CrawlRequest) (result CrawlResult, links []CrawlRequest) {
+	result = CrawlResult{URL: req.URL, Depth: req.Depth}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	if err = c.wait(ctx, u.Host); err != nil {
+		result.Error = err.Error()
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	res, err := c.fetch.Do(httpReq)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	defer res.Body.Close()
+	result.Status = res.StatusCode
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		result.Error = res.Status
+		return
+	}
+	if contentType := res.Header.Get("Content-Type"); IsTextContent(body, contentType) {
+		if body, err = DecodeCharset(body, contentType, CharsetFromContext(ctx)); err != nil {
+			result.Error = err.Error()
+			return
+		}
+	}
+
+	content := string(body)
+	base := res.Request.URL
+	execCtx := NewContext(ctx, map[any]any{"url": base.String()})
+	if c.opt.Extract != nil {
+		if result.Data, err = c.opt.Extract.Exec(execCtx, content); err != nil {
+			result.Error = err.Error()
+		}
+	}
+	if c.opt.Links != nil && (c.opt.MaxDepth <= 0 || req.Depth < c.opt.MaxDepth) {
+		ret, err := c.opt.Links.Exec(execCtx, content)
+		if err != nil {
+			if result.Error != "" {
+				result.Error += "; "
+			}
+			result.Error += err.Error()
+			return
+		}
+		links = c.links(base, req, ret)
+	}
+	return
+}
+
+// links returns the requests of the links result.
+func (c *Crawler) links(base *url.URL, parent CrawlRequest, ret any) []CrawlRequest {
+	var items []any
+	switch t := ret.(type) {
+	case nil:
+	case Iterator:
+		for i := 0; i < t.Len(); i++ {
+			items = append(items, t.At(i))
+		}
+	case []any:
+		items = t
+	case []string:
+		for _, s := range t {
+			items = append(items, s)
+		}
+	default:
+		items = []any{t}
+	}
+
+	links := make([]CrawlRequest, 0, len(items))
+	for _, item := range items {
+		link := CrawlRequest{Depth: parent.Depth + 1, Priority: parent.Priority}
+		if m, ok := item.(map[string]any); ok {
+			link.URL = cast.ToString(m["url"])
+			if p, ok := m["priority"]; ok {
+				link.Priority = cast.ToInt(p)
+			}
+		} else {
+			link.URL = cast.ToString(item)
+		}
+		u, err := base.Parse(link.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		if c.opt.SameHost {
+			c.mu.Lock()
+			same := c.hosts[u.Hostname()]
+			c.mu.Unlock()
+			if !same {
+				continue
+			}
+		}
+		link.URL = u.String()
+		links = append(links, link)
+	}
+	return links
+}
+
+// wait waits for the Delay of the host.
+func (c *Crawler) wait(ctx context.Context, host string) error {
+	if c.opt.Delay <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := time.Now()
+	next := c.next[host]
+	if next.Before(now) {
+		next = now
+	}
+	c.next[host] = next.Add(c.opt.Delay)
+	c.mu.Unlock()
+
+	if d := next.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// crawlCheckpoint the saved state of the Crawler.
+type crawlCheckpoint struct {
+	Pages int      `json:"pages"`
+	Hosts []string `json:"hosts"`
+	// Seen the seen URLs of the previous checkpoint format, which are moved to the seen file.
+	Seen []string `json:"seen,omitempty"`
+	// SeenSize the size of the seen file, the later appended URLs are discarded on resume.
+	SeenSize int64          `json:"seenSize"`
+	Frontier []CrawlRequest `json:"frontier"`
+}
+
+// checkpointSeen returns the file of the seen URLs of the checkpoint, one URL per line.
+func checkpointSeen(name string) string { return name + ".seen" }
+
+// SaveCheckpoint saves the frontier to the file, and appends the seen URLs since
+// the last checkpoint to the seen file, so the name should not be changed during the crawl.
+// The seen URLs are recorded only if the CrawlOptions.Checkpoint is set.
+func (c *Crawler) SaveCheckpoint(name string) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	cp := crawlCheckpoint{
+		Pages:    c.pages,
+		Frontier: make([]CrawlRequest, 0, c.frontier.Len()),
+	}
+	for host := range c.hosts {
+		cp.Hosts = append(cp.Hosts, host)
+	}
+	items := make(crawlFrontier, len(c.frontier))
+	copy(items, c.frontier)
+	seen := slices.Clone(c.seen)
+	seenSize := c.seenSize
+	c.mu.Unlock()
+
+	seenSize, err := appendSeen(checkpointSeen(name), seenSize, seen)
+	if err != nil {
+		return err
+	}
+	cp.SeenSize = seenSize
+
+	for items.Len() > 0 {
+		cp.Frontier = append(cp.Frontier, heap.Pop(&items).(crawlItem).CrawlRequest)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.seen = slices.Delete(c.seen, 0, len(seen))
+	c.seenSize = seenSize
+	c.mu.Unlock()
+	return nil
+}
+
+// appendSeen writes the URLs to the seen file at the offset, the content after
+// the offset is not saved by the checkpoint and is overwritten. Returns the new size.
+func appendSeen(name string, offset int64, urls []string) (int64, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	var buf []byte
+	for _, u := range urls {
+		buf = append(append(buf, u...), '\n')
+	}
+	if _, err = f.WriteAt(buf, offset); err == nil {
+		err = f.Truncate(offset + int64(len(buf)))
+	}
+	if err = errors.Join(err, f.Close()); err != nil {
+		return 0, err
+	}
+	return offset + int64(len(buf)), nil
+}
+
+// readSeen returns the URLs of the seen file up to the size.
+func readSeen(name string, size int64) ([]string, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) < size {
+		return nil, fmt.Errorf("invalid checkpoint seen file %s: %w", name, io.ErrUnexpectedEOF)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+// RemoveCheckpoint removes the checkpoint file and its seen file.
+func RemoveCheckpoint(name string) error {
+	err := os.Remove(checkpointSeen(name))
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return errors.Join(err, os.Remove(name))
+}
+
+// Resume restores the frontier and the seen URLs from the checkpoint file.
+// It returns false if the file does not exist.
+func (c *Crawler) Resume(ctx context.Context, name string) (bool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	var cp crawlCheckpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return false, fmt.Errorf("invalid checkpoint %s: %w", name, err)
+	}
+	seen, err := readSeen(checkpointSeen(name), cp.SeenSize)
+	if err != nil {
+		return false, err
+	}
+	for _, urls := range [][]string{seen, cp.Seen} {
+		for _, u := range urls {
+			if err = c.opt.Seen.Set(ctx, "crawl:"+u, []byte{1}); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = cp.Pages
+	for _, host := range cp.Hosts {
+		c.hosts[host] = true
+	}
+	c.seenSize = cp.SeenSize
+	// the seen URLs of the previous checkpoint format are appended to the seen file by the next checkpoint
+	c.seen = append(c.seen, cp.Seen...)
+	for _, req := range cp.Frontier {
+		c.enqueue(req)
+	}
+	return true, nil
+}
+
+type crawlItem struct {
+	CrawlRequest
+	seq int
+}
+
+// crawlFrontier the priority queue, the higher priority first, then the first in first out.
+type crawlFrontier []crawlItem
+
+func (f crawlFrontier) Len() int { return len(f) }
+
+func (f crawlFrontier) Less(i, j int) bool {
+	if f[i].Priority != f[j].Priority {
+		return f[i].Priority > f[j].Priority
+	}
+	return f[i].seq < f[j].seq
+}
+
+func (f crawlFrontier) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+
+func (f *crawlFrontier) Push(x any) { *f = append(*f, x.(crawlItem)) }
+
+func (f *crawlFrontier) Pop() any {
+	old := *f
+	n := len(old)
+	item := old[n-1]
+	*f = old[:n-1]
+	return item
+}
@@ -0,0 +1,169 @@
+package ski
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type execFunc func(ctx context.Context, arg any) (any, error)
+
+func (f execFunc) Exec(ctx context.Context, arg any) (any, error) { return f(ctx, arg) }
+
+func TestCrawler(t *testing.T) {
+	t.Parallel()
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a><a href="b">b</a><a href="/a#top">a</a><a href="https://example.com/">ext</a>`,
+		"/a": `<a href="/c">c</a><a href="/">root</a>`,
+		"/b": `<a href="/d">d</a>`,
+		"/c": `<a href="/e">e</a>`,
+		"/d": ``,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprint(w, page)
+	}))
+	defer ts.Close()
+
+	href := regexp.MustCompile(`href="([^"]+)"`)
+	links := execFunc(func(_ context.Context, arg any) (any, error) {
+		var ret []any
+		for _, m := range href.FindAllStringSubmatch(arg.(string), -1) {
+			ret = append(ret, m[1])
+		}
+		return ret, nil
+	})
+	extract := execFunc(func(ctx context.Context, arg any) (any, error) {
+		return strings.Count(arg.(string), "<a"), nil
+	})
+
+	crawl := func(c *Crawler) (visited []string, err error) {
+		err = c.Run(context.Background(), func(res CrawlResult) error {
+			visited = append(visited, strings.TrimPrefix(res.URL, ts.URL))
+			return nil
+		})
+		return
+	}
+
+	t.Run("depth", func(t *testing.T) {
+		c := NewCrawler(http.DefaultClient, CrawlOptions{Links: links, Extract: extract, MaxDepth: 1, SameHost: true})
+		assert.NoError(t, c.Add(context.Background(), ts.URL+"/", 0))
+		visited, err := crawl(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/", "/a", "/b"}, visited)
+	})
+
+	t.Run("result", func(t *testing.T) {
+		c := NewCrawler(http.DefaultClient, CrawlOptions{Links: links, Extract: extract, MaxPages: 2, SameHost: true})
+		assert.NoError(t, c.Add(context.Background(), ts.URL+"/b", 0))
+		assert.NoError(t, c.Add(context.Background(), ts.URL+"/x", 1))
+		var results []CrawlResult
+		err := c.Run(context.Background(), func(res CrawlResult) error {
+			results = append(results, res)
+			return nil
+		})
+		assert.NoError(t, err)
+		if assert.Len(t, results, 2) {
+			assert.Equal(t, CrawlResult{URL: ts.URL + "/x", Status: 404, Error: "404 Not Found"}, results[0])
+			assert.Equal(t, CrawlResult{URL: ts.URL + "/b", Status: 200, Data: 1}, results[1])
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+		opt := CrawlOptions{Links: links, SameHost: true, Checkpoint: checkpoint}
+
+		c := NewCrawler(http.DefaultClient, opt)
+		assert.NoError(t, c.Add(context.Background(), ts.URL+"/", 0))
+		stop := errors.New("stop")
+		var visited []string
+		err := c.Run(context.Background(), func(res CrawlResult) error {
+			visited = append(visited, strings.TrimPrefix(res.URL, ts.URL))
+			if len(visited) == 2 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+
+		// the seen URLs are saved to the seen file instead of the checkpoint
+		data, err := os.ReadFile(checkpoint)
+		if assert.NoError(t, err) {
+			assert.NotContains(t, string(data), `"seen":`)
+		}
+		data, err = os.ReadFile(checkpoint + ".seen")
+		if assert.NoError(t, err) {
+			assert.Equal(t, 4, strings.Count(string(data), "\n"))
+		}
+		// the URLs appended after the checkpoint are discarded
+		f, err := os.OpenFile(checkpoint+".seen", os.O_APPEND|os.O_WRONLY, 0)
+		if assert.NoError(t, err) {
+			_, _ = f.WriteString(ts.URL + "/e\n")
+			_ = f.Close()
+		}
+
+		c = NewCrawler(http.DefaultClient, opt)
+		ok, err := c.Resume(context.Background(), checkpoint)
+		assert.True(t, ok)
+		assert.NoError(t, err)
+		resumed, err := crawl(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/", "/a", "/b", "/c", "/d", "/e"}, append(visited, resumed...))
+		data, err = os.ReadFile(checkpoint + ".seen")
+		if assert.NoError(t, err) {
+			assert.Equal(t, 6, strings.Count(string(data), "\n"))
+		}
+
+		assert.NoError(t, RemoveCheckpoint(checkpoint))
+		assert.NoFileExists(t, checkpoint+".seen")
+	})
+
+	t.Run("resume seen", func(t *testing.T) {
+		// the seen URLs of the checkpoint are moved to the seen file
+		checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+		data := fmt.Sprintf(`{"pages":1,"hosts":["127.0.0.1"],"seen":["%[1]s/","%[1]s/a","%[1]s/b"],"frontier":[{"url":"%[1]s/b","depth":1}]}`, ts.URL)
+		assert.NoError(t, os.WriteFile(checkpoint, []byte(data), 0o600))
+
+		c := NewCrawler(http.DefaultClient, CrawlOptions{Links: links, SameHost: true, Checkpoint: checkpoint})
+		ok, err := c.Resume(context.Background(), checkpoint)
+		assert.True(t, ok)
+		assert.NoError(t, err)
+		visited, err := crawl(c)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/b", "/d"}, visited)
+		seen, err := os.ReadFile(checkpoint + ".seen")
+		if assert.NoError(t, err) {
+			assert.Equal(t, 4, strings.Count(string(seen), "\n"))
+		}
+	})
+
+	t.Run("push", func(t *testing.T) {
+		c := NewCrawler(http.DefaultClient, CrawlOptions{})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, c.Add(context.Background(), ts.URL+"/", 0))
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, c.Len())
+		assert.Empty(t, c.seen)
+	})
+}
@@ -0,0 +1,175 @@
+package ski
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The operations of the Change.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change the structural change of the JSON value.
+type Change struct {
+	// Op the operation: added, removed, changed.
+	Op string `json:"op"`
+	// Path the JSON Pointer (RFC 6901) of the value, empty is the root.
+	Path string `json:"path"`
+	// Old the previous value, New the current value. The JSON of the change
+	// has the old of the removed and changed, the new of the added and changed,
+	// even if the value is null.
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// MarshalJSON encodes the change with the sides of the operation, the null sides are kept.
+func (c Change) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Op   string `json:"op"`
+		Path string `json:"path"`
+		Old  *any   `json:"old,omitempty"`
+		New  *any   `json:"new,omitempty"`
+	}{Op: c.Op, Path: c.Path}
+	if c.Op != ChangeAdded {
+		v.Old = &c.Old
+	}
+	if c.Op != ChangeRemoved {
+		v.New = &c.New
+	}
+	return json.Marshal(v)
+}
+
+// Diff returns the structural changes from the old to the new value, the values
+// are compared as JSON. The objects are compared by the keys, the arrays by the indexes.
+func Diff(old, new any) ([]Change, error) {
+	o, err := normalizeJSON(old)
+	if err != nil {
+		return nil, err
+	}
+	n, err := normalizeJSON(new)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	diffValue("", o, n, &changes)
+	return changes, nil
+}
+
+// normalizeJSON returns the value of the JSON round-trip.
+func normalizeJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var ret any
+	return ret, json.Unmarshal(data, &ret)
+}
+
+func diffValue(path string, old, new any, changes *[]Change) {
+	switch o := old.(type) {
+	case map[string]any:
+		if n, ok := new.(map[string]any); ok {
+			keys := make([]string, 0, len(o)+len(n))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			for k := range n {
+				if _, ok := o[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				ov, inOld := o[k]
+				nv, inNew := n[k]
+				p := path + "/" + escapePointer(k)
+				switch {
+				case !inOld:
+					*changes = append(*changes, Change{Op: ChangeAdded, Path: p, New: nv})
+				case !inNew:
+					*changes = append(*changes, Change{Op: ChangeRemoved, Path: p, Old: ov})
+				default:
+					diffValue(p, ov, nv, changes)
+				}
+			}
+			return
+		}
+	case []any:
+		if n, ok := new.([]any); ok {
+			for i := 0; i < max(len(o), len(n)); i++ {
+				p := path + "/" + strconv.Itoa(i)
+				switch {
+				case i >= len(o):
+					*changes = append(*changes, Change{Op: ChangeAdded, Path: p, New: n[i]})
+				case i >= len(n):
+					*changes = append(*changes, Change{Op: ChangeRemoved, Path: p, Old: o[i]})
+				default:
+					diffValue(p, o[i], n[i], changes)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Op: ChangeChanged, Path: path, Old: old, New: new})
+	}
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapePointer(s string) string { return pointerEscaper.Replace(s) }
+
+// ChangeDetector detects the changes of the results between the runs,
+// the previous result of each key is stored in the Cache.
+type ChangeDetector struct {
+	cache Cache
+}
+
+// NewChangeDetector returns a new ChangeDetector stores the previous results
+// in the cache, such as NewFileCache to keep the results between the processes.
+func NewChangeDetector(cache Cache) *ChangeDetector {
+	return &ChangeDetector{cache}
+}
+
+// Detect returns the changes of the result against the previous result of the key,
+// then stores the result as the previous. The first result of the key is
+// the added change of the root, the empty changes means nothing changed.
+func (d *ChangeDetector) Detect(ctx context.Context, key string, v any) ([]Change, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	key = "diff:" + key
+	prev, err := d.cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	if len(prev) == 0 {
+		cur, err := normalizeJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		changes = []Change{{Op: ChangeAdded, New: cur}}
+	} else {
+		var old any
+		if err = json.Unmarshal(prev, &old); err != nil {
+			return nil, err
+		}
+		if changes, err = Diff(old, v); err != nil {
+			return nil, err
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, d.cache.Set(ctx, key, data)
+}
@@ -0,0 +1,80 @@
+package ski
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	old := map[string]any{
+		"title": "foo",
+		"price": 1,
+		"tags":  []any{"a", "b"},
+		"a/b":   map[string]any{"x": 1, "y": 2},
+	}
+	changes, err := Diff(old, map[string]any{
+		"title": "bar",
+		"price": 1.0,
+		"tags":  []any{"a"},
+		"a/b":   map[string]any{"x": 1, "z": true},
+		"new":   NewIterator([]int{1}),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Op: ChangeRemoved, Path: "/a~1b/y", Old: float64(2)},
+		{Op: ChangeAdded, Path: "/a~1b/z", New: true},
+		{Op: ChangeAdded, Path: "/new", New: []any{float64(1)}},
+		{Op: ChangeRemoved, Path: "/tags/1", Old: "b"},
+		{Op: ChangeChanged, Path: "/title", Old: "foo", New: "bar"},
+	}, changes)
+
+	changes, err = Diff([]any{1}, map[string]any{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{{Op: ChangeChanged, Old: []any{float64(1)}, New: map[string]any{}}}, changes)
+
+	changes, err = Diff(old, old)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestChangeJSON(t *testing.T) {
+	t.Parallel()
+	changes, err := Diff(map[string]any{"a": nil, "b": 1, "c": nil}, map[string]any{"a": 1, "b": nil, "d": nil})
+	if assert.NoError(t, err) {
+		data, err := json.Marshal(changes)
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, `[
+				{"op":"changed","path":"/a","old":null,"new":1},
+				{"op":"changed","path":"/b","old":1,"new":null},
+				{"op":"removed","path":"/c","old":null},
+				{"op":"added","path":"/d","new":null}
+			]`, string(data))
+		}
+	}
+}
+
+func TestChangeDetector(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	d := NewChangeDetector(NewCache())
+
+	changes, err := d.Detect(ctx, "page", map[string]any{"n": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{{Op: ChangeAdded, New: map[string]any{"n": float64(1)}}}, changes)
+
+	changes, err = d.Detect(ctx, "page", map[string]any{"n": 1})
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = d.Detect(ctx, "page", map[string]any{"n": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []Change{{Op: ChangeChanged, Path: "/n", Old: float64(1), New: float64(2)}}, changes)
+
+	changes, err = d.Detect(ctx, "other", map[string]any{"n": 2})
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+}
@@ -2,9 +2,13 @@ package ski
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 )
 
@@ -16,23 +20,142 @@ type Fetch interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
-// NewFetch return the http.Client implementation
+// FetchOptions the options of the http.Client created by NewFetchWithOptions.
+// The zero value fields use the default values.
+type FetchOptions struct {
+	// CAFile the PEM encoded CA certificates file, which are trusted in addition to the system pool.
+	CAFile string `yaml:"ca-file" json:"caFile"`
+	// CertFile and KeyFile the PEM encoded client certificate and private key for mTLS.
+	CertFile string `yaml:"cert-file" json:"certFile"`
+	KeyFile  string `yaml:"key-file" json:"keyFile"`
+	// MinTLSVersion the minimum TLS version, one of 1.0, 1.1, 1.2, 1.3, default is 1.2.
+	MinTLSVersion string `yaml:"min-tls-version" json:"minTLSVersion"`
+	// InsecureSkipVerify skips the server certificate verification, only for testing.
+	InsecureSkipVerify bool `yaml:"insecure-skip-verify" json:"insecureSkipVerify"`
+	// DisableHTTP2 disables the HTTP/2.
+	DisableHTTP2 bool `yaml:"disable-http2" json:"disableHTTP2"`
+	// MaxIdleConns the max idle connections of all hosts, default is 100.
+	MaxIdleConns int `yaml:"max-idle-conns" json:"maxIdleConns"`
+	// MaxIdleConnsPerHost the max idle connections of each host, default is 2.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host" json:"maxIdleConnsPerHost"`
+	// MaxConnsPerHost the max connections of each host, zero means unlimited.
+	MaxConnsPerHost int `yaml:"max-conns-per-host" json:"maxConnsPerHost"`
+	// DialTimeout the timeout of the connection established, default is 30s.
+	DialTimeout time.Duration `yaml:"dial-timeout" json:"dialTimeout"`
+	// TLSHandshakeTimeout the timeout of the TLS handshake, default is 10s.
+	TLSHandshakeTimeout time.Duration `yaml:"tls-handshake-timeout" json:"tlsHandshakeTimeout"`
+	// ResponseHeaderTimeout the timeout of waiting the response headers, zero means no timeout.
+	ResponseHeaderTimeout time.Duration `yaml:"response-header-timeout" json:"responseHeaderTimeout"`
+	// IdleConnTimeout the timeout of the idle connection closed, default is 90s.
+	IdleConnTimeout time.Duration `yaml:"idle-conn-timeout" json:"idleConnTimeout"`
+	// Timeout the timeout of each request including reading the body, zero means no timeout.
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// Redirect the redirect policy.
+	Redirect RedirectOptions `yaml:"redirect" json:"redirect"`
+}
+
+// NewFetch return the http.Client implementation,
+// which decodes the gzip, deflate, br and zstd response body.
 func NewFetch() Fetch {
+	fetch, _ := NewFetchWithOptions(FetchOptions{})
+	return fetch
+}
+
+// NewFetchWithOptions return the http.Client implementation with the FetchOptions,
+// which decodes the gzip, deflate, br and zstd response body.
+func NewFetchWithOptions(opt FetchOptions) (Fetch, error) {
+	opt = opt.withDefaults()
+	tlsConfig, err := opt.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy: ProxyFromRequest,
+		DialContext: (&net.Dialer{
+			Timeout:   opt.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !opt.DisableHTTP2,
+		MaxIdleConns:          opt.MaxIdleConns,
+		MaxIdleConnsPerHost:   opt.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       opt.MaxConnsPerHost,
+		IdleConnTimeout:       opt.IdleConnTimeout,
+		TLSHandshakeTimeout:   opt.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: opt.ResponseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if opt.DisableHTTP2 {
+		// the non-nil empty map disables the HTTP/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
 	return &http.Client{
-		Transport: &http.Transport{
-			Proxy: ProxyFromRequest,
-			DialContext: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-		Jar: NewCookieJar(),
+		Transport:     &decompressTransport{transport},
+		Jar:           NewCookieJar(),
+		Timeout:       opt.Timeout,
+		CheckRedirect: opt.Redirect.checkRedirect,
+	}, nil
+}
+
+func (opt FetchOptions) withDefaults() FetchOptions {
+	if opt.DialTimeout <= 0 {
+		opt.DialTimeout = 30 * time.Second
+	}
+	if opt.MaxIdleConns <= 0 {
+		opt.MaxIdleConns = 100
+	}
+	if opt.IdleConnTimeout <= 0 {
+		opt.IdleConnTimeout = 90 * time.Second
+	}
+	if opt.TLSHandshakeTimeout <= 0 {
+		opt.TLSHandshakeTimeout = 10 * time.Second
+	}
+	return opt
+}
+
+// tlsConfig returns the tls.Config of the options.
+func (opt FetchOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opt.InsecureSkipVerify, //nolint:gosec
+	}
+	switch opt.MinTLSVersion {
+	case "":
+	case "1.0":
+		config.MinVersion = tls.VersionTLS10
+	case "1.1":
+		config.MinVersion = tls.VersionTLS11
+	case "1.2":
+		config.MinVersion = tls.VersionTLS12
+	case "1.3":
+		config.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid min TLS version %q", opt.MinTLSVersion)
+	}
+
+	if opt.CAFile != "" {
+		pem, err := os.ReadFile(opt.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", opt.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if opt.CertFile != "" || opt.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opt.CertFile, opt.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
 	}
+	return config, nil
 }
 
 var requestProxyKey byte
@@ -49,9 +172,22 @@ func WithProxyURL(ctx context.Context, proxy *url.URL) context.Context {
 	return context.WithValue(ctx, &requestProxyKey, proxy)
 }
 
+// noProxy the proxy on context indicates the direct connection.
+var noProxy = new(url.URL)
+
+// WithoutProxy returns a copy of parent context in which the request
+// connects directly, such as bypasses the ProxyPool.
+func WithoutProxy(ctx context.Context) context.Context {
+	if c, ok := ctx.(Context); ok {
+		c.SetValue(&requestProxyKey, noProxy)
+		return c
+	}
+	return context.WithValue(ctx, &requestProxyKey, noProxy)
+}
+
 // ProxyFromContext returns a proxy URL on context.
 func ProxyFromContext(ctx context.Context) *url.URL {
-	if proxy := ctx.Value(&requestProxyKey); proxy != nil {
+	if proxy := ctx.Value(&requestProxyKey); proxy != nil && proxy != noProxy {
 		return proxy.(*url.URL)
 	}
 	return nil
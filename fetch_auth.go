@@ -0,0 +1,421 @@
+package ski
+
+import (
+	"crypto/md5" //nolint:gosec
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BasicAuthMiddleware returns the FetchMiddleware which sets the HTTP Basic
+// Authentication to the request if the request does not have the Authorization header.
+func BasicAuthMiddleware(username, password string) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				req = req.Clone(req.Context())
+				req.SetBasicAuth(username, password)
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// BearerAuthMiddleware returns the FetchMiddleware which sets the Bearer token
+// to the request if the request does not have the Authorization header.
+func BearerAuthMiddleware(token string) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// HostMiddleware returns the FetchMiddleware which applies the middleware only
+// to the requests of the hosts and their subdomains, such as limits the credentials
+// to be sent to the hosts. Without hosts the middleware is applied to all requests.
+func HostMiddleware(mw FetchMiddleware, hosts ...string) FetchMiddleware {
+	if len(hosts) == 0 {
+		return mw
+	}
+	return func(fetch Fetch) Fetch {
+		wrapped := mw(fetch)
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			host := req.URL.Hostname()
+			for _, h := range hosts {
+				if host == h || strings.HasSuffix(host, "."+h) {
+					return wrapped.Do(req)
+				}
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// DigestAuthMiddleware returns the FetchMiddleware of the HTTP Digest Access Authentication (RFC 7616).
+// The request is resent with the credentials when the server responds 401 with the Digest challenge,
+// the challenge of the host is reused by the subsequent requests.
+// Only the request with a replayable body can be resent.
+func DigestAuthMiddleware(username, password string) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		d := &digestAuth{
+			fetch:      fetch,
+			username:   username,
+			password:   password,
+			challenges: make(map[string]*digestChallenge),
+		}
+		return FetchFunc(d.Do)
+	}
+}
+
+type digestAuth struct {
+	fetch              Fetch
+	username, password string
+	mu                 sync.Mutex
+	challenges         map[string]*digestChallenge
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+	nc                                   int
+}
+
+// Do sends an HTTP request with the Digest authorization.
+func (d *digestAuth) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return d.fetch.Do(req)
+	}
+
+	original := req
+	if auth, ok := d.authorization(req); ok {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", auth)
+	}
+	res, err := d.fetch.Do(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	challenge, ok := parseDigestChallenge(res.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return res, nil
+	}
+	if original.Body != nil && original.Body != http.NoBody && original.GetBody == nil {
+		return res, nil
+	}
+	d.mu.Lock()
+	d.challenges[original.URL.Host] = challenge
+	d.mu.Unlock()
+
+	retry := original.Clone(original.Context())
+	if original.GetBody != nil {
+		body, err := original.GetBody()
+		if err != nil {
+			return res, nil //nolint:nilerr
+		}
+		retry.Body = body
+	}
+	auth, _ := d.authorization(retry)
+	retry.Header.Set("Authorization", auth)
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+	_ = res.Body.Close()
+	return d.fetch.Do(retry)
+}
+
+// authorization returns the Authorization header of the request with the challenge of the host.
+func (d *digestAuth) authorization(req *http.Request) (string, bool) {
+	d.mu.Lock()
+	c, ok := d.challenges[req.URL.Host]
+	if !ok {
+		d.mu.Unlock()
+		return "", false
+	}
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	challenge := *c
+	d.mu.Unlock()
+
+	h := digestHash(challenge.algorithm)
+	if h == nil {
+		return "", false
+	}
+	cnonce := make([]byte, 16)
+	_, _ = rand.Read(cnonce)
+	cnonceHex := hex.EncodeToString(cnonce)
+
+	uri := req.URL.RequestURI()
+	ha1 := digestHex(h, d.username+":"+challenge.realm+":"+d.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = digestHex(h, ha1+":"+challenge.nonce+":"+cnonceHex)
+	}
+	ha2 := digestHex(h, req.Method+":"+uri)
+
+	var response string
+	if challenge.qop != "" {
+		response = digestHex(h, strings.Join([]string{ha1, challenge.nonce, nc, cnonceHex, challenge.qop, ha2}, ":"))
+	} else {
+		response = digestHex(h, ha1+":"+challenge.nonce+":"+ha2)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username=%q, realm=%q, nonce=%q, uri=%q, response=%q`,
+		d.username, challenge.realm, challenge.nonce, uri, response)
+	if challenge.algorithm != "" {
+		fmt.Fprintf(&sb, ", algorithm=%s", challenge.algorithm)
+	}
+	if challenge.qop != "" {
+		fmt.Fprintf(&sb, `, qop=%s, nc=%s, cnonce=%q`, challenge.qop, nc, cnonceHex)
+	}
+	if challenge.opaque != "" {
+		fmt.Fprintf(&sb, `, opaque=%q`, challenge.opaque)
+	}
+	return sb.String(), true
+}
+
+// parseDigestChallenge returns the Digest challenge of the WWW-Authenticate headers.
+func parseDigestChallenge(values []string) (*digestChallenge, bool) {
+	for _, v := range values {
+		scheme, params, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		c := new(digestChallenge)
+		for k, v := range parseAuthParams(params) {
+			switch k {
+			case "realm":
+				c.realm = v
+			case "nonce":
+				c.nonce = v
+			case "opaque":
+				c.opaque = v
+			case "algorithm":
+				c.algorithm = v
+			case "qop":
+				// only the auth is supported
+				for _, qop := range strings.Split(v, ",") {
+					if strings.TrimSpace(qop) == "auth" {
+						c.qop = "auth"
+					}
+				}
+			}
+		}
+		if c.nonce == "" || digestHash(c.algorithm) == nil {
+			continue
+		}
+		return c, true
+	}
+	return nil, false
+}
+
+// parseAuthParams parses the comma separated auth-params, the value may be quoted.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " \t,")
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			v, r, _ := strings.Cut(rest, ",")
+			value.WriteString(strings.TrimSpace(v))
+			s = r
+		}
+		params[key] = value.String()
+	}
+	return params
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	default:
+		return nil
+	}
+}
+
+func digestHex(h func() hash.Hash, s string) string {
+	w := h()
+	_, _ = io.WriteString(w, s)
+	return hex.EncodeToString(w.Sum(nil))
+}
+
+// OAuth2Options the OAuth2 client credentials grant options.
+type OAuth2Options struct {
+	// TokenURL the token endpoint of the authorization server.
+	TokenURL string `yaml:"token-url" json:"tokenURL"`
+	// ClientID the client identifier.
+	ClientID string `yaml:"client-id" json:"clientID"`
+	// ClientSecret the client secret.
+	ClientSecret string `yaml:"client-secret" json:"clientSecret"`
+	// Scopes the scopes of the access token.
+	Scopes []string `yaml:"scopes" json:"scopes"`
+	// Cache stores the access token until it expires, such as shared by the runs, default is in memory.
+	Cache Cache `yaml:"-" json:"-"`
+}
+
+// oauth2ExpiryDelta the access token is refreshed before it expires.
+const oauth2ExpiryDelta = 30 * time.Second
+
+// OAuth2Middleware returns the FetchMiddleware of the OAuth2 client credentials grant (RFC 6749 4.4).
+// The access token is requested with the Fetch and stored in the Cache, it is refreshed before expiry,
+// or when the server responds 401 with the replayable request.
+func OAuth2Middleware(opt OAuth2Options) FetchMiddleware {
+	if opt.Cache == nil {
+		opt.Cache = NewCache()
+	}
+	return func(fetch Fetch) Fetch {
+		o := &oauth2Auth{fetch: fetch, opt: opt}
+		return FetchFunc(o.Do)
+	}
+}
+
+type oauth2Auth struct {
+	fetch Fetch
+	opt   OAuth2Options
+	mu    sync.Mutex
+}
+
+type oauth2Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in,omitempty"`
+	Expiry      int64  `json:"expiry,omitempty"` // unix seconds, zero means never expire
+}
+
+// Do sends an HTTP request with the access token.
+func (o *oauth2Auth) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return o.fetch.Do(req)
+	}
+	token, err := o.token(req, false)
+	if err != nil {
+		return nil, err
+	}
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", token)
+	res, err := o.fetch.Do(authed)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return res, nil
+	}
+
+	// the token may be revoked, request a new one
+	if token, err = o.token(req, true); err != nil {
+		return res, nil //nolint:nilerr
+	}
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return res, nil //nolint:nilerr
+		}
+	}
+	retry.Header.Set("Authorization", token)
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+	_ = res.Body.Close()
+	return o.fetch.Do(retry)
+}
+
+// token returns the Authorization header value of the access token.
+func (o *oauth2Auth) token(req *http.Request, refresh bool) (string, error) {
+	ctx := req.Context()
+	key := "oauth2:" + o.opt.TokenURL + " " + o.opt.ClientID + " " + strings.Join(o.opt.Scopes, " ")
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var token oauth2Token
+	if !refresh {
+		if data, err := o.opt.Cache.Get(ctx, key); err == nil && len(data) > 0 &&
+			json.Unmarshal(data, &token) == nil && token.AccessToken != "" &&
+			(token.Expiry == 0 || time.Now().Add(oauth2ExpiryDelta).Unix() < token.Expiry) {
+			return token.header(), nil
+		}
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.opt.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.opt.Scopes, " "))
+	}
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.opt.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	tokenReq.SetBasicAuth(url.QueryEscape(o.opt.ClientID), url.QueryEscape(o.opt.ClientSecret))
+
+	res, err := o.fetch.Do(tokenReq)
+	if err != nil {
+		return "", fmt.Errorf("oauth2: request token failed: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("oauth2: request token failed: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("oauth2: request token failed: %s %s", res.Status, body)
+	}
+	token = oauth2Token{}
+	if err = json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("oauth2: server response missing access_token")
+	}
+
+	if token.ExpiresIn > 0 {
+		token.Expiry = time.Now().Unix() + token.ExpiresIn
+		ctx = withCacheTimeout(ctx, time.Duration(token.ExpiresIn)*time.Second)
+	}
+	token.ExpiresIn = 0
+	if data, err := json.Marshal(token); err == nil {
+		if err = o.opt.Cache.Set(ctx, key, data); err != nil {
+			Logger(ctx).Warn(fmt.Sprintf("oauth2 token cache failed: %s", err), fetchSubsystem)
+		}
+	}
+	return token.header(), nil
+}
+
+// header returns the Authorization header value.
+func (t oauth2Token) header() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
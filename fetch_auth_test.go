@@ -0,0 +1,190 @@
+package ski
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAndBearerAuth(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	do := func(fetch Fetch, url string, header string) string {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		res, err := fetch.Do(req)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+
+	basic := BasicAuthMiddleware("user", "pass")(http.DefaultClient)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", do(basic, ts.URL, ""))
+	assert.Equal(t, "Token foo", do(basic, ts.URL, "Token foo"))
+
+	bearer := BearerAuthMiddleware("token")(http.DefaultClient)
+	assert.Equal(t, "Bearer token", do(bearer, ts.URL, ""))
+
+	host := HostMiddleware(BearerAuthMiddleware("token"), "example.com")(http.DefaultClient)
+	assert.Equal(t, "", do(host, ts.URL, ""))
+	host = HostMiddleware(BearerAuthMiddleware("token"), "127.0.0.1")(http.DefaultClient)
+	assert.Equal(t, "Bearer token", do(host, ts.URL, ""))
+}
+
+func TestDigestAuth(t *testing.T) {
+	t.Parallel()
+	const realm, nonce, opaque = "ski", "dcd98b7102dd2f0e8b11d0f600bfb0c093", "5ccc069c403ebaf9f0171e9517f40e41"
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s)) //nolint:gosec
+		return hex.EncodeToString(sum[:])
+	}
+	var challenges atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		scheme, params, _ := strings.Cut(auth, " ")
+		p := parseAuthParams(params)
+		ha1 := h("user:" + realm + ":pass")
+		ha2 := h(r.Method + ":" + p["uri"])
+		expected := h(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+		if scheme != "Digest" || p["response"] != expected || p["opaque"] != opaque || p["uri"] != r.URL.RequestURI() {
+			challenges.Add(1)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth,auth-int", nonce=%q, opaque=%q`, realm, nonce, opaque))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = fmt.Fprintf(w, "%s %s", p["nc"], body)
+	}))
+	defer ts.Close()
+
+	fetch := DigestAuthMiddleware("user", "pass")(http.DefaultClient)
+	do := func(path, body string) (int, string) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, ts.URL+path, strings.NewReader(body))
+		res, err := fetch.Do(req)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(data)
+	}
+
+	status, body := do("/a?b=c", "foo")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "00000001 foo", body)
+	assert.EqualValues(t, 1, challenges.Load())
+
+	// the challenge is reused
+	status, body = do("/d", "bar")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "00000002 bar", body)
+	assert.EqualValues(t, 1, challenges.Load())
+
+	wrong := DigestAuthMiddleware("user", "wrong")(http.DefaultClient)
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+	res, err := wrong.Do(req)
+	if assert.NoError(t, err) {
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	}
+}
+
+func TestOAuth2Auth(t *testing.T) {
+	t.Parallel()
+	var issued, revoked atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			_ = r.ParseForm()
+			if id != "client" || secret != "secret" || r.PostForm.Get("grant_type") != "client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = io.WriteString(w, `{"error":"invalid_client"}`)
+				return
+			}
+			n := issued.Add(1)
+			expires := 3600
+			if r.PostForm.Get("scope") == "short" {
+				expires = 10 // less than the expiry delta
+			}
+			_, _ = fmt.Fprintf(w, `{"access_token":"token%d","token_type":"bearer","expires_in":%d}`, n, expires)
+		case "/revoke":
+			revoked.Store(issued.Load())
+		default:
+			auth := r.Header.Get("Authorization")
+			if auth == "" || auth == fmt.Sprintf("Bearer token%d", revoked.Load()) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = io.WriteString(w, auth)
+		}
+	}))
+	defer ts.Close()
+
+	do := func(fetch Fetch, path string) (int, string) {
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+path, nil)
+		res, err := fetch.Do(req)
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(data)
+	}
+
+	cache := NewCache()
+	opt := OAuth2Options{TokenURL: ts.URL + "/token", ClientID: "client", ClientSecret: "secret", Cache: cache}
+	fetch := OAuth2Middleware(opt)(http.DefaultClient)
+
+	_, body := do(fetch, "/")
+	assert.Equal(t, "Bearer token1", body)
+	_, body = do(fetch, "/")
+	assert.Equal(t, "Bearer token1", body)
+
+	// the token is shared by the cache
+	_, body = do(OAuth2Middleware(opt)(http.DefaultClient), "/")
+	assert.Equal(t, "Bearer token1", body)
+
+	// the revoked token is refreshed
+	_, _ = do(http.DefaultClient, "/revoke")
+	_, body = do(fetch, "/")
+	assert.Equal(t, "Bearer token2", body)
+	assert.EqualValues(t, 2, issued.Load())
+
+	// the token is refreshed before expiry
+	short := OAuth2Middleware(OAuth2Options{TokenURL: opt.TokenURL, ClientID: "client", ClientSecret: "secret", Scopes: []string{"short"}, Cache: cache})(http.DefaultClient)
+	_, body = do(short, "/")
+	assert.Equal(t, "Bearer token3", body)
+	_, body = do(short, "/")
+	assert.Equal(t, "Bearer token4", body)
+
+	_, body = do(OAuth2Middleware(OAuth2Options{TokenURL: opt.TokenURL, ClientID: "client", ClientSecret: "wrong"})(http.DefaultClient), "/")
+	assert.Contains(t, body, "invalid_client")
+
+	// the token expiry is not set to the shared context
+	ctx := NewContext(context.Background(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	res, err := OAuth2Middleware(OAuth2Options{TokenURL: opt.TokenURL, ClientID: "client", ClientSecret: "secret", Scopes: []string{"shared"}, Cache: cache})(http.DefaultClient).Do(req)
+	if assert.NoError(t, err) {
+		_ = res.Body.Close()
+	}
+	assert.Zero(t, CacheTimeout(ctx))
+}
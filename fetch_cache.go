@@ -0,0 +1,283 @@
+package ski
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCacheMiss the response is not in the cache when offline.
+var ErrCacheMiss = errors.New("response not in cache")
+
+const (
+	// cacheStoredHeader the internal header records the time of the response stored.
+	cacheStoredHeader = "X-Ski-Cache-Stored"
+	// FromCacheHeader the header is set to the response which served from the cache.
+	FromCacheHeader = "X-From-Cache"
+)
+
+// cacheableStatus the status codes which the response can be stored.
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMultipleChoices,
+	http.StatusMovedPermanently, http.StatusPermanentRedirect,
+	http.StatusNotFound, http.StatusGone,
+}
+
+// CacheOptions the options of the HTTP cache Fetch.
+type CacheOptions struct {
+	// Offline serves the cached responses regardless of the freshness and
+	// never sends requests, returns ErrCacheMiss if the response not in the cache.
+	// A request with the Cache-Control: only-if-cached header is always offline.
+	Offline bool `yaml:"offline" json:"offline"`
+	// Timeout the stored responses timeout of the Cache, zero means never expire.
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// NewCacheFetch returns a Fetch which stores the GET and HEAD responses in the Cache.
+// It respects the Cache-Control, Expires of the response to serve the fresh responses,
+// and revalidates the stale responses with the ETag and Last-Modified.
+// The Cache-Control of the request no-store, no-cache, max-age and only-if-cached are supported.
+// The Vary header is not supported, the response with Vary: * is not stored.
+// The requests with the different Authorization or Cookie headers are stored separately.
+func NewCacheFetch(fetch Fetch, cache Cache, opt CacheOptions) Fetch {
+	return &cacheFetch{fetch, cache, opt}
+}
+
+type cacheFetch struct {
+	fetch Fetch
+	cache Cache
+	opt   CacheOptions
+}
+
+// Do sends an HTTP request and returns an HTTP response, serves from the cache if available.
+func (c *cacheFetch) Do(req *http.Request) (*http.Response, error) {
+	reqCC := parseCacheControl(req.Header)
+	_, onlyIfCached := reqCC["only-if-cached"]
+	offline := c.opt.Offline || onlyIfCached
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		if offline {
+			return nil, fmt.Errorf("%w: %s %s", ErrCacheMiss, req.Method, req.URL)
+		}
+		return c.fetch.Do(req)
+	}
+	if _, ok := reqCC["no-store"]; ok && !offline {
+		return c.fetch.Do(req)
+	}
+
+	ctx := req.Context()
+	cached, stored, err := c.load(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if offline {
+		if cached == nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrCacheMiss, req.Method, req.URL)
+		}
+		return cached, nil
+	}
+
+	if cached == nil {
+		res, err := c.fetch.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.store(ctx, req, res)
+	}
+
+	if fresh(cached, stored, reqCC) {
+		return cached, nil
+	}
+
+	etag := cached.Header.Get("ETag")
+	lastModified := cached.Header.Get("Last-Modified")
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	if conditional || (etag == "" && lastModified == "") {
+		_ = cached.Body.Close()
+		res, err := c.fetch.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		return c.store(ctx, req, res)
+	}
+
+	revalidate := req.Clone(ctx)
+	if etag != "" {
+		revalidate.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		revalidate.Header.Set("If-Modified-Since", lastModified)
+	}
+	res, err := c.fetch.Do(revalidate)
+	if err != nil {
+		_ = cached.Body.Close()
+		return nil, err
+	}
+	if res.StatusCode != http.StatusNotModified {
+		_ = cached.Body.Close()
+		return c.store(ctx, req, res)
+	}
+
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+	// update the stored response with the new headers
+	for k, v := range res.Header {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		cached.Header[k] = v
+	}
+	cached.Header.Del(FromCacheHeader)
+	res, err = c.store(ctx, req, cached)
+	if err != nil {
+		return nil, err
+	}
+	res.Header.Set(FromCacheHeader, "1")
+	return res, nil
+}
+
+// load returns the stored response and its stored time, or nil if not in the cache.
+func (c *cacheFetch) load(ctx context.Context, req *http.Request) (*http.Response, time.Time, error) {
+	data, err := c.cache.Get(ctx, cacheKey(req))
+	if err != nil || len(data) == 0 {
+		return nil, time.Time{}, err
+	}
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		// the stored response is corrupted, ignore it
+		return nil, time.Time{}, nil //nolint:nilerr
+	}
+	stored, _ := strconv.ParseInt(res.Header.Get(cacheStoredHeader), 10, 64)
+	res.Header.Del(cacheStoredHeader)
+	res.Header.Set(FromCacheHeader, "1")
+	return res, time.Unix(stored, 0), nil
+}
+
+// store the response to the cache if it is cacheable, returns the response with the read body.
+func (c *cacheFetch) store(ctx context.Context, req *http.Request, res *http.Response) (*http.Response, error) {
+	resCC := parseCacheControl(res.Header)
+	if _, ok := resCC["no-store"]; ok ||
+		!slices.Contains(cacheableStatus, res.StatusCode) ||
+		res.Header.Get("Vary") == "*" {
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.TransferEncoding = nil
+
+	clone := *res
+	clone.Header = res.Header.Clone()
+	clone.Header.Set(cacheStoredHeader, strconv.FormatInt(time.Now().Unix(), 10))
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	data, err := httputil.DumpResponse(&clone, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opt.Timeout > 0 {
+		ctx = withCacheTimeout(ctx, c.opt.Timeout)
+	}
+	if err = c.cache.Set(ctx, cacheKey(req), data); err != nil {
+		Logger(ctx).Warn(fmt.Sprintf("http cache store failed: %s", err), fetchSubsystem)
+	}
+	return res, nil
+}
+
+// cacheKeyHeaders the credential headers of the request are part of the cache key.
+var cacheKeyHeaders = []string{"Authorization", "Cookie"}
+
+// cacheKey returns the cache key of the request, the credential headers are hashed
+// into the key so the responses of the different credentials are not shared.
+func cacheKey(req *http.Request) string {
+	key := "http:" + req.Method + " " + req.URL.String()
+	var h hash.Hash
+	for _, name := range cacheKeyHeaders {
+		for _, v := range req.Header.Values(name) {
+			if h == nil {
+				h = sha256.New()
+			}
+			_, _ = fmt.Fprintf(h, "%s: %s\n", name, v)
+		}
+	}
+	if h == nil {
+		return key
+	}
+	return key + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// fresh reports whether the stored response is fresh for the request.
+func fresh(res *http.Response, stored time.Time, reqCC map[string]string) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	resCC := parseCacheControl(res.Header)
+	if _, ok := resCC["no-cache"]; ok {
+		return false
+	}
+
+	age := time.Since(stored)
+	if v, err := strconv.Atoi(res.Header.Get("Age")); err == nil && v > 0 {
+		age += time.Duration(v) * time.Second
+	}
+	if v, ok := reqCC["max-age"]; ok {
+		if seconds, err := strconv.Atoi(v); err == nil && age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+
+	var lifetime time.Duration
+	if v, ok := resCC["max-age"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if v := res.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return false
+		}
+		date := stored
+		if v, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+			date = v
+		}
+		lifetime = expires.Sub(date)
+	}
+	return age < lifetime
+}
+
+// parseCacheControl parses the Cache-Control header directives.
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			k, v, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+	}
+	return cc
+}
@@ -0,0 +1,148 @@
+package ski
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheFetch(t *testing.T) {
+	t.Parallel()
+	var count atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	cache := NewCache()
+	fetch := NewCacheFetch(http.DefaultClient, cache, CacheOptions{})
+
+	do := func(fetch Fetch, path string, header ...string) (*http.Response, string, error) {
+		count.Store(0)
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		res, err := fetch.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return res, string(body), err
+	}
+
+	t.Run("max-age", func(t *testing.T) {
+		res, body, err := do(fetch, "/max-age")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/max-age", body)
+			assert.Empty(t, res.Header.Get(FromCacheHeader))
+		}
+		res, body, err = do(fetch, "/max-age")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/max-age", body)
+			assert.Equal(t, "1", res.Header.Get(FromCacheHeader))
+			assert.EqualValues(t, 0, count.Load())
+		}
+		_, _, err = do(fetch, "/max-age", "Cache-Control", "no-cache")
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, 1, count.Load())
+		}
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		_, _, err := do(fetch, "/etag")
+		assert.NoError(t, err)
+		res, body, err := do(fetch, "/etag")
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "/etag", body)
+			assert.Equal(t, "1", res.Header.Get(FromCacheHeader))
+			assert.EqualValues(t, 1, count.Load())
+		}
+	})
+
+	t.Run("no-store", func(t *testing.T) {
+		_, _, err := do(fetch, "/no-store")
+		assert.NoError(t, err)
+		_, _, err = do(fetch, "/no-store")
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, 1, count.Load())
+		}
+		_, _, err = do(fetch, "/no-store", "Cache-Control", "only-if-cached")
+		assert.ErrorIs(t, err, ErrCacheMiss)
+	})
+
+	t.Run("credentials", func(t *testing.T) {
+		_, _, err := do(fetch, "/max-age", "Authorization", "Bearer foo")
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, 1, count.Load())
+		}
+		res, _, err := do(fetch, "/max-age", "Authorization", "Bearer bar")
+		if assert.NoError(t, err) {
+			assert.Empty(t, res.Header.Get(FromCacheHeader))
+			assert.EqualValues(t, 1, count.Load())
+		}
+		res, _, err = do(fetch, "/max-age", "Authorization", "Bearer foo")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "1", res.Header.Get(FromCacheHeader))
+			assert.EqualValues(t, 0, count.Load())
+		}
+		res, _, err = do(fetch, "/max-age", "Cookie", "session=foo")
+		if assert.NoError(t, err) {
+			assert.Empty(t, res.Header.Get(FromCacheHeader))
+			assert.EqualValues(t, 1, count.Load())
+		}
+	})
+
+	t.Run("offline", func(t *testing.T) {
+		offline := NewCacheFetch(http.DefaultClient, cache, CacheOptions{Offline: true})
+		_, body, err := do(offline, "/etag")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/etag", body)
+			assert.EqualValues(t, 0, count.Load())
+		}
+		_, _, err = do(offline, "/not-cached")
+		assert.ErrorIs(t, err, ErrCacheMiss)
+		assert.EqualValues(t, 0, count.Load())
+	})
+}
+
+func TestCacheFetchTimeout(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	fetch := NewCacheFetch(http.DefaultClient, NewCache(), CacheOptions{Timeout: time.Hour})
+	ctx := NewContext(context.Background(), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	res, err := fetch.Do(req)
+	if assert.NoError(t, err) {
+		_ = res.Body.Close()
+	}
+	// the timeout of the http cache is not set to the shared context
+	assert.Zero(t, CacheTimeout(ctx))
+}
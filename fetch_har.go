@@ -0,0 +1,488 @@
+package ski
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrHARNotFound the request is not found in the HAR when replaying.
+var ErrHARNotFound = errors.New("request not found in HAR")
+
+type (
+	// HAR the HTTP Archive format 1.2, the required fields are defined.
+	// http://www.softwareishard.com/blog/har-12-spec/
+	HAR struct {
+		Log HARLog `json:"log"`
+	}
+
+	// HARLog the root of the HAR.
+	HARLog struct {
+		Version string      `json:"version"`
+		Creator HARCreator  `json:"creator"`
+		Entries []*HAREntry `json:"entries"`
+	}
+
+	// HARCreator the creator application of the HAR.
+	HARCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	// HAREntry an exported HTTP request.
+	HAREntry struct {
+		StartedDateTime time.Time   `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         HARRequest  `json:"request"`
+		Response        HARResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         HARTimings  `json:"timings"`
+	}
+
+	// HARRequest the detailed info about the request.
+	HARRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []HARNameValue `json:"cookies"`
+		Headers     []HARNameValue `json:"headers"`
+		QueryString []HARNameValue `json:"queryString"`
+		PostData    *HARPostData   `json:"postData,omitempty"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	// HARResponse the detailed info about the response.
+	HARResponse struct {
+		Status      int            `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []HARNameValue `json:"cookies"`
+		Headers     []HARNameValue `json:"headers"`
+		Content     HARContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int            `json:"headersSize"`
+		BodySize    int            `json:"bodySize"`
+	}
+
+	// HARNameValue the name and value pair of the headers, cookies and query string.
+	HARNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	// HARPostData the posted data of the request.
+	HARPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+
+	// HARContent the content of the response, the binary content is base64 encoded.
+	HARContent struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Encoding string `json:"encoding,omitempty"`
+	}
+
+	// HARTimings the timings of the request, the values are in milliseconds.
+	HARTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
+
+// ReadHAR reads the HAR from the reader.
+func ReadHAR(r io.Reader) (*HAR, error) {
+	har := new(HAR)
+	if err := json.NewDecoder(r).Decode(har); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	return har, nil
+}
+
+// LoadHAR reads the HAR from the file.
+func LoadHAR(path string) (*HAR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadHAR(f)
+}
+
+// WriteTo writes the HAR JSON to the writer.
+func (h *HAR) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Save writes the HAR to the file.
+func (h *HAR) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = h.WriteTo(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HARRecorder is a Fetch which records the requests and responses to the HAR.
+type HARRecorder struct {
+	fetch   Fetch
+	opt     HARRecorderOptions
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+// HARRecorderOptions the options of the HARRecorder.
+type HARRecorderOptions struct {
+	// RedactHeaders the additional headers to redact,
+	// the credentials headers and the cookies are always redacted.
+	RedactHeaders []string `yaml:"redact-headers" json:"redactHeaders"`
+	// Unredacted records the credentials headers and the cookies in plain text.
+	Unredacted bool `yaml:"unredacted" json:"unredacted"`
+}
+
+// harRedacted the value of the redacted header or cookie.
+const harRedacted = "REDACTED"
+
+// harRedactHeaders the headers contain the credentials.
+var harRedactHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+	"X-Api-Key", "X-Auth-Token", "X-Csrf-Token",
+}
+
+// NewHARRecorder returns a HARRecorder which sends the requests with the Fetch.
+// The values of the credentials headers such as Authorization and the cookies
+// are redacted unless the HARRecorderOptions.Unredacted.
+func NewHARRecorder(fetch Fetch, opt HARRecorderOptions) *HARRecorder {
+	return &HARRecorder{fetch: fetch, opt: opt}
+}
+
+// Do sends an HTTP request and returns an HTTP response, records the request and response.
+func (r *HARRecorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	res, err := r.fetch.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	wait := time.Since(start)
+
+	resBody, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	total := time.Since(start)
+
+	entry := &HAREntry{
+		StartedDateTime: start,
+		Time:            milliseconds(total),
+		Request:         harRequest(req, reqBody),
+		Response:        harResponse(res, resBody),
+		Timings:         HARTimings{Wait: milliseconds(wait), Receive: milliseconds(total - wait)},
+	}
+	if !r.opt.Unredacted {
+		r.redact(entry)
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+	return res, nil
+}
+
+// redact replaces the values of the credentials headers and the cookies,
+// the scheme of the Authorization such as Bearer is kept.
+func (r *HARRecorder) redact(entry *HAREntry) {
+	headers := func(headers []HARNameValue) {
+		for i, h := range headers {
+			if !slices.ContainsFunc(harRedactHeaders, func(name string) bool { return strings.EqualFold(name, h.Name) }) &&
+				!slices.ContainsFunc(r.opt.RedactHeaders, func(name string) bool { return strings.EqualFold(name, h.Name) }) {
+				continue
+			}
+			switch strings.ToLower(h.Name) {
+			case "authorization", "proxy-authorization":
+				if scheme, _, ok := strings.Cut(h.Value, " "); ok {
+					headers[i].Value = scheme + " " + harRedacted
+					continue
+				}
+			case "set-cookie":
+				if name, _, ok := strings.Cut(h.Value, "="); ok {
+					headers[i].Value = name + "=" + harRedacted
+					continue
+				}
+			}
+			headers[i].Value = harRedacted
+		}
+	}
+	cookies := func(cookies []HARNameValue) {
+		for i := range cookies {
+			cookies[i].Value = harRedacted
+		}
+	}
+	headers(entry.Request.Headers)
+	cookies(entry.Request.Cookies)
+	headers(entry.Response.Headers)
+	cookies(entry.Response.Cookies)
+}
+
+// HAR returns the HAR of the recorded entries.
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &HAR{HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "ski"},
+		Entries: append(make([]*HAREntry, 0, len(r.entries)), r.entries...),
+	}}
+}
+
+// HARReplayOptions the request matching options of the HAR replay Fetch,
+// the zero value matches the method and URL.
+type HARReplayOptions struct {
+	// IgnoreMethod matches the request regardless of the method.
+	IgnoreMethod bool `yaml:"ignore-method" json:"ignoreMethod"`
+	// IgnoreQuery matches the URL without the query string.
+	IgnoreQuery bool `yaml:"ignore-query" json:"ignoreQuery"`
+	// MatchBody matches the request body also.
+	MatchBody bool `yaml:"match-body" json:"matchBody"`
+	// Fallback the Fetch sends the unmatched requests, nil returns ErrHARNotFound.
+	Fallback Fetch `yaml:"-" json:"-"`
+}
+
+// NewHARReplay returns a Fetch which serves the responses of the HAR entries
+// that match the request. The entries of the same request are served in the
+// recorded order, and the last one is repeated.
+func NewHARReplay(har *HAR, opt HARReplayOptions) Fetch {
+	r := &harReplay{opt: opt, entries: make(map[string][]*HAREntry), served: make(map[string]int)}
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			continue
+		}
+		var body string
+		if entry.Request.PostData != nil {
+			body = entry.Request.PostData.Text
+		}
+		key := r.key(entry.Request.Method, u, body)
+		r.entries[key] = append(r.entries[key], entry)
+	}
+	return r
+}
+
+type harReplay struct {
+	opt     HARReplayOptions
+	mu      sync.Mutex
+	entries map[string][]*HAREntry
+	served  map[string]int
+}
+
+// Do returns the recorded HTTP response of the request.
+func (r *harReplay) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if r.opt.MatchBody {
+		var err error
+		if body, err = requestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	key := r.key(req.Method, req.URL, string(body))
+	r.mu.Lock()
+	entries := r.entries[key]
+	if len(entries) == 0 {
+		r.mu.Unlock()
+		if r.opt.Fallback != nil {
+			return r.opt.Fallback.Do(req)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrHARNotFound, req.Method, req.URL)
+	}
+	i := min(r.served[key], len(entries)-1)
+	r.served[key]++
+	entry := entries[i]
+	r.mu.Unlock()
+
+	return entry.Response.toResponse(req)
+}
+
+// key returns the matching key of the request.
+func (r *harReplay) key(method string, u *url.URL, body string) string {
+	var sb strings.Builder
+	if !r.opt.IgnoreMethod {
+		sb.WriteString(strings.ToUpper(method))
+	}
+	sb.WriteByte(' ')
+	c := *u
+	c.Fragment = ""
+	if r.opt.IgnoreQuery {
+		c.RawQuery = ""
+	}
+	sb.WriteString(c.String())
+	if r.opt.MatchBody {
+		sb.WriteByte(' ')
+		sb.WriteString(body)
+	}
+	return sb.String()
+}
+
+// toResponse returns the HTTP response of the recorded response.
+func (h *HARResponse) toResponse(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if h.Content.Encoding == "base64" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(h.Content.Text); err != nil {
+			return nil, fmt.Errorf("invalid HAR content: %w", err)
+		}
+	} else {
+		body = []byte(h.Content.Text)
+	}
+
+	header := make(http.Header, len(h.Headers))
+	for _, nv := range h.Headers {
+		switch http.CanonicalHeaderKey(nv.Name) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			// the content is stored decoded
+			continue
+		}
+		header.Add(nv.Name, nv.Value)
+	}
+
+	proto := h.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", h.Status, h.StatusText),
+		StatusCode:    h.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestBody reads the request body and replaces it with a replayable one.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
+
+func harRequest(req *http.Request, body []byte) HARRequest {
+	ret := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     harHeaders(req.Header),
+		QueryString: make([]HARNameValue, 0),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if ret.HTTPVersion == "" {
+		ret.HTTPVersion = "HTTP/1.1"
+	}
+	for _, c := range req.Cookies() {
+		ret.Cookies = append(ret.Cookies, HARNameValue{c.Name, c.Value})
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			ret.QueryString = append(ret.QueryString, HARNameValue{k, v})
+		}
+	}
+	if body != nil {
+		ret.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return ret
+}
+
+func harResponse(res *http.Response, body []byte) HARResponse {
+	ret := HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(res.Status, fmt.Sprint(res.StatusCode))),
+		HTTPVersion: res.Proto,
+		Cookies:     make([]HARNameValue, 0),
+		Headers:     harHeaders(res.Header),
+		Content: HARContent{
+			Size:     len(body),
+			MimeType: res.Header.Get("Content-Type"),
+		},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for _, c := range res.Cookies() {
+		ret.Cookies = append(ret.Cookies, HARNameValue{c.Name, c.Value})
+	}
+	if utf8.Valid(body) {
+		ret.Content.Text = string(body)
+	} else {
+		ret.Content.Text = base64.StdEncoding.EncodeToString(body)
+		ret.Content.Encoding = "base64"
+	}
+	return ret
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	ret := make([]HARNameValue, 0, len(header))
+	for k, vs := range header {
+		for _, v := range vs {
+			ret = append(ret, HARNameValue{k, v})
+		}
+	}
+	slices.SortStableFunc(ret, func(a, b HARNameValue) int { return cmp.Compare(a.Name, b.Name) })
+	return ret
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,167 @@
+package ski
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARFetch(t *testing.T) {
+	t.Parallel()
+	var count atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := count.Add(1)
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0xff, 0xfe, 0x00})
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write(body)
+		default:
+			w.Header().Set("X-Count", string(rune('0'+n)))
+			_, _ = io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+		}
+	}))
+	defer ts.Close()
+
+	do := func(fetch Fetch, method, path, body string) (*http.Response, string, error) {
+		req, _ := http.NewRequestWithContext(context.Background(), method, ts.URL+path, strings.NewReader(body))
+		res, err := fetch.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		return res, string(data), err
+	}
+
+	recorder := NewHARRecorder(http.DefaultClient, HARRecorderOptions{})
+	for _, r := range [][3]string{
+		{http.MethodGet, "/page?p=1", ""},
+		{http.MethodGet, "/page?p=1", ""},
+		{http.MethodGet, "/binary", ""},
+		{http.MethodPost, "/echo", "foo"},
+		{http.MethodPost, "/echo", "bar"},
+	} {
+		_, body, err := do(recorder, r[0], r[1], r[2])
+		if assert.NoError(t, err) && r[1] == "/echo" {
+			assert.Equal(t, r[2], body)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	_, err := recorder.HAR().WriteTo(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	har, err := ReadHAR(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, har.Log.Entries, 5)
+	count.Store(0)
+
+	t.Run("replay", func(t *testing.T) {
+		replay := NewHARReplay(har, HARReplayOptions{})
+		res, body, err := do(replay, http.MethodGet, "/page?p=1", "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "GET /page?p=1", body)
+			assert.Equal(t, "1", res.Header.Get("X-Count"))
+		}
+		// served in the recorded order, the last one is repeated
+		for i := 0; i < 2; i++ {
+			res, _, err = do(replay, http.MethodGet, "/page?p=1", "")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "2", res.Header.Get("X-Count"))
+			}
+		}
+		_, body, err = do(replay, http.MethodGet, "/binary", "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, string([]byte{0xff, 0xfe, 0x00}), body)
+		}
+		_, _, err = do(replay, http.MethodHead, "/page?p=1", "")
+		assert.ErrorIs(t, err, ErrHARNotFound)
+		_, _, err = do(replay, http.MethodGet, "/page?p=2", "")
+		assert.ErrorIs(t, err, ErrHARNotFound)
+		assert.EqualValues(t, 0, count.Load())
+	})
+
+	t.Run("match", func(t *testing.T) {
+		replay := NewHARReplay(har, HARReplayOptions{IgnoreMethod: true, IgnoreQuery: true})
+		_, body, err := do(replay, http.MethodHead, "/page?p=2", "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "GET /page?p=1", body)
+		}
+
+		replay = NewHARReplay(har, HARReplayOptions{MatchBody: true})
+		_, body, err = do(replay, http.MethodPost, "/echo", "bar")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "bar", body)
+		}
+		_, _, err = do(replay, http.MethodPost, "/echo", "baz")
+		assert.ErrorIs(t, err, ErrHARNotFound)
+
+		replay = NewHARReplay(har, HARReplayOptions{Fallback: http.DefaultClient})
+		_, body, err = do(replay, http.MethodGet, "/page?p=2", "")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "GET /page?p=2", body)
+		}
+	})
+}
+
+func TestHARRecorderRedact(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "secret", Path: "/"})
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	record := func(opt HARRecorderOptions) *HAREntry {
+		recorder := NewHARRecorder(http.DefaultClient, opt)
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Cookie", "sid=secret")
+		req.Header.Set("X-Secret", "secret")
+		res, err := recorder.Do(req)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		_ = res.Body.Close()
+		return recorder.HAR().Log.Entries[0]
+	}
+	header := func(headers []HARNameValue, name string) string {
+		for _, h := range headers {
+			if h.Name == name {
+				return h.Value
+			}
+		}
+		return ""
+	}
+
+	entry := record(HARRecorderOptions{RedactHeaders: []string{"x-secret"}})
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "Bearer REDACTED", header(entry.Request.Headers, "Authorization"))
+		assert.Equal(t, "REDACTED", header(entry.Request.Headers, "Cookie"))
+		assert.Equal(t, "REDACTED", header(entry.Request.Headers, "X-Secret"))
+		assert.Equal(t, []HARNameValue{{"sid", "REDACTED"}}, entry.Request.Cookies)
+		assert.Equal(t, "sid=REDACTED", header(entry.Response.Headers, "Set-Cookie"))
+		assert.Equal(t, []HARNameValue{{"sid", "REDACTED"}}, entry.Response.Cookies)
+		assert.Equal(t, "ok", entry.Response.Content.Text)
+	}
+
+	entry = record(HARRecorderOptions{Unredacted: true})
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "Bearer token", header(entry.Request.Headers, "Authorization"))
+		assert.Equal(t, "secret", header(entry.Request.Headers, "X-Secret"))
+		assert.Equal(t, "sid=secret; Path=/", header(entry.Response.Headers, "Set-Cookie"))
+	}
+}
@@ -0,0 +1,194 @@
+package ski
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// FetchFunc is an adapter to allow the use of ordinary functions as Fetch.
+	FetchFunc func(*http.Request) (*http.Response, error)
+
+	// FetchMiddleware wraps the Fetch to add the behavior before or after the request.
+	FetchMiddleware func(Fetch) Fetch
+)
+
+// Do calls f(req).
+func (f FetchFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// ChainFetch returns the base Fetch wrapped by the middlewares,
+// the first middleware is the outermost, which handles the request first.
+func ChainFetch(base Fetch, mws ...FetchMiddleware) Fetch {
+	for i := len(mws) - 1; i >= 0; i-- {
+		base = mws[i](base)
+	}
+	return base
+}
+
+// RetryMiddleware returns the FetchMiddleware of NewRetryFetch.
+func RetryMiddleware(opt RetryOptions) FetchMiddleware {
+	return func(fetch Fetch) Fetch { return NewRetryFetch(fetch, opt) }
+}
+
+// RateLimitMiddleware returns the FetchMiddleware of NewRateLimitFetch.
+func RateLimitMiddleware(opt RateLimitOptions) FetchMiddleware {
+	return func(fetch Fetch) Fetch { return NewRateLimitFetch(fetch, opt) }
+}
+
+// CacheMiddleware returns the FetchMiddleware of NewCacheFetch.
+func CacheMiddleware(cache Cache, opt CacheOptions) FetchMiddleware {
+	return func(fetch Fetch) Fetch { return NewCacheFetch(fetch, cache, opt) }
+}
+
+// HeaderMiddleware sets the default headers to the request
+// if the request does not have the header.
+func HeaderMiddleware(header http.Header) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			var clone bool
+			for k, v := range header {
+				if _, ok := req.Header[http.CanonicalHeaderKey(k)]; ok {
+					continue
+				}
+				if !clone {
+					req = req.Clone(req.Context())
+					clone = true
+				}
+				// copy the values, the later changes of the request do not affect the default headers
+				req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// UserAgentMiddleware rotates the user-agents in round-robin order
+// if the request does not have the User-Agent header.
+func UserAgentMiddleware(agents ...string) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		if len(agents) == 0 {
+			return fetch
+		}
+		var next atomic.Uint64
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("User-Agent") == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("User-Agent", agents[(next.Add(1)-1)%uint64(len(agents))])
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// SignMiddleware calls the sign function with the cloned request before sending,
+// such as computing the signature header.
+func SignMiddleware(sign func(*http.Request) error) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			if err := sign(req); err != nil {
+				return nil, err
+			}
+			return fetch.Do(req)
+		})
+	}
+}
+
+// LogMiddleware logs the request method, url, response status and duration
+// with the context Logger.
+func LogMiddleware(level slog.Level) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			start := time.Now()
+			res, err := fetch.Do(req)
+			attrs := []slog.Attr{
+				fetchSubsystem,
+				slog.String("method", req.Method),
+				slog.String("url", req.URL.String()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				Logger(ctx).LogAttrs(ctx, max(level, slog.LevelWarn), "fetch failed", append(attrs, slog.Any("error", err))...)
+				return nil, err
+			}
+			Logger(ctx).LogAttrs(ctx, level, "fetch", append(attrs, slog.Int("status", res.StatusCode))...)
+			return res, nil
+		})
+	}
+}
+
+// FetchMetrics collects the requests metrics of Fetch.
+type FetchMetrics struct {
+	requests atomic.Int64
+	inflight atomic.Int64
+	errors   atomic.Int64
+	duration atomic.Int64
+	status   [6]atomic.Int64 // status classes, 1xx ~ 5xx
+}
+
+// Middleware returns the FetchMiddleware which records the metrics.
+func (m *FetchMetrics) Middleware() FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			m.requests.Add(1)
+			m.inflight.Add(1)
+			defer m.inflight.Add(-1)
+			start := time.Now()
+			res, err := fetch.Do(req)
+			m.duration.Add(int64(time.Since(start)))
+			if err != nil {
+				m.errors.Add(1)
+				return nil, err
+			}
+			if class := res.StatusCode / 100; class > 0 && class < len(m.status) {
+				m.status[class].Add(1)
+			}
+			return res, nil
+		})
+	}
+}
+
+// Requests returns the total requests.
+func (m *FetchMetrics) Requests() int64 { return m.requests.Load() }
+
+// Errors returns the total failed requests.
+func (m *FetchMetrics) Errors() int64 { return m.errors.Load() }
+
+// Status returns the total responses of the status class, such as 2 for 2xx.
+func (m *FetchMetrics) Status(class int) int64 {
+	if class <= 0 || class >= len(m.status) {
+		return 0
+	}
+	return m.status[class].Load()
+}
+
+// String returns the JSON text of the metrics.
+func (m *FetchMetrics) String() string {
+	text, _ := m.MarshalText()
+	return string(text)
+}
+
+// MarshalText encodes the metrics snapshot as a JSON object, the average duration
+// is formatted as the time.Duration string.
+func (m *FetchMetrics) MarshalText() ([]byte, error) {
+	requests := m.requests.Load()
+	var avg time.Duration
+	if requests > 0 {
+		avg = time.Duration(m.duration.Load() / requests)
+	}
+	return json.Marshal(map[string]any{
+		"requests":    requests,
+		"inflight":    m.inflight.Load(),
+		"errors":      m.errors.Load(),
+		"1xx":         m.status[1].Load(),
+		"2xx":         m.status[2].Load(),
+		"3xx":         m.status[3].Load(),
+		"4xx":         m.status[4].Load(),
+		"5xx":         m.status[5].Load(),
+		"avgDuration": avg.String(),
+	})
+}
@@ -0,0 +1,102 @@
+package ski
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainFetch(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Agent", r.UserAgent())
+		w.Header().Set("X-Lang", r.Header.Get("Accept-Language"))
+		w.Header().Set("X-Sign", r.Header.Get("X-Sign"))
+		if r.URL.Path == "/404" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	var order []string
+	trace := func(name string) FetchMiddleware {
+		return func(fetch Fetch) Fetch {
+			return FetchFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return fetch.Do(req)
+			})
+		}
+	}
+
+	logs := new(bytes.Buffer)
+	metrics := new(FetchMetrics)
+	fetch := ChainFetch(http.DefaultClient,
+		trace("first"),
+		metrics.Middleware(),
+		HeaderMiddleware(http.Header{"Accept-Language": {"en"}}),
+		UserAgentMiddleware("agent1", "agent2"),
+		SignMiddleware(func(req *http.Request) error {
+			req.Header.Set("X-Sign", req.Method+" "+req.URL.Path)
+			return nil
+		}),
+		LogMiddleware(slog.LevelInfo),
+		trace("last"),
+	)
+
+	ctx := WithLogger(context.Background(), slog.New(slog.NewTextHandler(logs, nil)))
+	do := func(path string, header http.Header) *http.Response {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := fetch.Do(req)
+		if assert.NoError(t, err) {
+			_ = res.Body.Close()
+		}
+		return res
+	}
+
+	res := do("/", nil)
+	assert.Equal(t, []string{"first", "last"}, order)
+	assert.Equal(t, "agent1", res.Header.Get("X-Agent"))
+	assert.Equal(t, "en", res.Header.Get("X-Lang"))
+	assert.Equal(t, "GET /", res.Header.Get("X-Sign"))
+
+	res = do("/404", http.Header{"Accept-Language": {"ja"}})
+	assert.Equal(t, "agent2", res.Header.Get("X-Agent"))
+	assert.Equal(t, "ja", res.Header.Get("X-Lang"))
+
+	res = do("/", http.Header{"User-Agent": {"custom"}})
+	assert.Equal(t, "custom", res.Header.Get("X-Agent"))
+
+	assert.EqualValues(t, 3, metrics.Requests())
+	assert.EqualValues(t, 2, metrics.Status(2))
+	assert.EqualValues(t, 1, metrics.Status(4))
+	assert.Contains(t, metrics.String(), `"requests":3`)
+	assert.Equal(t, 3, strings.Count(logs.String(), "msg=fetch "))
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	t.Parallel()
+	header := http.Header{"Accept-Language": {"en"}}
+	fetch := ChainFetch(FetchFunc(func(req *http.Request) (*http.Response, error) {
+		req.Header["Accept-Language"][0] = "fr"
+		req.Header.Add("Accept-Language", "de")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}), HeaderMiddleware(header))
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://localhost", nil)
+	res, err := fetch.Do(req)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"fr", "de"}, res.Request.Header.Values("Accept-Language"))
+	}
+	// the default headers are not changed by the request
+	assert.Equal(t, http.Header{"Accept-Language": {"en"}}, header)
+	assert.Empty(t, req.Header)
+}
@@ -0,0 +1,346 @@
+package ski
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions the per-host rate limit options of Fetch.
+type RateLimitOptions struct {
+	// RequestsPerSecond the max requests per second of each host, zero means unlimited.
+	RequestsPerSecond float64 `yaml:"requests-per-second" json:"requestsPerSecond"`
+	// Burst the max requests of each host can be sent at once, default is 1.
+	Burst int `yaml:"burst" json:"burst"`
+	// MaxConcurrency the max in-flight requests of each host, zero means unlimited.
+	// A request is in-flight until its response body is closed.
+	MaxConcurrency int `yaml:"max-concurrency" json:"maxConcurrency"`
+	// Robots honours the Crawl-delay of the host robots.txt,
+	// the slower one of the RequestsPerSecond and Crawl-delay is used.
+	Robots bool `yaml:"robots" json:"robots"`
+	// UserAgent the user-agent to match the robots.txt group, default is "*".
+	UserAgent string `yaml:"user-agent" json:"userAgent"`
+}
+
+// NewRateLimitFetch returns a Fetch which limits the requests rate and
+// concurrency of each host. The requests exceeding the limits are queued
+// until they are allowed or the request context is done.
+func NewRateLimitFetch(fetch Fetch, opt RateLimitOptions) Fetch {
+	if opt.Burst <= 0 {
+		opt.Burst = 1
+	}
+	return &rateLimitFetch{
+		fetch: fetch,
+		opt:   opt,
+		hosts: make(map[string]*hostLimiter),
+	}
+}
+
+type rateLimitFetch struct {
+	fetch Fetch
+	opt   RateLimitOptions
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+	swept time.Time // the last eviction of the idle hosts
+}
+
+// hostLimiter the token bucket and semaphore of a host.
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // the interval of each token, zero means unlimited
+	burst    float64
+	tokens   float64
+	last     time.Time
+	sem      chan struct{}
+
+	// the refs and used are guarded by the rateLimitFetch.mu
+	refs int       // the requests are using the limiter
+	used time.Time // the last request done
+
+	robotsMu   sync.Mutex
+	robots     bool          // the robots.txt is fetched
+	robotsAt   time.Time     // the last failed fetch of the robots.txt
+	robotsWait chan struct{} // closed when the in-flight fetch of the robots.txt done
+}
+
+const (
+	// robotsTimeout the timeout of fetching the robots.txt.
+	robotsTimeout = 10 * time.Second
+	// robotsRetry the interval to fetch the robots.txt again after a failure.
+	robotsRetry = time.Minute
+	// hostIdleTimeout the idle host limiters and their robots.txt are evicted after the timeout.
+	hostIdleTimeout = 10 * time.Minute
+)
+
+// Do sends an HTTP request and returns an HTTP response, waiting for the host limits.
+func (r *rateLimitFetch) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	limiter := r.limiter(req.URL)
+	defer r.done(limiter)
+
+	if r.opt.Robots {
+		if err := r.robots(ctx, req.URL, limiter); err != nil {
+			return nil, err
+		}
+	}
+
+	if limiter.sem != nil {
+		select {
+		case limiter.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := limiter.wait(ctx); err != nil {
+		limiter.release()
+		return nil, err
+	}
+
+	res, err := r.fetch.Do(req)
+	if err != nil {
+		limiter.release()
+		return nil, err
+	}
+	if limiter.sem != nil {
+		res.Body = &releaseBody{ReadCloser: res.Body, release: limiter.release}
+	}
+	return res, nil
+}
+
+// limiter returns the hostLimiter of the URL host, the caller must call the done
+// after the request. The idle hosts are evicted when a new host is added.
+func (r *rateLimitFetch) limiter(u *url.URL) *hostLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.hosts[u.Host]; ok {
+		l.refs++
+		return l
+	}
+	if now := time.Now(); now.Sub(r.swept) >= hostIdleTimeout {
+		r.evict(now)
+		r.swept = now
+	}
+	l := &hostLimiter{burst: float64(r.opt.Burst), tokens: float64(r.opt.Burst), refs: 1}
+	if r.opt.RequestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / r.opt.RequestsPerSecond)
+	}
+	if r.opt.MaxConcurrency > 0 {
+		l.sem = make(chan struct{}, r.opt.MaxConcurrency)
+	}
+	r.hosts[u.Host] = l
+	return l
+}
+
+// done marks the request of the limiter is done.
+func (r *rateLimitFetch) done(l *hostLimiter) {
+	r.mu.Lock()
+	l.refs--
+	l.used = time.Now()
+	r.mu.Unlock()
+}
+
+// evict removes the host limiters which have no requests and have been idle for
+// the hostIdleTimeout, and their token buckets are refilled. Must be called with the r.mu held.
+func (r *rateLimitFetch) evict(now time.Time) {
+	for host, l := range r.hosts {
+		if l.refs > 0 || len(l.sem) > 0 {
+			continue
+		}
+		l.mu.Lock()
+		idle := max(hostIdleTimeout, time.Duration(l.burst*float64(l.interval)))
+		l.mu.Unlock()
+		if now.Sub(l.used) >= idle {
+			delete(r.hosts, host)
+		}
+	}
+}
+
+// robots applies the Crawl-delay of the host robots.txt once, if the fetch failed
+// the robots.txt is fetched again by the request after the robotsRetry.
+// The robots.txt is fetched once for the concurrent requests of the host,
+// returns the context error if the context is done before the fetch.
+func (r *rateLimitFetch) robots(ctx context.Context, u *url.URL, limiter *hostLimiter) error {
+	limiter.robotsMu.Lock()
+	if limiter.robots || time.Since(limiter.robotsAt) < robotsRetry {
+		limiter.robotsMu.Unlock()
+		return nil
+	}
+	wait := limiter.robotsWait
+	if wait == nil {
+		wait = make(chan struct{})
+		limiter.robotsWait = wait
+		go func() {
+			ok := r.crawlDelay(ctx, u, limiter)
+			limiter.robotsMu.Lock()
+			if limiter.robots = ok; !ok {
+				limiter.robotsAt = time.Now()
+			}
+			limiter.robotsWait = nil
+			limiter.robotsMu.Unlock()
+			close(wait)
+		}()
+	}
+	limiter.robotsMu.Unlock()
+
+	select {
+	case <-wait:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// crawlDelay fetches the host robots.txt and applies the Crawl-delay to the limiter,
+// reports whether the robots.txt is fetched. The robots.txt is fetched with its own
+// timeout, the cancellation of the request does not affect it.
+func (r *rateLimitFetch) crawlDelay(ctx context.Context, u *url.URL, limiter *hostLimiter) bool {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), robotsTimeout)
+	defer cancel()
+	robots := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robots.String(), nil)
+	if err != nil {
+		return true
+	}
+	if r.opt.UserAgent != "" {
+		req.Header.Set("User-Agent", r.opt.UserAgent)
+	}
+	res, err := r.fetch.Do(req)
+	if err != nil {
+		Logger(ctx).Debug("fetch robots.txt failed", fetchSubsystem, slog.String("url", robots.String()), slog.Any("error", err))
+		return false
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return true
+	}
+
+	delay := parseCrawlDelay(io.LimitReader(res.Body, 512<<10), r.opt.UserAgent)
+	limiter.mu.Lock()
+	if delay > limiter.interval {
+		limiter.interval = delay
+		// the Crawl-delay allows only one request per delay
+		limiter.burst = 1
+		limiter.tokens = min(limiter.tokens, 1)
+	}
+	limiter.mu.Unlock()
+	return true
+}
+
+// wait reserves a token and waits until it is available or the context is done.
+func (l *hostLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens * float64(l.interval))
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// return the reserved token
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// release the concurrency slot.
+func (l *hostLimiter) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// releaseBody calls the release once when the body closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// parseCrawlDelay returns the Crawl-delay of the robots.txt group that matches
+// the user-agent, or the group of "*" if no group matches.
+func parseCrawlDelay(r io.Reader, userAgent string) time.Duration {
+	userAgent = strings.ToLower(userAgent)
+	var (
+		agents      []string
+		inRules     bool
+		matched     = time.Duration(-1)
+		wildcard    = time.Duration(-1)
+		scanner     = bufio.NewScanner(r)
+		matchAgents = func(delay time.Duration) {
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					wildcard = delay
+				case userAgent != "" && strings.Contains(userAgent, agent):
+					matched = delay
+				}
+			}
+		}
+	)
+
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			matchAgents(time.Duration(seconds * float64(time.Second)))
+		default:
+			inRules = true
+		}
+	}
+
+	if matched >= 0 {
+		return matched
+	}
+	return max(wildcard, 0)
+}
@@ -0,0 +1,173 @@
+package ski
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitFetch(t *testing.T) {
+	t.Parallel()
+	var current, peak atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			_, _ = fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.05\n")
+			return
+		}
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	get := func(fetch Fetch, ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		res, err := fetch.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		return res.Body.Close()
+	}
+
+	t.Run("rate", func(t *testing.T) {
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{RequestsPerSecond: 20})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, get(fetch, context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		peak.Store(0)
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{MaxConcurrency: 2})
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, get(fetch, context.Background()))
+			}()
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, peak.Load(), int32(2))
+	})
+
+	t.Run("robots", func(t *testing.T) {
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{Robots: true})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, get(fetch, context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("robots cancel", func(t *testing.T) {
+		// the robots.txt is fetched even if the first request is canceled
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{Robots: true})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, get(fetch, ctx), context.Canceled)
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, get(fetch, context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("robots wait", func(t *testing.T) {
+		var robots atomic.Int32
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				robots.Add(1)
+				<-release
+			}
+		}))
+		defer slow.Close()
+		defer close(release)
+
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{Robots: true})
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// the requests waiting for the robots.txt are canceled by their context
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+				req, _ := http.NewRequestWithContext(ctx, http.MethodGet, slow.URL, nil)
+				_, err := fetch.Do(req)
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, robots.Load())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{RequestsPerSecond: 1})
+		assert.NoError(t, get(fetch, context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, get(fetch, ctx), context.DeadlineExceeded)
+	})
+}
+
+func TestRateLimitEvict(t *testing.T) {
+	t.Parallel()
+	fetch := NewRateLimitFetch(http.DefaultClient, RateLimitOptions{MaxConcurrency: 1}).(*rateLimitFetch)
+	idle := fetch.limiter(&url.URL{Host: "idle.example"})
+	fetch.done(idle)
+	busy := fetch.limiter(&url.URL{Host: "busy.example"})
+	fetch.done(busy)
+	busy.sem <- struct{}{}
+	using := fetch.limiter(&url.URL{Host: "using.example"})
+
+	fetch.mu.Lock()
+	for _, l := range fetch.hosts {
+		l.used = l.used.Add(-hostIdleTimeout)
+	}
+	fetch.swept = time.Time{}
+	fetch.mu.Unlock()
+
+	fetch.done(fetch.limiter(&url.URL{Host: "new.example"}))
+	assert.Len(t, fetch.hosts, 3)
+	assert.NotContains(t, fetch.hosts, "idle.example")
+	// the in-flight hosts are kept
+	assert.Same(t, busy, fetch.hosts["busy.example"])
+	assert.Same(t, using, fetch.hosts["using.example"])
+}
+
+func TestParseCrawlDelay(t *testing.T) {
+	t.Parallel()
+	robots := `
+User-agent: ski
+User-agent: other
+Disallow: /private
+Crawl-delay: 2 # comment
+
+User-agent: *
+Crawl-delay: 5
+`
+	assert.Equal(t, 2*time.Second, parseCrawlDelay(strings.NewReader(robots), "ski/1.0"))
+	assert.Equal(t, 5*time.Second, parseCrawlDelay(strings.NewReader(robots), "curl"))
+	assert.Equal(t, time.Duration(0), parseCrawlDelay(strings.NewReader("User-agent: *\nDisallow:"), ""))
+}
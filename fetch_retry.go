@@ -0,0 +1,255 @@
+package ski
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultRetryAttempts default max attempts of the request
+	DefaultRetryAttempts = 3
+	// DefaultRetryMinBackoff default backoff of the first retry
+	DefaultRetryMinBackoff = 500 * time.Millisecond
+	// DefaultRetryMaxBackoff default max backoff between attempts
+	DefaultRetryMaxBackoff = 30 * time.Second
+)
+
+var (
+	// DefaultRetryMethods the idempotent methods that can be retried
+	DefaultRetryMethods = []string{
+		http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete,
+	}
+	// DefaultRetryStatusCodes the transient status codes that can be retried
+	DefaultRetryStatusCodes = []int{
+		http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+	}
+)
+
+// RetryOptions the retry policy of Fetch.
+// The zero value fields use the default values.
+type RetryOptions struct {
+	// MaxAttempts the max attempts including the first request, 1 means no retry.
+	MaxAttempts int `yaml:"max-attempts" json:"maxAttempts"`
+	// MinBackoff the backoff of the first retry, doubled on each retry.
+	MinBackoff time.Duration `yaml:"min-backoff" json:"minBackoff"`
+	// MaxBackoff the max backoff between attempts, a Retry-After longer
+	// than MaxBackoff will not be retried.
+	MaxBackoff time.Duration `yaml:"max-backoff" json:"maxBackoff"`
+	// Methods the methods can be retried, a request with the Idempotency-Key
+	// header can be retried regardless of the method.
+	Methods []string `yaml:"methods" json:"methods"`
+	// StatusCodes the response status codes should be retried.
+	StatusCodes []int `yaml:"status-codes" json:"statusCodes"`
+}
+
+var requestRetryKey byte
+
+// WithRetry returns a copy of parent context in which the RetryOptions associated with context,
+// the non-zero fields of RetryOptions overrides the options of NewRetryFetch.
+// The options are not set to the shared Context, only the requests of the returned context are affected.
+func WithRetry(ctx context.Context, opt RetryOptions) context.Context {
+	return context.WithValue(ctx, &requestRetryKey, opt)
+}
+
+// RetryFromContext returns the RetryOptions on context.
+func RetryFromContext(ctx context.Context) (RetryOptions, bool) {
+	opt, ok := ctx.Value(&requestRetryKey).(RetryOptions)
+	return opt, ok
+}
+
+// NewRetryFetch returns a Fetch which retries the transient errors,
+// with exponential backoff and jitter between attempts.
+// A request is retried when the transient network error occurred or the response status
+// is one of the RetryOptions.StatusCodes, the Retry-After header is respected.
+// Only the idempotent requests with a replayable body are retried.
+func NewRetryFetch(fetch Fetch, opt RetryOptions) Fetch {
+	return &retryFetch{fetch, opt}
+}
+
+type retryFetch struct {
+	fetch Fetch
+	opt   RetryOptions
+}
+
+// Do sends an HTTP request and returns an HTTP response, retry with the policy.
+func (r *retryFetch) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	opt := r.opt
+	if o, ok := RetryFromContext(ctx); ok {
+		opt = opt.merge(o)
+	}
+	opt = opt.withDefaults()
+
+	if opt.MaxAttempts <= 1 || !opt.retryable(req) {
+		return r.fetch.Do(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := r.fetch.Do(req)
+		if attempt >= opt.MaxAttempts {
+			return res, err
+		}
+
+		delay := opt.backoff(attempt)
+		if err != nil {
+			if !retryableError(err) {
+				return nil, err
+			}
+		} else {
+			if !slices.Contains(opt.StatusCodes, res.StatusCode) {
+				return res, nil
+			}
+			if after, ok := retryAfter(res); ok {
+				if after > opt.MaxBackoff {
+					return res, nil
+				}
+				delay = after
+			}
+			// drain the body to reuse the connection
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+			_ = res.Body.Close()
+		}
+
+		Logger(ctx).LogAttrs(ctx, slog.LevelDebug, "retry request", fetchSubsystem,
+			slog.String("method", req.Method),
+			slog.String("url", req.URL.String()),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("error", err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// merge returns the options which the non-zero fields overridden by the other.
+func (opt RetryOptions) merge(other RetryOptions) RetryOptions {
+	if other.MaxAttempts != 0 {
+		opt.MaxAttempts = other.MaxAttempts
+	}
+	if other.MinBackoff != 0 {
+		opt.MinBackoff = other.MinBackoff
+	}
+	if other.MaxBackoff != 0 {
+		opt.MaxBackoff = other.MaxBackoff
+	}
+	if len(other.Methods) > 0 {
+		opt.Methods = other.Methods
+	}
+	if len(other.StatusCodes) > 0 {
+		opt.StatusCodes = other.StatusCodes
+	}
+	return opt
+}
+
+func (opt RetryOptions) withDefaults() RetryOptions {
+	if opt.MaxAttempts == 0 {
+		opt.MaxAttempts = DefaultRetryAttempts
+	}
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = DefaultRetryMinBackoff
+	}
+	if opt.MaxBackoff <= 0 {
+		opt.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if len(opt.Methods) == 0 {
+		opt.Methods = DefaultRetryMethods
+	}
+	if len(opt.StatusCodes) == 0 {
+		opt.StatusCodes = DefaultRetryStatusCodes
+	}
+	return opt
+}
+
+// retryable reports whether the request is idempotent and its body can be replayed.
+func (opt RetryOptions) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return slices.ContainsFunc(opt.Methods, func(m string) bool {
+		return strings.EqualFold(m, req.Method)
+	})
+}
+
+// backoff returns the exponential backoff with jitter of the attempt,
+// the delay is in the range of [backoff/2, backoff].
+func (opt RetryOptions) backoff(attempt int) time.Duration {
+	backoff := opt.MinBackoff
+	for i := 1; i < attempt && backoff < opt.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, opt.MaxBackoff)
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)) //nolint:gosec
+}
+
+// retryAfter parses the Retry-After header, which is the delay seconds or HTTP date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(v); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// retryableError reports whether the error is a transient network error,
+// the connection closed by the peer, the timeout or the temporary error.
+// The refused connection and the not found host are not retried.
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var tempErr interface{ Temporary() bool }
+	return errors.As(err, &tempErr) && tempErr.Temporary()
+}
@@ -0,0 +1,138 @@
+package ski
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryFetch(t *testing.T) {
+	t.Parallel()
+	var count atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := count.Add(1)
+		switch r.URL.Path {
+		case "/unavailable":
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/retry-after":
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case "/reset":
+			if n < 2 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				_ = conn.Close()
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	fetch := NewRetryFetch(http.DefaultClient, RetryOptions{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	})
+
+	do := func(ctx context.Context, method, path string) *http.Response {
+		count.Store(0)
+		req, _ := http.NewRequestWithContext(ctx, method, ts.URL+path, strings.NewReader("body"))
+		res, err := fetch.Do(req)
+		if assert.NoError(t, err) {
+			_ = res.Body.Close()
+		}
+		return res
+	}
+
+	t.Run("status", func(t *testing.T) {
+		res := do(context.Background(), http.MethodGet, "/unavailable")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.EqualValues(t, 3, count.Load())
+	})
+
+	t.Run("non idempotent", func(t *testing.T) {
+		res := do(context.Background(), http.MethodPost, "/unavailable")
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.EqualValues(t, 1, count.Load())
+	})
+
+	t.Run("context options", func(t *testing.T) {
+		ctx := WithRetry(context.Background(), RetryOptions{MaxAttempts: 2})
+		res := do(ctx, http.MethodGet, "/unavailable")
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.EqualValues(t, 2, count.Load())
+
+		// the options are not set to the shared context
+		shared := NewContext(context.Background(), nil)
+		_ = WithRetry(shared, RetryOptions{MaxAttempts: 1})
+		_, ok := RetryFromContext(shared)
+		assert.False(t, ok)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		res := do(context.Background(), http.MethodGet, "/retry-after")
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.EqualValues(t, 1, count.Load())
+	})
+
+	t.Run("connection reset", func(t *testing.T) {
+		res := do(context.Background(), http.MethodPut, "/reset")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.EqualValues(t, 2, count.Load())
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Parallel()
+	opt := RetryOptions{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}.withDefaults()
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		delay := opt.backoff(attempt + 1)
+		assert.GreaterOrEqual(t, delay, expected/2)
+		assert.LessOrEqual(t, delay, expected)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryableError(t *testing.T) {
+	t.Parallel()
+	dial := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://localhost", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	for _, c := range []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"refused", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), false},
+		{"not found host", dial(&net.DNSError{Err: "no such host", Name: "foo.invalid", IsNotFound: true}), false},
+		{"dns timeout", dial(&net.DNSError{Err: "timeout", Name: "foo.com", IsTimeout: true}), true},
+		{"dns temporary", dial(&net.DNSError{Err: "server misbehaving", Name: "foo.com", IsTemporary: true}), true},
+		{"dial timeout", dial(timeoutError{}), true},
+		{"reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"eof", &url.Error{Op: "Get", URL: "http://localhost", Err: io.EOF}, true},
+		{"canceled", &url.Error{Op: "Get", URL: "http://localhost", Err: context.Canceled}, false},
+		{"other", errors.New("tls: bad certificate"), false},
+	} {
+		assert.Equal(t, c.retryable, retryableError(c.err), c.name)
+	}
+}
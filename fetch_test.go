@@ -0,0 +1,122 @@
+package ski
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchOptions(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	clientCert := writeCert(t, certFile, keyFile)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var name string
+		if len(r.TLS.PeerCertificates) > 0 {
+			name = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		_, _ = fmt.Fprintf(w, "%s %s", r.Proto, name)
+	}))
+	ts.EnableHTTP2 = true
+	ts.TLS = &tls.Config{ClientAuth: tls.VerifyClientCertIfGiven, ClientCAs: x509.NewCertPool()}
+	ts.TLS.ClientCAs.AddCert(clientCert)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	do := func(opt FetchOptions) (string, error) {
+		fetch, err := NewFetchWithOptions(opt)
+		if err != nil {
+			return "", err
+		}
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL, nil)
+		res, err := fetch.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), err
+	}
+
+	_, err = do(FetchOptions{})
+	assert.ErrorContains(t, err, "certificate")
+
+	body, err := do(FetchOptions{InsecureSkipVerify: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "HTTP/2.0 ", body)
+	}
+
+	body, err = do(FetchOptions{CAFile: caFile, DisableHTTP2: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "HTTP/1.1 ", body)
+	}
+
+	body, err = do(FetchOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "HTTP/2.0 ski", body)
+	}
+
+	_, err = do(FetchOptions{CAFile: certFile + ".missing"})
+	assert.Error(t, err)
+	_, err = do(FetchOptions{MinTLSVersion: "2.0"})
+	assert.Error(t, err)
+}
+
+// writeCert writes a self-signed client certificate and its private key to the files.
+func writeCert(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ski"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
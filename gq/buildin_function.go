@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
@@ -35,6 +36,22 @@ func builtins() FuncMap {
 		"parents": Parents,
 		"prefix":  Prefix,
 		"suffix":  Suffix,
+
+		"trim":      Trim,
+		"normalize": Normalize,
+		"ownText":   OwnText,
+		"src":       Src,
+		"outerHtml": OuterHtml,
+		"siblings":  Siblings,
+		"closest":   Closest,
+		"filter":    Filter,
+		"not":       Not,
+		"has":       Has,
+		"index":     Index,
+		"count":     Count,
+		"tagName":   TagName,
+		"attrs":     Attrs,
+		"dataset":   Dataset,
 	}
 }
 
@@ -370,3 +387,312 @@ func Suffix(_ context.Context, content any, args ...string) (any, error) {
 		return content, nil
 	}
 }
+
+// mapString applies fn to the string content, or the text of each element.
+func mapString(name string, content any, fn func(string) string) (any, error) {
+	switch c := content.(type) {
+	case string:
+		return fn(c), nil
+	case []string:
+		ret := make([]string, len(c))
+		for i, s := range c {
+			ret[i] = fn(s)
+		}
+		return ret, nil
+	case ski.Iterator:
+		ret := make([]string, c.Len())
+		for i := 0; i < c.Len(); i++ {
+			s, ok := c.At(i).(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: unexpected type %T", name, c.At(i))
+			}
+			ret[i] = fn(s)
+		}
+		return ski.NewIterator(ret), nil
+	case *goquery.Selection, *html.Node, nil:
+		return contentToString(content, func(node *goquery.Selection) (string, error) {
+			return fn(node.Text()), nil
+		})
+	default:
+		return nil, fmt.Errorf("%s: unexpected type %T", name, content)
+	}
+}
+
+// Trim returns the string without the leading and trailing whitespaces,
+// or the text of each element. If present the cutset trims the characters of the cutset.
+func Trim(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) > 0 && args[0] != "" {
+		return mapString("trim", content, func(s string) string { return strings.Trim(s, args[0]) })
+	}
+	return mapString("trim", content, strings.TrimSpace)
+}
+
+// Normalize returns the string with the whitespaces trimmed and collapsed
+// to the single space, or the text of each element.
+func Normalize(_ context.Context, content any, _ ...string) (any, error) {
+	return mapString("normalize", content, normalizeSpace)
+}
+
+// OwnText gets the text contents of each element in the set of matched elements,
+// excluding their descendants.
+func OwnText(_ context.Context, content any, _ ...string) (any, error) {
+	return contentToString(content, func(node *goquery.Selection) (string, error) {
+		var b strings.Builder
+		for _, n := range node.Nodes {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					b.WriteString(c.Data)
+				}
+			}
+		}
+		return strings.TrimSpace(b.String()), nil
+	})
+}
+
+// Src gets the image URL of each element, the largest candidate of the srcset
+// attribute, or the src attribute, the lazy loading data-srcset and data-src attributes
+// are used if absent. If URL is not absolute returns the absolute URL
+// of the baseURL or the first argument.
+func Src(ctx context.Context, content any, args ...string) (any, error) {
+	var base *url.URL
+	if v, ok := ctx.Value("baseURL").(string); ok {
+		args = []string{v}
+	}
+	if len(args) > 0 && args[0] != "" {
+		var err error
+		if base, err = url.Parse(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	return contentToString(content, func(node *goquery.Selection) (string, error) {
+		src := srcsetURL(node.AttrOr("srcset", node.AttrOr("data-srcset", "")))
+		if src == "" {
+			src = strings.TrimSpace(node.AttrOr("src", node.AttrOr("data-src", "")))
+		}
+		if src == "" || base == nil {
+			return src, nil
+		}
+		ref, err := url.Parse(src)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	})
+}
+
+// srcsetURL returns the URL of the largest candidate of the srcset, the width
+// descriptors are preferred to the pixel density descriptors, they are not comparable.
+// The candidate without the descriptor is 1x.
+func srcsetURL(srcset string) string {
+	var (
+		widthSrc, densitySrc string
+		width, density       float64
+	)
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		descriptor, size := byte('x'), 1.0
+		if len(fields) > 1 {
+			d := fields[1]
+			if v, err := strconv.ParseFloat(d[:len(d)-1], 64); err == nil && (d[len(d)-1] == 'w' || d[len(d)-1] == 'x') {
+				descriptor, size = d[len(d)-1], v
+			}
+		}
+		if descriptor == 'w' {
+			if widthSrc == "" || size > width {
+				widthSrc, width = fields[0], size
+			}
+		} else if densitySrc == "" || size > density {
+			densitySrc, density = fields[0], size
+		}
+	}
+	if widthSrc != "" {
+		return widthSrc
+	}
+	return densitySrc
+}
+
+// OuterHtml gets the outer HTML of each element, same as html(true).
+func OuterHtml(_ context.Context, content any, _ ...string) (any, error) { //nolint
+	return contentToString(content, goquery.OuterHtml)
+}
+
+// selectionMatcher applies fn to the Selection with the compiled selector,
+// the selector supports the extension pseudo-classes.
+func selectionMatcher(name string, content any, sel string,
+	fn func(*goquery.Selection, goquery.Matcher) *goquery.Selection) (any, error) {
+	node, ok := content.(*goquery.Selection)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected type %T", name, content)
+	}
+	m, err := compileSelector(sel)
+	if err != nil {
+		return nil, err
+	}
+	return fn(node, m), nil
+}
+
+// Siblings gets the siblings of each element in the Selection.
+// If present the selector will return filtered by the specified selector.
+func Siblings(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) > 0 {
+		return selectionMatcher("siblings", content, args[0], (*goquery.Selection).SiblingsMatcher)
+	}
+	if node, ok := content.(*goquery.Selection); ok {
+		return node.Siblings(), nil
+	}
+	return nil, fmt.Errorf("siblings: unexpected type %T", content)
+}
+
+// Closest gets the first element that matches the selector by testing the
+// element itself and traversing up through its ancestors in the DOM tree.
+func Closest(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("closest(selector) must has selector")
+	}
+	return selectionMatcher("closest", content, args[0], (*goquery.Selection).ClosestMatcher)
+}
+
+// Filter reduces the set of matched elements to those that match the selector.
+func Filter(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("filter(selector) must has selector")
+	}
+	return selectionMatcher("filter", content, args[0], (*goquery.Selection).FilterMatcher)
+}
+
+// Not removes the elements that match the selector from the Selection.
+func Not(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("not(selector) must has selector")
+	}
+	// the positional pseudo-classes such as :first filter the Selection as the set
+	return selectionMatcher("not", content, args[0], func(node *goquery.Selection, m goquery.Matcher) *goquery.Selection {
+		return node.NotSelection(node.FilterMatcher(m))
+	})
+}
+
+// Has reduces the set of matched elements to those that have a descendant
+// that matches the selector.
+func Has(_ context.Context, content any, args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("has(selector) must has selector")
+	}
+	return selectionMatcher("has", content, args[0], (*goquery.Selection).HasMatcher)
+}
+
+// Index returns the position of the first element in the Selection relative
+// to its sibling elements, or -1 if the Selection is empty.
+// If present returns the position of the first element within the elements
+// matched by the selector.
+func Index(_ context.Context, content any, args ...string) (any, error) {
+	node, ok := content.(*goquery.Selection)
+	if !ok {
+		return nil, fmt.Errorf("index: unexpected type %T", content)
+	}
+	if len(args) > 0 {
+		m, err := compileSelector(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return node.IndexMatcher(m), nil
+	}
+	return node.Index(), nil
+}
+
+// Count returns the number of the elements in the Selection, or the length of the list.
+func Count(_ context.Context, content any, _ ...string) (any, error) {
+	switch c := content.(type) {
+	case *goquery.Selection:
+		return c.Length(), nil
+	case ski.Iterator:
+		return c.Len(), nil
+	case []string:
+		return len(c), nil
+	case string, *html.Node:
+		return 1, nil
+	case nil:
+		return 0, nil
+	default:
+		return nil, fmt.Errorf("count: unexpected type %T", content)
+	}
+}
+
+// TagName gets the lower case tag name of each element.
+func TagName(_ context.Context, content any, _ ...string) (any, error) {
+	return contentToString(content, func(node *goquery.Selection) (string, error) {
+		return goquery.NodeName(node), nil
+	})
+}
+
+// contentToMap applies fn to each element, returns the map of the single element,
+// or the list of the maps.
+func contentToMap(name string, content any, fn func(*html.Node) map[string]string) (any, error) {
+	switch c := content.(type) {
+	case *goquery.Selection:
+		switch c.Length() {
+		case 0:
+			return nil, nil
+		case 1:
+			return fn(c.Nodes[0]), nil
+		default:
+			list := make([]map[string]string, c.Length())
+			for i, n := range c.Nodes {
+				list[i] = fn(n)
+			}
+			return ski.NewIterator(list), nil
+		}
+	case *html.Node:
+		return fn(c), nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected type %T", name, content)
+	}
+}
+
+// Attrs gets all the attributes of each element as the map.
+func Attrs(_ context.Context, content any, _ ...string) (any, error) {
+	return contentToMap("attrs", content, func(n *html.Node) map[string]string {
+		attrs := make(map[string]string, len(n.Attr))
+		for _, attr := range n.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+		return attrs
+	})
+}
+
+// Dataset gets the data-* attributes of each element as the map,
+// the names are converted to the camel case like the DOM dataset,
+// such as data-user-id to userId.
+func Dataset(_ context.Context, content any, _ ...string) (any, error) {
+	return contentToMap("dataset", content, func(n *html.Node) map[string]string {
+		dataset := make(map[string]string)
+		for _, attr := range n.Attr {
+			if name, ok := strings.CutPrefix(attr.Key, "data-"); ok {
+				dataset[datasetName(name)] = attr.Val
+			}
+		}
+		return dataset
+	})
+}
+
+func datasetName(name string) string {
+	var b strings.Builder
+	upper := false
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch == '-' && i+1 < len(name) && 'a' <= name[i+1] && name[i+1] <= 'z':
+			upper = true
+		case upper:
+			b.WriteByte(ch - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -2,6 +2,9 @@ package gq
 
 import (
 	"testing"
+
+	"github.com/shiroyk/ski"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestBuildInFuncText(t *testing.T) {
@@ -134,3 +137,183 @@ func TestBuildInZip(t *testing.T) {
 		`<div id="n6" class="six odd row">6</div><div id="nf6" class="six odd row">f6</div>`,
 	})
 }
+
+func TestBuildInFuncTrim(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#main #n1 -> text -> prefix(' ') -> trim`, "1")
+
+	assertValue(t, `#main #n1 -> text -> prefix(--) -> trim(-)`, "1")
+
+	assertValue(t, `#main .row -> slice(0, 2) -> trim`, []string{"1", "2"})
+}
+
+func TestBuildInFuncNormalize(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#main -> normalize`, "1 2 3 4 5 6")
+
+	assertValue(t, `#main -> text -> suffix(" \n 7") -> normalize`, "1 2 3 4 5 6 7")
+}
+
+func TestBuildInFuncOwnText(t *testing.T) {
+	t.Parallel()
+	exec, err := new_value()(ski.String(`p -> ownText`))
+	if assert.NoError(t, err) {
+		v, err := exec.Exec(ctx, `<p> foo <b>bar</b> baz </p>`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "foo  baz", v)
+		}
+	}
+}
+
+func TestBuildInFuncSrc(t *testing.T) {
+	t.Parallel()
+	doc := `<img id="i1" src="/a.png" srcset="/a-1x.png, /a-2x.png 2x, /a-1.5x.png 1.5x">
+<img id="i2" srcset="b-100.png 100w, b-400.png 400w,b-200.png 200w">
+<img id="i3" data-src="https://cdn.com/c.png">
+<img id="i4" src="d.png">`
+	exec, err := new_value()(ski.String(`img -> src(https://localhost/path/)`))
+	if assert.NoError(t, err) {
+		v, err := exec.Exec(ctx, doc)
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, []string{
+				"https://localhost/a-2x.png",
+				"https://localhost/path/b-400.png",
+				"https://cdn.com/c.png",
+				"https://localhost/path/d.png",
+			}, v)
+		}
+	}
+	exec, err = new_value()(ski.String(`#i4 -> src`))
+	if assert.NoError(t, err) {
+		v, err := exec.Exec(ctx, doc)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "d.png", v)
+		}
+	}
+}
+
+func TestSrcsetURL(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct{ srcset, want string }{
+		{"", ""},
+		{"a.png", "a.png"},
+		{"a.png 1x, b.png 3x, c.png 2x", "b.png"},
+		{"a.png 100w, b.png 50w", "a.png"},
+		// the width is preferred to the density
+		{"a.png 3x, b.png 400w, c.png 800w", "c.png"},
+		{"a.png 1000w, b.png 2x", "a.png"},
+		{"a.png, b.png 2w", "b.png"},
+		// the invalid descriptor is 1x
+		{"a.png 0.5x, b.png large", "b.png"},
+	} {
+		assert.Equal(t, c.want, srcsetURL(c.srcset), c.srcset)
+	}
+}
+
+func TestBuildInFuncOuterHtml(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#foot #nf1 -> outerHtml`, `<div id="nf1" class="one even row">f1</div>`)
+}
+
+func TestBuildInFuncSiblings(t *testing.T) {
+	t.Parallel()
+	assertError(t, `#main #n1 -> text -> siblings`, "siblings: unexpected type string")
+
+	assertValue(t, `.body #a2 -> siblings -> attr(id)`, []string{"a1", "a3", "a4"})
+
+	assertValue(t, `.body #a2 -> siblings(:last) -> attr(id)`, "a4")
+}
+
+func TestBuildInFuncClosest(t *testing.T) {
+	t.Parallel()
+	assertError(t, `.body a -> closest`, "closest(selector) must has selector")
+
+	assertValue(t, `.body a:has-text(home) -> closest(div) -> attr(class)`, "body")
+
+	assertValue(t, `.body #a1 -> closest(li) -> attr(id)`, "a1")
+}
+
+func TestBuildInFuncFilter(t *testing.T) {
+	t.Parallel()
+	assertError(t, `.body li -> filter`, "filter(selector) must has selector")
+
+	assertValue(t, `.row -> filter(.odd) -> filter(:eq(1)) -> attr(id)`, "n4")
+
+	assertValue(t, `.body li -> filter('.selected, :has-text(github)') -> attr(id)`, []string{"a2", "a3"})
+}
+
+func TestBuildInFuncNot(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#foot .row -> not(.even) -> not(:first) -> attr(id)`, []string{"nf4", "nf6"})
+}
+
+func TestBuildInFuncHas(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `.body li -> has('a[title^="Go"]') -> attr(id)`, []string{"a1", "a3"})
+}
+
+func TestBuildInFuncIndex(t *testing.T) {
+	t.Parallel()
+	assertError(t, `#main -> text -> index`, "index: unexpected type string")
+
+	assertValue(t, `.body .selected -> index`, 2)
+
+	assertValue(t, `#foot #nf2 -> index(.row)`, 7)
+}
+
+func TestBuildInFuncCount(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `.row -> count`, 12)
+
+	assertValue(t, `.row -> text -> count`, 12)
+
+	assertValue(t, `#none -> count`, 0)
+}
+
+func TestBuildInFuncTagName(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#a1, #a1 a -> tagName`, []string{"li", "a"})
+}
+
+func TestBuildInFuncAttrs(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `#a1 a -> attrs`, map[string]string{"href": "https://google.com", "title": "Google page"})
+
+	assertElements(t, `#main .row -> slice(0, 2) -> attrs`, []string{
+		"map[class:one even row id:n1]",
+		"map[class:two odd row id:n2]",
+	})
+
+	// the attrs of several elements pass through the element and elements
+	for _, newExec := range []ski.NewExecutor{new_element(), new_elements()} {
+		exec, err := newExec(ski.String(`#main .row -> slice(1, 3) -> attrs`))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		v, err := exec.Exec(ctx, content)
+		if assert.NoError(t, err) {
+			assert.Equal(t, ski.NewIterator([]map[string]string{
+				{"id": "n2", "class": "two odd row"},
+				{"id": "n3", "class": "three even row"},
+			}), v)
+		}
+	}
+	list := []map[string]string{{"id": "n1"}, {"id": "n2"}}
+	for _, fn := range []Func{element, elements} {
+		v, err := fn(ctx, list)
+		if assert.NoError(t, err) {
+			assert.Equal(t, list, v)
+		}
+	}
+}
+
+func TestBuildInFuncDataset(t *testing.T) {
+	t.Parallel()
+	exec, err := new_value()(ski.String(`div -> dataset`))
+	if assert.NoError(t, err) {
+		v, err := exec.Exec(ctx, `<div id="d" data-id="1" data-user-name="foo" data--x="2" data-A-b="3"></div>`)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{"id": "1", "userName": "foo", "X": "2", "aB": "3"}, v)
+		}
+	}
+}
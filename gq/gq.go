@@ -9,7 +9,6 @@ import (
 	"sync/atomic"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/andybalholm/cascadia"
 	"github.com/shiroyk/ski"
 	"golang.org/x/net/html"
 )
@@ -66,14 +65,14 @@ func new_elements() ski.NewExecutor {
 func compile(raw string) (ret matcher, err error) {
 	funcs := strings.Split(raw, "->")
 	if len(funcs) == 1 {
-		ret.Matcher, err = cascadia.Compile(funcs[0])
+		ret.Matcher, err = compileSelector(funcs[0])
 		return
 	}
 	selector := strings.TrimSpace(funcs[0])
 	if len(selector) == 0 {
 		ret.Matcher = new(emptyMatcher)
 	} else {
-		ret.Matcher, err = cascadia.Compile(selector)
+		ret.Matcher, err = compileSelector(selector)
 		if err != nil {
 			return
 		}
@@ -116,7 +115,13 @@ func (f matcher) Exec(ctx context.Context, arg any) (any, error) {
 		return nil, err
 	}
 
-	var node any = nodes.FindMatcher(f)
+	var node any
+	if sel, ok := f.Matcher.(selector); ok {
+		// the positional pseudo-classes select from all the matched elements
+		node = nodes.FindNodes(sel.find(nodes.Nodes)...)
+	} else {
+		node = nodes.FindMatcher(f)
+	}
 
 	for _, c := range f.calls {
 		node, err = c.fn(ctx, node, c.args...)
@@ -129,21 +134,20 @@ func (f matcher) Exec(ctx context.Context, arg any) (any, error) {
 }
 
 func value(ctx context.Context, node any, _ ...string) (any, error) {
-	if node == nil {
-		return nil, nil
-	}
-	v, err := Text(ctx, node)
-	if err != nil {
-		return nil, err
+	switch node.(type) {
+	case *goquery.Selection, *html.Node:
+		return Text(ctx, node)
+	default:
+		// the results of the functions, such as the count and the attrs
+		return node, nil
 	}
-	return v, nil
 }
 
 func element(_ context.Context, node any, _ ...string) (any, error) {
 	switch t := node.(type) {
 	default:
 		return nil, fmt.Errorf("unexpected type %T", node)
-	case string, []string, *html.Node, ski.Iterator, nil:
+	case string, []string, *html.Node, ski.Iterator, int, map[string]string, []map[string]string, nil:
 		return t, nil
 	case *goquery.Selection:
 		if len(t.Nodes) == 0 {
@@ -162,7 +166,7 @@ func elements(_ context.Context, node any, _ ...string) (any, error) {
 	switch t := node.(type) {
 	default:
 		return nil, fmt.Errorf("unexpected type %T", node)
-	case string, []string, *html.Node, ski.Iterator, nil:
+	case string, []string, *html.Node, ski.Iterator, int, map[string]string, []map[string]string, nil:
 		return t, nil
 	case *goquery.Selection:
 		return ski.NewIterator(t.Nodes), nil
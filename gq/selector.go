@@ -0,0 +1,875 @@
+package gq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// elementPseudoClass the extension pseudo-class matches each element alone,
+// which is the cascadia.Sel combined with the selectors compiled by cascadia.
+type elementPseudoClass func(arg string) (cascadia.Sel, error)
+
+// positionalPseudoClass the jQuery positional pseudo-class selects from the set of the
+// matched elements, such as :first selects the first element of the set. It can not be
+// the cascadia.Sel, which matches each element without the others.
+type positionalPseudoClass func(arg string) (func([]*html.Node) []*html.Node, error)
+
+// elementPseudoClasses the extension pseudo-classes match each element, which are not supported by cascadia.
+// The :contains(), :containsOwn(), :matches(regex), :matchesOwn(regex)
+// and the attribute regex [attr#=(regex)] are supported by cascadia.
+var elementPseudoClasses = map[string]elementPseudoClass{
+	"has-text": hasTextPseudo,
+	"visible":  visiblePseudo(true),
+	"hidden":   visiblePseudo(false),
+}
+
+// positionalPseudoClasses the jQuery positional pseudo-classes.
+var positionalPseudoClasses = map[string]positionalPseudoClass{
+	"eq":    eqPseudo,
+	"lt":    indexPseudo(func(i, n int) bool { return i < n }),
+	"gt":    indexPseudo(func(i, n int) bool { return i > n }),
+	"first": noArgPseudo(func(nodes []*html.Node) []*html.Node { return nodes[:min(len(nodes), 1)] }),
+	"last":  noArgPseudo(func(nodes []*html.Node) []*html.Node { return nodes[max(len(nodes)-1, 0):] }),
+}
+
+func isPseudoClass(name string) bool {
+	return elementPseudoClasses[name] != nil || positionalPseudoClasses[name] != nil
+}
+
+func isPositionalPseudoClass(name string) bool {
+	return positionalPseudoClasses[name] != nil
+}
+
+// compileSelector compiles the selector. The selector without the extension pseudo-classes
+// is compiled by cascadia, the selector with the element extensions is the cascadia.Selector
+// of the cascadia.Sel implementations. The selector with the positional extensions evaluates
+// the compound selectors from left to right, the positional pseudo-classes such as :first
+// and :eq(n) select from all the matched elements like jQuery.
+func compileSelector(sel string) (goquery.Matcher, error) {
+	if findPseudoClass(sel, isPseudoClass) == "" {
+		return cascadia.Compile(sel)
+	}
+	ret, err := compileGroups(sel)
+	if err != nil {
+		return nil, fmt.Errorf("selector %s: %w", sel, err)
+	}
+	if !ret.positional() {
+		return cascadia.Selector(ret.sel().Match), nil
+	}
+	return ret, nil
+}
+
+func compileGroups(sel string) (selector, error) {
+	var ret selector
+	for _, group := range splitSelector(sel, isGroupSeparator) {
+		c, err := compileComplex(group)
+		if err != nil {
+			return ret, err
+		}
+		ret.groups = append(ret.groups, c)
+	}
+	return ret, nil
+}
+
+// findPseudoClass returns the name of the first extension pseudo-class of the selector
+// which is reported by the fn, including the nested in the arguments such as :not(:first).
+func findPseudoClass(sel string, fn func(string) bool) (name string) {
+	scanSelector(sel, func(i, _ int) bool {
+		if sel[i] == ':' {
+			if n, _ := pseudoName(sel, i); fn(n) {
+				name = n
+			}
+		}
+		return name == ""
+	})
+	return
+}
+
+// scanSelector calls fn with the index and the parentheses depth of each byte
+// outside the quotes, the brackets and the escapes, stops if fn returns false.
+// The depth of the parentheses is the depth outside them.
+func scanSelector(sel string, fn func(i, depth int) bool) {
+	var quote byte
+	depth, bracket := 0, 0
+	for i := 0; i < len(sel); i++ {
+		ch := sel[i]
+		switch {
+		case ch == '\\':
+			i++
+			continue
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+			continue
+		case ch == '"' || ch == '\'':
+			quote = ch
+			continue
+		case ch == '[':
+			bracket++
+			continue
+		case ch == ']':
+			bracket--
+			continue
+		case bracket > 0:
+			continue
+		case ch == ')':
+			depth--
+		}
+		if !fn(i, depth) {
+			return
+		}
+		if ch == '(' {
+			depth++
+		}
+	}
+}
+
+// pseudoName returns the lower case pseudo-class name after the colon at i, and the end index.
+func pseudoName(sel string, i int) (string, int) {
+	j := i + 1
+	for j < len(sel) && (sel[j] == '-' || sel[j] == '_' ||
+		'a' <= sel[j] && sel[j] <= 'z' || 'A' <= sel[j] && sel[j] <= 'Z' || '0' <= sel[j] && sel[j] <= '9') {
+		j++
+	}
+	if j == i+1 {
+		return "", j
+	}
+	return strings.ToLower(sel[i+1 : j]), j
+}
+
+func isGroupSeparator(ch byte) bool { return ch == ',' }
+
+func isCombinator(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == '\f' || ch == '>' || ch == '+' || ch == '~'
+}
+
+// splitSelector splits the selector by the top level separators, the separators are kept
+// at the start of the parts except the group separators.
+func splitSelector(sel string, sep func(byte) bool) []string {
+	var parts []string
+	start := 0
+	scanSelector(sel, func(i, depth int) bool {
+		if depth == 0 && sep(sel[i]) && (i == 0 || !sep(sel[i-1])) {
+			parts = append(parts, sel[start:i])
+			start = i
+			if isGroupSeparator(sel[i]) {
+				start++
+			}
+		}
+		return true
+	})
+	return append(parts, sel[start:])
+}
+
+// combinator the relation to the elements matched by the previous compound selector.
+type combinator byte
+
+const (
+	descendant combinator = ' '
+	child      combinator = '>'
+	adjacent   combinator = '+'
+	sibling    combinator = '~'
+)
+
+// compound the compound selector with the extension pseudo-classes.
+type compound struct {
+	combinator combinator
+	sel        cascadia.Sel
+	// filters the positional pseudo-classes and the pseudo-classes after them.
+	filters []func([]*html.Node) []*html.Node
+}
+
+// complexSelector the compound selectors separated by the combinators.
+type complexSelector []compound
+
+func compileComplex(sel string) (complexSelector, error) {
+	var ret complexSelector
+	for _, part := range splitSelector(strings.TrimSpace(sel), isCombinator) {
+		comb := descendant
+		part = strings.TrimSpace(part)
+		if part != "" && (part[0] == '>' || part[0] == '+' || part[0] == '~') {
+			comb = combinator(part[0])
+			part = strings.TrimSpace(part[1:])
+		}
+		if part == "" {
+			if comb == descendant {
+				continue
+			}
+			return nil, fmt.Errorf("expected selector after %c", comb)
+		}
+		c, err := compileCompound(part)
+		if err != nil {
+			return nil, err
+		}
+		c.combinator = comb
+		ret = append(ret, c)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("expected selector")
+	}
+	return ret, nil
+}
+
+// compileCompound extracts the extension pseudo-classes of the compound selector,
+// the rest is compiled by cascadia. The :not() and :has() are extracted only if
+// the argument has the extension pseudo-classes.
+func compileCompound(sel string) (c compound, err error) {
+	var (
+		rest  strings.Builder
+		sels  []cascadia.Sel
+		start int
+		errs  error
+	)
+	scanSelector(sel, func(i, depth int) bool {
+		if depth != 0 || sel[i] != ':' || i < start {
+			return true
+		}
+		name, end := pseudoName(sel, i)
+		if !isPseudoClass(name) && name != "not" && name != "has" {
+			return true
+		}
+		var arg string
+		if end < len(sel) && sel[end] == '(' {
+			closing := matchParenthesis(sel, end)
+			if closing < 0 {
+				errs = fmt.Errorf("unclosed parenthesis of :%s", name)
+				return false
+			}
+			arg = unquote(strings.TrimSpace(sel[end+1 : closing]))
+			end = closing + 1
+		}
+
+		var (
+			m      cascadia.Sel
+			filter func([]*html.Node) []*html.Node
+			err    error
+		)
+		switch {
+		case isPositionalPseudoClass(name):
+			filter, err = positionalPseudoClasses[name](arg)
+		case elementPseudoClasses[name] != nil:
+			m, err = elementPseudoClasses[name](arg)
+		case findPseudoClass(arg, isPseudoClass) == "":
+			// compiled by cascadia
+			return true
+		case findPseudoClass(arg, isPositionalPseudoClass) != "":
+			if name == "not" {
+				filter, err = notPositionalPseudo(arg)
+			} else {
+				filter, err = hasPositionalPseudo(arg)
+			}
+		default:
+			m, err = relativePseudo(name, arg)
+		}
+		if err != nil {
+			errs = fmt.Errorf(":%s %w", name, err)
+			return false
+		}
+		switch {
+		case filter != nil:
+			c.filters = append(c.filters, filter)
+		case len(c.filters) > 0:
+			// after the positional pseudo-classes, such as li:first:has-text(a)
+			c.filters = append(c.filters, nodeFilter(m.Match))
+		default:
+			sels = append(sels, m)
+		}
+		rest.WriteString(sel[start:i])
+		start = end
+		return true
+	})
+	if errs != nil {
+		return c, errs
+	}
+	rest.WriteString(sel[start:])
+	s := rest.String()
+	if name := findPseudoClass(s, isPseudoClass); name != "" {
+		return c, fmt.Errorf(":%s is only supported in :not() and :has() of the nested selectors", name)
+	}
+	if s == "" {
+		s = "*"
+	}
+	if c.sel, err = cascadia.Parse(s); err != nil {
+		return c, err
+	}
+	if len(sels) > 0 {
+		c.sel = compoundSel(append([]cascadia.Sel{c.sel}, sels...))
+	}
+	return c, nil
+}
+
+// matchParenthesis returns the index of the closing parenthesis of the open parenthesis at i.
+func matchParenthesis(sel string, i int) int {
+	closing := -1
+	scanSelector(sel[i:], func(j, depth int) bool {
+		if depth == 0 && sel[i+j] == ')' {
+			closing = i + j
+			return false
+		}
+		return true
+	})
+	return closing
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// match returns the elements matched by the selector in the roots and their descendants.
+func (s complexSelector) match(roots []*html.Node) []*html.Node {
+	var nodes []*html.Node
+	for i, c := range s {
+		var candidates []*html.Node
+		if i == 0 {
+			for _, root := range roots {
+				candidates = appendMatched(candidates, root, c.sel, true)
+			}
+		} else {
+			for _, n := range nodes {
+				switch c.combinator {
+				case descendant:
+					for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+						candidates = appendMatched(candidates, ch, c.sel, true)
+					}
+				case child:
+					for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+						candidates = appendMatched(candidates, ch, c.sel, false)
+					}
+				case adjacent:
+					if next := nextElement(n); next != nil {
+						candidates = appendMatched(candidates, next, c.sel, false)
+					}
+				case sibling:
+					for next := nextElement(n); next != nil; next = nextElement(next) {
+						candidates = appendMatched(candidates, next, c.sel, false)
+					}
+				}
+			}
+		}
+		nodes = documentOrder(candidates)
+		for _, filter := range c.filters {
+			if len(nodes) == 0 {
+				break
+			}
+			nodes = filter(nodes)
+		}
+		if len(nodes) == 0 {
+			return nil
+		}
+	}
+	return nodes
+}
+
+func appendMatched(nodes []*html.Node, n *html.Node, sel cascadia.Sel, deep bool) []*html.Node {
+	if n.Type != html.ElementNode {
+		return nodes
+	}
+	if sel.Match(n) {
+		nodes = append(nodes, n)
+	}
+	if deep {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			nodes = appendMatched(nodes, ch, sel, true)
+		}
+	}
+	return nodes
+}
+
+func prevElement(n *html.Node) *html.Node {
+	for n = n.PrevSibling; n != nil; n = n.PrevSibling {
+		if n.Type == html.ElementNode {
+			return n
+		}
+	}
+	return nil
+}
+
+func nextElement(n *html.Node) *html.Node {
+	for n = n.NextSibling; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode {
+			return n
+		}
+	}
+	return nil
+}
+
+// documentOrder removes the duplicate nodes and sorts the nodes in the document order.
+func documentOrder(nodes []*html.Node) []*html.Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	set := make(map[*html.Node]struct{}, len(nodes))
+	var roots []*html.Node
+	for _, n := range nodes {
+		set[n] = struct{}{}
+		root := n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		if !containsNode(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	ret := make([]*html.Node, 0, len(set))
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if _, ok := set[n]; ok {
+			ret = append(ret, n)
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return ret
+}
+
+func containsNode(nodes []*html.Node, n *html.Node) bool {
+	for _, node := range nodes {
+		if node == n {
+			return true
+		}
+	}
+	return false
+}
+
+// positional reports whether the compound selectors have the positional pseudo-classes.
+func (s complexSelector) positional() bool {
+	for _, c := range s {
+		if len(c.filters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sel returns the cascadia.Sel of the complex selector without the positional pseudo-classes.
+func (s complexSelector) sel() cascadia.Sel {
+	ret := s[0].sel
+	for _, c := range s[1:] {
+		ret = combinedSel{first: ret, combinator: c.combinator, second: c.sel}
+	}
+	return ret
+}
+
+// selector the selector group with the positional pseudo-classes, implements goquery.Matcher.
+type selector struct {
+	groups []complexSelector
+}
+
+// positional reports whether the selector has the positional pseudo-classes.
+func (s selector) positional() bool {
+	for _, c := range s.groups {
+		if c.positional() {
+			return true
+		}
+	}
+	return false
+}
+
+// sel returns the cascadia.Sel of the selector without the positional pseudo-classes.
+func (s selector) sel() cascadia.SelectorGroup {
+	ret := make(cascadia.SelectorGroup, 0, len(s.groups))
+	for _, c := range s.groups {
+		ret = append(ret, c.sel())
+	}
+	return ret
+}
+
+// find returns the matched descendants of the nodes.
+func (s selector) find(nodes []*html.Node) []*html.Node {
+	var roots []*html.Node
+	for _, n := range nodes {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type == html.ElementNode {
+				roots = append(roots, ch)
+			}
+		}
+	}
+	return s.matchAll(roots)
+}
+
+func (s selector) matchAll(roots []*html.Node) []*html.Node {
+	var ret []*html.Node
+	for _, c := range s.groups {
+		ret = append(ret, c.match(roots)...)
+	}
+	if len(s.groups) > 1 {
+		ret = documentOrder(ret)
+	}
+	return ret
+}
+
+// matchRoot returns the matched elements of the root of the document.
+func (s selector) matchRoot(root *html.Node) []*html.Node {
+	if root.Type == html.ElementNode {
+		return s.matchAll([]*html.Node{root})
+	}
+	// the document node
+	return s.find([]*html.Node{root})
+}
+
+// Match reports whether the node is matched in its document, the whole document
+// is matched for each call.
+func (s selector) Match(n *html.Node) bool {
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return containsNode(s.matchRoot(root), n)
+}
+
+// MatchAll returns the matched elements of the node and its descendants.
+func (s selector) MatchAll(n *html.Node) []*html.Node {
+	return s.matchAll([]*html.Node{n})
+}
+
+// Filter returns the matched nodes, the compound selector filters the nodes as the set,
+// such as :first returns the first node.
+func (s selector) Filter(nodes []*html.Node) []*html.Node {
+	var ret []*html.Node
+	for _, c := range s.groups {
+		if len(c) == 1 {
+			matched := make([]*html.Node, 0, len(nodes))
+			for _, n := range nodes {
+				if c[0].sel.Match(n) {
+					matched = append(matched, n)
+				}
+			}
+			for _, filter := range c[0].filters {
+				if len(matched) == 0 {
+					break
+				}
+				matched = filter(matched)
+			}
+			ret = append(ret, matched...)
+			continue
+		}
+		// the match sets of the roots of the nodes
+		group := selector{groups: []complexSelector{c}}
+		sets := make(map[*html.Node]map[*html.Node]struct{})
+		for _, n := range nodes {
+			root := n
+			for root.Parent != nil {
+				root = root.Parent
+			}
+			set, ok := sets[root]
+			if !ok {
+				matched := group.matchRoot(root)
+				set = make(map[*html.Node]struct{}, len(matched))
+				for _, m := range matched {
+					set[m] = struct{}{}
+				}
+				sets[root] = set
+			}
+			if _, ok = set[n]; ok {
+				ret = append(ret, n)
+			}
+		}
+	}
+	if len(s.groups) > 1 {
+		ret = documentOrder(ret)
+	}
+	return ret
+}
+
+// notPositionalPseudo :not(selector) removes the elements matched by the selector with the
+// positional pseudo-classes, the selector filters the elements as the set, such as li:not(:first).
+func notPositionalPseudo(arg string) (func([]*html.Node) []*html.Node, error) {
+	sel, err := compileGroups(arg)
+	if err != nil {
+		return nil, err
+	}
+	return func(nodes []*html.Node) []*html.Node {
+		excluded := sel.Filter(nodes)
+		ret := make([]*html.Node, 0, len(nodes))
+		for _, n := range nodes {
+			if !containsNode(excluded, n) {
+				ret = append(ret, n)
+			}
+		}
+		return ret
+	}, nil
+}
+
+// hasPositionalPseudo :has(selector) matches the elements have the descendants matched by the
+// selector with the positional pseudo-classes, the selector finds in each element, such as div:has(p:eq(1)).
+func hasPositionalPseudo(arg string) (func([]*html.Node) []*html.Node, error) {
+	if isRelative(arg) {
+		return nil, fmt.Errorf("the relative selector %s is not supported", arg)
+	}
+	sel, err := compileGroups(arg)
+	if err != nil {
+		return nil, err
+	}
+	return nodeFilter(func(n *html.Node) bool {
+		return len(sel.find([]*html.Node{n})) > 0
+	}), nil
+}
+
+func isRelative(sel string) bool {
+	return sel != "" && (sel[0] == '>' || sel[0] == '+' || sel[0] == '~')
+}
+
+// pseudoSpecificity the specificity of the pseudo-class.
+var pseudoSpecificity = cascadia.Specificity{0, 1, 0}
+
+// compoundSel matches the element matched by all the selectors.
+type compoundSel []cascadia.Sel
+
+func (s compoundSel) Match(n *html.Node) bool {
+	for _, sel := range s {
+		if !sel.Match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s compoundSel) Specificity() (ret cascadia.Specificity) {
+	for _, sel := range s {
+		ret = ret.Add(sel.Specificity())
+	}
+	return
+}
+
+func (s compoundSel) String() string {
+	var b strings.Builder
+	for _, sel := range s {
+		b.WriteString(sel.String())
+	}
+	return b.String()
+}
+
+func (s compoundSel) PseudoElement() string { return "" }
+
+// combinedSel matches the element matched by the second, which has the relation
+// of the combinator to an element matched by the first.
+type combinedSel struct {
+	first      cascadia.Sel
+	combinator combinator
+	second     cascadia.Sel
+}
+
+func (s combinedSel) Match(n *html.Node) bool {
+	if !s.second.Match(n) {
+		return false
+	}
+	switch s.combinator {
+	case descendant:
+		for p := n.Parent; p != nil; p = p.Parent {
+			if s.first.Match(p) {
+				return true
+			}
+		}
+	case child:
+		return n.Parent != nil && s.first.Match(n.Parent)
+	case adjacent:
+		prev := prevElement(n)
+		return prev != nil && s.first.Match(prev)
+	case sibling:
+		for prev := prevElement(n); prev != nil; prev = prevElement(prev) {
+			if s.first.Match(prev) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s combinedSel) Specificity() cascadia.Specificity {
+	return s.first.Specificity().Add(s.second.Specificity())
+}
+
+func (s combinedSel) String() string {
+	if s.combinator == descendant {
+		return s.first.String() + " " + s.second.String()
+	}
+	return s.first.String() + " " + string(s.combinator) + " " + s.second.String()
+}
+
+func (s combinedSel) PseudoElement() string { return "" }
+
+// pseudoSel the extension pseudo-class matches each element.
+type pseudoSel struct {
+	name, arg string
+	match     func(*html.Node) bool
+}
+
+func (s pseudoSel) Match(n *html.Node) bool {
+	return n.Type == html.ElementNode && s.match(n)
+}
+
+func (s pseudoSel) Specificity() cascadia.Specificity { return pseudoSpecificity }
+
+func (s pseudoSel) String() string {
+	if s.arg == "" && s.name != "not" && s.name != "has" {
+		return ":" + s.name
+	}
+	return ":" + s.name + "(" + s.arg + ")"
+}
+
+func (s pseudoSel) PseudoElement() string { return "" }
+
+// relativePseudo :not(selector) and :has(selector) with the element extension pseudo-classes,
+// such as li:not(:has-text(go)) and div:has(a:visible).
+func relativePseudo(name, arg string) (cascadia.Sel, error) {
+	if name == "has" && isRelative(arg) {
+		return nil, fmt.Errorf("the relative selector %s is not supported", arg)
+	}
+	sel, err := compileGroups(arg)
+	if err != nil {
+		return nil, err
+	}
+	group := sel.sel()
+	if name == "not" {
+		return pseudoSel{name, arg, func(n *html.Node) bool { return !group.Match(n) }}, nil
+	}
+	return pseudoSel{name, arg, func(n *html.Node) bool { return hasDescendant(n, group) }}, nil
+}
+
+// hasDescendant reports whether the node has the descendant matched by the matcher.
+func hasDescendant(n *html.Node, m cascadia.Matcher) bool {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && (m.Match(ch) || hasDescendant(ch, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func noArgPseudo(fn func([]*html.Node) []*html.Node) positionalPseudoClass {
+	return func(arg string) (func([]*html.Node) []*html.Node, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("unexpected argument %s", arg)
+		}
+		return fn, nil
+	}
+}
+
+func nodeFilter(fn func(*html.Node) bool) func([]*html.Node) []*html.Node {
+	return func(nodes []*html.Node) []*html.Node {
+		ret := make([]*html.Node, 0, len(nodes))
+		for _, n := range nodes {
+			if fn(n) {
+				ret = append(ret, n)
+			}
+		}
+		return ret
+	}
+}
+
+// hasTextPseudo :has-text(text) matches the elements contain the text,
+// case-insensitive and the whitespaces are collapsed.
+func hasTextPseudo(arg string) (cascadia.Sel, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("requires the text")
+	}
+	text := strings.ToLower(normalizeSpace(arg))
+	return pseudoSel{"has-text", arg, func(n *html.Node) bool {
+		return strings.Contains(strings.ToLower(normalizeSpace(goquery.NewDocumentFromNode(n).Text())), text)
+	}}, nil
+}
+
+// normalizeSpace trims and collapses the whitespaces.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// eqPseudo :eq(n) matches the element at the index, the negative index counts from the last.
+func eqPseudo(arg string) (func([]*html.Node) []*html.Node, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("requires the int index: %w", err)
+	}
+	return func(nodes []*html.Node) []*html.Node {
+		i := n
+		if i < 0 {
+			i += len(nodes)
+		}
+		if i < 0 || i >= len(nodes) {
+			return nil
+		}
+		return nodes[i : i+1]
+	}, nil
+}
+
+// indexPseudo :lt(n) and :gt(n) match the elements by the index.
+func indexPseudo(fn func(i, n int) bool) positionalPseudoClass {
+	return func(arg string) (func([]*html.Node) []*html.Node, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("requires the int index: %w", err)
+		}
+		return func(nodes []*html.Node) []*html.Node {
+			ret := make([]*html.Node, 0, len(nodes))
+			for i, node := range nodes {
+				if fn(i, n) {
+					ret = append(ret, node)
+				}
+			}
+			return ret
+		}, nil
+	}
+}
+
+// hiddenTags the elements are never rendered.
+var hiddenTags = map[string]struct{}{
+	"head": {}, "script": {}, "style": {}, "template": {}, "noscript": {},
+	"meta": {}, "link": {}, "title": {}, "base": {},
+}
+
+// visiblePseudo :visible and :hidden match the elements by the heuristics, the element
+// is hidden if itself or its ancestor is the hidden tag, the hidden input, has the hidden
+// attribute, the aria-hidden="true" attribute, or the display: none or visibility: hidden style.
+func visiblePseudo(visible bool) elementPseudoClass {
+	name := "hidden"
+	if visible {
+		name = "visible"
+	}
+	return func(arg string) (cascadia.Sel, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("unexpected argument %s", arg)
+		}
+		return pseudoSel{name, "", func(n *html.Node) bool { return isHidden(n) != visible }}, nil
+	}
+}
+
+func isHidden(n *html.Node) bool {
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if _, ok := hiddenTags[n.Data]; ok {
+			return true
+		}
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "hidden":
+				return true
+			case "aria-hidden":
+				if strings.EqualFold(attr.Val, "true") {
+					return true
+				}
+			case "type":
+				if n.Data == "input" && strings.EqualFold(attr.Val, "hidden") {
+					return true
+				}
+			case "style":
+				style := strings.ToLower(strings.Join(strings.Fields(attr.Val), ""))
+				if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package gq
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestPseudoClasses(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `li:has-text(GOLANG) -> attr(id)`, "a3")
+	assertValue(t, `li:has-text("  go  ") -> attr(id)`, []string{"a1", "a3"})
+	assertValue(t, `#foot div:contains(f5) -> attr(id)`, "nf5")
+	assertValue(t, `.body a:matches(^G.+b$) -> text`, "Github")
+	assertValue(t, `.body a[href#=(^/)] -> text`, "Home")
+
+	assertValue(t, `li:first -> attr(id)`, "a1")
+	assertValue(t, `li:last -> attr(id)`, "a4")
+	assertValue(t, `.row:eq(7) -> text`, "f2")
+	assertValue(t, `.row:eq(-1) -> text`, "f6")
+	assertValue(t, `.row:eq(20) -> text`, nil)
+	assertValue(t, `#main .row:gt(3) -> text`, []string{"5", "6"})
+	assertValue(t, `#main .row:lt(2), #foot .row:first -> text`, []string{"1", "2", "f1"})
+	assertValue(t, `.odd:first + div -> text`, "3")
+	assertValue(t, `li:has-text(git) ~ li:last > a -> text`, "Home")
+	assertValue(t, `div:has-text(golang):last > ul > :eq(1) a -> text`, "Github")
+
+	assertValue(t, `title:visible`, nil)
+	assertValue(t, `title:hidden`, "Tests for siblings")
+	assertValue(t, `script:hidden -> attr(type)`, "text/javascript")
+
+	assertError(t, `li:first -> href`, "href attribute's value is not exist")
+}
+
+func TestNestedPseudoClasses(t *testing.T) {
+	t.Parallel()
+	assertValue(t, `li:not(:first) -> attr(id)`, []string{"a2", "a3", "a4"})
+	assertValue(t, `li:not(:first, :last) -> attr(id)`, []string{"a2", "a3"})
+	assertValue(t, `li:not(:has-text(go)) -> attr(id)`, []string{"a2", "a4"})
+	assertValue(t, `li:not(.selected):last -> attr(id)`, "a4")
+	assertValue(t, `#foot .row:not(.odd:gt(1)) -> attr(id)`, []string{"nf1", "nf2", "nf3", "nf4", "nf5"})
+	assertValue(t, `div:has(li:eq(2)) -> attr(class)`, "body")
+	assertValue(t, `div:has(.row:eq(5)) -> attr(id)`, []string{"main", "foot"})
+	assertValue(t, `div:has(.row:eq(6)) -> attr(id)`, nil)
+	assertValue(t, `div:has(a:has-text(home)) > ul -> attr(id)`, "url")
+	assertValue(t, `li:has(a:not(:first)) -> attr(id)`, nil)
+	assertValue(t, `.body a -> closest(li:not(:first)) -> attr(id)`, []string{"a2", "a3", "a4"})
+	assertValue(t, `.row -> filter('#foot .row:first, #main > .row:last') -> attr(id)`, []string{"n6", "nf1"})
+}
+
+func TestVisiblePseudoClass(t *testing.T) {
+	t.Parallel()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div>
+<p id="p1">1</p>
+<p id="p2" hidden>2</p>
+<p id="p3" style="DISPLAY: none">3</p>
+<div aria-hidden="true"><p id="p4">4</p></div>
+<p id="p5" style="visibility:hidden">5</p>
+<input id="p6" type="hidden">
+<input id="p7" type="text">
+</div>`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	m, err := compileSelector(`p:visible, input:visible`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	visible := doc.FindMatcher(m).Map(func(_ int, s *goquery.Selection) string { return s.AttrOr("id", "") })
+	assert.Equal(t, []string{"p1", "p7"}, visible)
+
+	m, err = compileSelector(`:hidden`)
+	if assert.NoError(t, err) {
+		hidden := doc.Find("p, input").FilterMatcher(m).Map(func(_ int, s *goquery.Selection) string { return s.AttrOr("id", "") })
+		assert.Equal(t, []string{"p2", "p3", "p4", "p5", "p6"}, hidden)
+	}
+}
+
+func TestCompileSelector(t *testing.T) {
+	t.Parallel()
+	for _, sel := range []string{`li:eq(a)`, `li:first(1)`, `li:has-text()`, `li:has-text(a`, `li >`, `li:eq(1):unknown`,
+		`li:not(:eq(a))`, `div:has(> li:first)`} {
+		_, err := compileSelector(sel)
+		assert.Error(t, err, sel)
+	}
+	_, err := compileSelector(`li:is(:first)`)
+	assert.ErrorContains(t, err, ":first is only supported in :not() and :has()")
+
+	// the element extensions are the cascadia.Sel
+	m, err := compileSelector(`ul > li:nth-child(2n+1):has-text("a, b") ~ :not(:visible), div:has(a:hidden)`)
+	if assert.NoError(t, err) {
+		assert.IsType(t, cascadia.Selector(nil), m)
+	}
+	sel, err := compileGroups(`ul > li:nth-child(2n+1):has-text("a, b") ~ :not(:visible), div:has(a:hidden)`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `ul > li:nth-child(2n+1):has-text(a, b) ~ *:not(:visible)`, sel.sel()[0].String())
+		assert.Equal(t, `div:has(a:hidden)`, sel.sel()[1].String())
+	}
+
+	m, err = compileSelector(`li:has-text(a):first`)
+	if assert.NoError(t, err) {
+		assert.IsType(t, selector{}, m)
+	}
+
+	m, err = compileSelector(`li[title=":first"], a:first-child`)
+	if assert.NoError(t, err) {
+		_, ok := m.(selector)
+		assert.False(t, ok)
+	}
+}
+
+func TestSelectorMatch(t *testing.T) {
+	t.Parallel()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if !assert.NoError(t, err) {
+		return
+	}
+	m, err := compileSelector(`#foot .row:first, li:not(:first)`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	nodes := doc.Find(".row, li").Nodes
+	var matched []string
+	for _, n := range nodes {
+		if m.Match(n) {
+			matched = append(matched, goquery.NewDocumentFromNode(n).AttrOr("id", ""))
+		}
+	}
+	assert.Equal(t, []string{"a2", "a3", "a4", "nf1"}, matched)
+
+	other, _ := goquery.NewDocumentFromReader(strings.NewReader(`<ul><li id="b1"></li><li id="b2"></li></ul>`))
+	li := other.Find("li").Nodes
+	assert.False(t, m.Match(li[0]))
+	assert.True(t, m.Match(li[1]))
+
+	// the modified document is matched again
+	other.Find("#b1").Remove()
+	assert.False(t, m.Match(li[1]))
+	assert.Equal(t, []*html.Node{li[1]}, m.Filter(append(li, li[1])))
+	assert.Empty(t, m.Filter([]*html.Node{li[0]}))
+}
+
+func TestPositionalOrder(t *testing.T) {
+	t.Parallel()
+	// the pseudo-classes after the positional filter the selected elements
+	assertValue(t, `li:has-text(golang):first -> attr(id)`, "a3")
+	assertValue(t, `li:first:has-text(golang) -> attr(id)`, nil)
+	assertValue(t, `li:first:has-text(go) -> attr(id)`, "a1")
+}
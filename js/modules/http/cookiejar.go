@@ -2,8 +2,10 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/grafana/sobek"
@@ -20,10 +22,13 @@ func (j *CookieJar) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
 		return nil, errors.New("CookieJar can not nil")
 	}
 	return rt.ToValue(map[string]func(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value{
-		"get":    j.Get,
-		"getAll": j.GetAll,
-		"set":    j.Set,
-		"del":    j.Del,
+		"get":       j.Get,
+		"getAll":    j.GetAll,
+		"set":       j.Set,
+		"del":       j.Del,
+		"clear":     j.Clear,
+		"parse":     j.Parse,
+		"stringify": j.Stringify,
 	}), nil
 }
 
@@ -37,33 +42,65 @@ func (j *CookieJar) Get(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value
 	if err != nil {
 		js.Throw(rt, err)
 	}
-	cookies := j.CookieJar.Cookies(u)
+	cookies := j.cookies(u)
 	name := opt["name"]
 	for _, cookie := range cookies {
 		if cookie.Name == name {
 			return toObj(cookie, rt)
 		}
 	}
-	if len(cookies) > 0 {
+	if len(cookies) > 0 && name == "" {
 		return toObj(cookies[0], rt)
 	}
 	return sobek.Null()
 }
 
 // GetAll returns the cookies for the given option.
+// If the url is present returns the cookies would be sent to the url,
+// if the domain is present returns the cookies of the domain and its subdomains,
+// if the name is present returns the cookies with the name,
+// without option returns all cookies.
 func (j *CookieJar) GetAll(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
-	opt, err := cast.ToStringMapStringE(call.Argument(0).Export())
-	if err != nil {
-		js.Throw(rt, errors.New("getAll parameter must be an object containing name, url"))
+	var opt map[string]string
+	if arg := call.Argument(0); !sobek.IsUndefined(arg) && !sobek.IsNull(arg) {
+		var err error
+		opt, err = cast.ToStringMapStringE(arg.Export())
+		if err != nil {
+			js.Throw(rt, errors.New("getAll parameter must be an object containing url, domain, name"))
+		}
 	}
-	u, err := url.Parse(opt["url"])
-	if err != nil {
-		js.Throw(rt, err)
+
+	var cookies []*http.Cookie
+	if v, ok := opt["url"]; ok {
+		u, err := url.Parse(v)
+		if err != nil {
+			js.Throw(rt, err)
+		}
+		cookies = j.cookies(u)
+	} else {
+		cookies = j.enumerable(rt).AllCookies()
 	}
-	return toObjs(j.CookieJar.Cookies(u), rt)
+
+	domain := strings.TrimPrefix(strings.ToLower(opt["domain"]), ".")
+	name := opt["name"]
+	ret := cookies[:0]
+	for _, cookie := range cookies {
+		if name != "" && cookie.Name != name {
+			continue
+		}
+		if domain != "" {
+			d := strings.TrimPrefix(cookie.Domain, ".")
+			if d != domain && !strings.HasSuffix(d, "."+domain) {
+				continue
+			}
+		}
+		ret = append(ret, cookie)
+	}
+	return toObjs(ret, rt)
 }
 
 // Set handles the receipt of the cookies in a reply for the given option.
+// The cookie can be the cookie object, the Set-Cookie string or the array of them.
 func (j *CookieJar) Set(call sobek.FunctionCall, rt *sobek.Runtime) (ret sobek.Value) {
 	u, err := url.Parse(call.Argument(0).String())
 	if err != nil {
@@ -72,13 +109,19 @@ func (j *CookieJar) Set(call sobek.FunctionCall, rt *sobek.Runtime) (ret sobek.V
 	var cookies []*http.Cookie
 	switch e := call.Argument(1).Export().(type) {
 	case map[string]any:
-		cookies = append(cookies, toCookie(e))
+		cookies = append(cookies, toStoreCookie(e))
+	case string:
+		cookies = append(cookies, parseSetCookie(rt, e))
 	case []any:
-		for _, cookie := range cookies {
-			cookies = append(cookies, toCookie(cast.ToStringMap(cookie)))
+		for _, cookie := range e {
+			if s, ok := cookie.(string); ok {
+				cookies = append(cookies, parseSetCookie(rt, s))
+				continue
+			}
+			cookies = append(cookies, toStoreCookie(cast.ToStringMap(cookie)))
 		}
 	default:
-		js.Throw(rt, errors.New("set second parameter must be cookie object"))
+		js.Throw(rt, errors.New("set second parameter must be cookie object or Set-Cookie string"))
 	}
 	if len(cookies) == 0 {
 		return sobek.Undefined()
@@ -89,15 +132,93 @@ func (j *CookieJar) Set(call sobek.FunctionCall, rt *sobek.Runtime) (ret sobek.V
 }
 
 // Del handles the receipt of the cookies in a reply for the given URL.
+// If the second parameter name is present only delete the cookie with the name.
 func (j *CookieJar) Del(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
 	u, err := url.Parse(call.Argument(0).String())
 	if err != nil {
 		js.Throw(rt, err)
 	}
+	if name := call.Argument(1); !sobek.IsUndefined(name) {
+		for _, cookie := range j.cookies(u) {
+			if cookie.Name == name.String() {
+				cookie.MaxAge = -1
+				if cookie.Domain == "" {
+					// the cookie of the jar can not enumerate the cookies has no attributes
+					j.CookieJar.SetCookies(u, []*http.Cookie{cookie})
+				} else {
+					ski.SetCookie(j.CookieJar, cookie)
+				}
+			}
+		}
+		return sobek.Undefined()
+	}
 	j.CookieJar.RemoveCookie(u)
 	return sobek.Undefined()
 }
 
+// Clear removes the cookies of the given domain and its subdomains,
+// without domain removes all cookies.
+func (j *CookieJar) Clear(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
+	var domain string
+	if arg := call.Argument(0); !sobek.IsUndefined(arg) && !sobek.IsNull(arg) {
+		domain = arg.String()
+	}
+	ski.ClearCookies(j.enumerable(rt), domain)
+	return sobek.Undefined()
+}
+
+// Parse returns the cookie object of the Set-Cookie string.
+func (j *CookieJar) Parse(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
+	return toObj(parseSetCookie(rt, call.Argument(0).String()), rt)
+}
+
+// Stringify returns the Set-Cookie string of the cookie object.
+func (j *CookieJar) Stringify(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
+	o, err := cast.ToStringMapE(call.Argument(0).Export())
+	if err != nil {
+		js.Throw(rt, errors.New("stringify parameter must be cookie object"))
+	}
+	return rt.ToValue(toCookie(o).String())
+}
+
+// enumerable returns the EnumerableCookieJar, throws if the jar can not enumerate the cookies.
+func (j *CookieJar) enumerable(rt *sobek.Runtime) ski.EnumerableCookieJar {
+	jar, ok := j.CookieJar.(ski.EnumerableCookieJar)
+	if !ok {
+		js.Throw(rt, fmt.Errorf("cookieJar %T can not enumerate the cookies", j.CookieJar))
+	}
+	return jar
+}
+
+// cookies returns the cookies with all attributes would be sent to the URL,
+// if the jar can not enumerate the cookies returns the cookies of the jar.
+func (j *CookieJar) cookies(u *url.URL) []*http.Cookie {
+	jar, ok := j.CookieJar.(ski.EnumerableCookieJar)
+	if !ok {
+		return j.CookieJar.Cookies(u)
+	}
+	var ret []*http.Cookie
+	for _, cookie := range jar.AllCookies() {
+		if ski.MatchCookie(cookie, u) {
+			ret = append(ret, cookie)
+		}
+	}
+	return ret
+}
+
+func parseSetCookie(rt *sobek.Runtime, str string) *http.Cookie {
+	cookies := (&http.Response{Header: http.Header{"Set-Cookie": {str}}}).Cookies()
+	if len(cookies) == 0 {
+		js.Throw(rt, errors.New("invalid Set-Cookie string"))
+	}
+	cookie := cookies[0]
+	if cookie.Domain != "" && !strings.HasPrefix(cookie.Domain, ".") {
+		// the Domain attribute present indicates a domain cookie
+		cookie.Domain = "." + cookie.Domain
+	}
+	return cookie
+}
+
 var sameSiteMapping = [...]string{
 	http.SameSiteDefaultMode: "",
 	http.SameSiteLaxMode:     "lax",
@@ -106,14 +227,24 @@ var sameSiteMapping = [...]string{
 }
 
 func toObj(cookie *http.Cookie, rt *sobek.Runtime) sobek.Value {
+	var expires int64
+	if !cookie.Expires.IsZero() {
+		expires = cookie.Expires.Unix()
+	}
 	o := rt.NewObject()
-	_ = o.Set("domain", rt.ToValue(cookie.Domain))
-	_ = o.Set("expires", rt.ToValue(cookie.Expires.Unix()))
+	_ = o.Set("domain", rt.ToValue(strings.TrimPrefix(cookie.Domain, ".")))
+	_ = o.Set("expires", rt.ToValue(expires))
 	_ = o.Set("name", rt.ToValue(cookie.Name))
 	_ = o.Set("path", rt.ToValue(cookie.Path))
 	_ = o.Set("sameSite", rt.ToValue(sameSiteMapping[cookie.SameSite]))
 	_ = o.Set("secure", rt.ToValue(cookie.Secure))
+	_ = o.Set("httpOnly", rt.ToValue(cookie.HttpOnly))
+	_ = o.Set("hostOnly", rt.ToValue(!strings.HasPrefix(cookie.Domain, ".")))
+	_ = o.Set("session", rt.ToValue(cookie.Expires.IsZero() && cookie.MaxAge == 0))
 	_ = o.Set("value", rt.ToValue(cookie.Value))
+	if cookie.MaxAge != 0 {
+		_ = o.Set("maxAge", rt.ToValue(cookie.MaxAge))
+	}
 	_ = o.Set("toString", func(sobek.FunctionCall) sobek.Value {
 		return rt.ToValue(cookie.String())
 	})
@@ -130,7 +261,7 @@ func toObjs(cookies []*http.Cookie, rt *sobek.Runtime) sobek.Value {
 
 func toCookie(o map[string]any) *http.Cookie {
 	var sameSite = http.SameSiteDefaultMode
-	switch cast.ToString(o["sameSite"]) {
+	switch strings.ToLower(cast.ToString(o["sameSite"])) {
 	case "lax":
 		sameSite = http.SameSiteLaxMode
 	case "strict":
@@ -138,13 +269,8 @@ func toCookie(o map[string]any) *http.Cookie {
 	case "none":
 		sameSite = http.SameSiteNoneMode
 	}
-	expires := cast.ToInt64(o["expires"])
-	if expires == 0 {
-		expires = time.Now().Add(time.Hour * 72).Unix()
-	}
-	return &http.Cookie{
+	cookie := &http.Cookie{
 		Domain:   cast.ToString(o["domain"]),
-		Expires:  time.Unix(expires, 0),
 		Name:     cast.ToString(o["name"]),
 		Path:     cast.ToString(o["path"]),
 		SameSite: sameSite,
@@ -153,4 +279,21 @@ func toCookie(o map[string]any) *http.Cookie {
 		Secure:   cast.ToBool(o["secure"]),
 		HttpOnly: cast.ToBool(o["httpOnly"]),
 	}
+	if cast.ToBool(o["hostOnly"]) {
+		cookie.Domain = ""
+	}
+	if expires := cast.ToInt64(o["expires"]); expires > 0 {
+		cookie.Expires = time.Unix(expires, 0)
+	}
+	return cookie
+}
+
+// toStoreCookie converts the object to cookie, the cookie without expires
+// will expire after 72 hours unless the session is true.
+func toStoreCookie(o map[string]any) *http.Cookie {
+	cookie := toCookie(o)
+	if cookie.Expires.IsZero() && cookie.MaxAge == 0 && !cast.ToBool(o["session"]) {
+		cookie.Expires = time.Now().Add(time.Hour * 72)
+	}
+	return cookie
 }
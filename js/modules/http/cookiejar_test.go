@@ -24,7 +24,7 @@ func TestCookie(t *testing.T) {
 		}
 		_ = rt.Set("cookieJar", instantiate)
 		client := http.Client{Jar: jar}
-		instance, _ := (&Http{&client}).Instantiate(rt)
+		instance, _ := (&Http{Fetch: &client}).Instantiate(rt)
 		_ = rt.Set("http", instance)
 	}))
 
@@ -52,3 +52,82 @@ func TestCookie(t *testing.T) {
 	`)
 	assert.NoError(t, err)
 }
+
+func TestCookieObject(t *testing.T) {
+	t.Parallel()
+	vm := modulestest.New(t, js.WithInitial(func(rt *sobek.Runtime) {
+		jar := CookieJar{ski.NewCookieJar()}
+		instantiate, err := jar.Instantiate(rt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rt.Set("cookieJar", instantiate)
+	}))
+
+	_, err := vm.RunString(context.Background(), `
+		cookieJar.set("https://www.example.com/", [
+			"sid=1; Domain=example.com; Path=/; Secure; HttpOnly; SameSite=Strict; Max-Age=3600",
+			{ name: "theme", value: "dark", path: "/", session: true },
+		]);
+		cookieJar.set("https://github.com/", { name: "foo", value: "bar", path: "/" });
+
+		const sid = cookieJar.get({ url: "https://api.example.com/", name: "sid" });
+		assert.equal(sid.domain, "example.com");
+		assert.true(!sid.hostOnly && sid.secure && sid.httpOnly && !sid.session);
+		assert.equal(sid.sameSite, "strict");
+		assert.true(sid.expires > Date.now() / 1000);
+		assert.true(!cookieJar.get({ url: "http://api.example.com/", name: "sid" }), "secure cookie over http");
+
+		const theme = cookieJar.get({ url: "https://www.example.com/", name: "theme" });
+		assert.true(theme.hostOnly && theme.session);
+		assert.equal(theme.expires, 0);
+
+		assert.equal(cookieJar.getAll().length, 3);
+		assert.equal(cookieJar.getAll({ domain: "example.com" }).length, 2);
+		assert.equal(cookieJar.getAll({ url: "https://github.com/" })[0].name, "foo");
+
+		const parsed = cookieJar.parse("lang=en; Path=/docs; HttpOnly");
+		assert.equal(parsed.name, "lang");
+		assert.equal(parsed.path, "/docs");
+		assert.true(parsed.httpOnly);
+		assert.equal(cookieJar.stringify(parsed), "lang=en; Path=/docs; HttpOnly");
+		assert.equal(cookieJar.stringify({ name: "a", value: "b", secure: true }), "a=b; Secure");
+		assert.true(!cookieJar.parse("c=d; Domain=example.com").hostOnly);
+
+		cookieJar.del("https://www.example.com/", "theme");
+		assert.equal(cookieJar.getAll({ domain: "example.com" }).length, 1);
+		cookieJar.clear("example.com");
+		assert.equal(cookieJar.getAll().length, 1);
+		cookieJar.clear();
+		assert.equal(cookieJar.getAll().length, 0);
+	`)
+	assert.NoError(t, err)
+}
+
+func TestCookieNotEnumerable(t *testing.T) {
+	t.Parallel()
+	vm := modulestest.New(t, js.WithInitial(func(rt *sobek.Runtime) {
+		// hides the AllCookies of the jar
+		jar := CookieJar{struct{ ski.CookieJar }{ski.NewCookieJar()}}
+		instantiate, err := jar.Instantiate(rt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = rt.Set("cookieJar", instantiate)
+	}))
+
+	_, err := vm.RunString(context.Background(), `
+		cookieJar.set("https://github.com", { name: "foo", value: "bar", path: "/" });
+		assert.equal(cookieJar.get({ url: "https://github.com" }).value, "bar");
+		assert.equal(cookieJar.getAll({ url: "https://github.com" }).length, 1);
+		cookieJar.del("https://github.com", "foo");
+		assert.true(!cookieJar.get({ url: "https://github.com" }), "cookie should be deleted");
+		try {
+			cookieJar.getAll();
+			assert.true(false, "should throw");
+		} catch (e) {
+			assert.true(String(e).includes("can not enumerate the cookies"), String(e));
+		}
+	`)
+	assert.NoError(t, err)
+}
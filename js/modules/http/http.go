@@ -12,6 +12,7 @@ import (
 	"net/http"
 	urlpkg "net/url"
 	"strings"
+	"time"
 
 	"github.com/grafana/sobek"
 	"github.com/shiroyk/ski"
@@ -21,17 +22,41 @@ import (
 
 func init() {
 	jar := ski.NewCookieJar()
-	fetch := ski.NewFetch().(*http.Client)
-	fetch.Jar = jar
-	js.Register("cookieJar", &CookieJar{jar})
-	js.Register("http", &Http{fetch})
-	js.Register("fetch", &Fetch{fetch})
+	client := ski.NewFetch().(*http.Client)
+	client.Jar = jar
+	// retry only if the request options present
+	Register(ski.ChainFetch(client, ski.RetryMiddleware(ski.RetryOptions{MaxAttempts: 1})), jar)
+	RegisterProxyPool(nil)
 	js.Register("FormData", new(FormData))
 	js.Register("URLSearchParams", new(URLSearchParams))
 	js.Register("AbortController", new(AbortController))
 	js.Register("AbortSignal", new(AbortSignal))
 }
 
+// Register registers the cookieJar, http and fetch modules with the given
+// ski.Fetch and ski.CookieJar, the Fetch should store cookies to the CookieJar.
+// It replaces the default modules, such as applies the ski.FetchMiddleware to
+// all requests of the scripts.
+func Register(fetch ski.Fetch, jar ski.CookieJar) {
+	js.Register("cookieJar", &CookieJar{jar})
+	js.Register("http", &Http{Fetch: fetch, jar: jar})
+	js.Register("fetch", &Fetch{fetch})
+}
+
+// WithFetch returns the js.Option sets the global http and fetch with the
+// given ski.Fetch to the VM, such as replays the HAR in the tests:
+//
+//	har, _ := ski.LoadHAR("testdata/page.har")
+//	vm := modulestest.New(t, http.WithFetch(ski.NewHARReplay(har, ski.HARReplayOptions{})))
+func WithFetch(fetch ski.Fetch) js.Option {
+	return js.WithInitial(func(rt *sobek.Runtime) {
+		instance, _ := (&Http{Fetch: fetch}).Instantiate(rt)
+		_ = rt.Set("http", instance)
+		f, _ := (&Fetch{fetch}).Instantiate(rt)
+		_ = rt.Set("fetch", f)
+	})
+}
+
 // Fetch the global Fetch() method starts the process of
 // fetching a resource from the network, returning a promise
 // which is fulfilled once the response is available.
@@ -49,7 +74,7 @@ func (fetch *Fetch) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
 				if signal != nil {
 					defer signal.abort() // release resources
 				}
-				return fetch.Do(req)
+				return doRequest(fetch, req)
 			},
 			func(res *http.Response, err error) (any, error) {
 				if err != nil {
@@ -63,7 +88,10 @@ func (fetch *Fetch) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
 func (*Fetch) Global() {}
 
 // Http module for fetching resources (including across the network).
-type Http struct{ ski.Fetch }
+type Http struct {
+	ski.Fetch
+	jar http.CookieJar // the cookie jar of the client created by Create
+}
 
 func (h *Http) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
 	if h.Fetch == nil {
@@ -77,9 +105,44 @@ func (h *Http) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
 		"patch":   h.Patch,
 		"request": h.Request,
 		"head":    h.Head,
+		"create":  h.Create,
 	}), nil
 }
 
+// Create returns a new http module with the client options, such as
+// http.create({ caFile: "ca.pem", certFile: "client.pem", keyFile: "client.key", http2: false }).
+// The auth option authenticates all requests of the client, the Digest challenge
+// is reused by the requests, such as http.create({ auth: { type: "digest", username: "user", password: "pass" } }).
+// The client shares the cookies, but the requests are not handled by the ski.FetchMiddleware.
+func (h *Http) Create(call sobek.FunctionCall, vm *sobek.Runtime) sobek.Value {
+	options := call.Argument(0).Export()
+	opt, err := toFetchOptions(options)
+	if err != nil {
+		js.Throw(vm, fmt.Errorf("create options is invalid, %s", err))
+	}
+	fetch, err := ski.NewFetchWithOptions(opt)
+	if err != nil {
+		js.Throw(vm, err)
+	}
+	client := fetch.(*http.Client)
+	if h.jar != nil {
+		client.Jar = h.jar
+	}
+	fetch = client
+	if m, ok := options.(map[string]any); ok && m["auth"] != nil {
+		auth, err := toAuthMiddleware(m["auth"])
+		if err != nil {
+			js.Throw(vm, fmt.Errorf("create options auth is invalid, %s", err))
+		}
+		fetch = auth(client)
+	}
+	instance, err := (&Http{Fetch: fetch, jar: client.Jar}).Instantiate(vm)
+	if err != nil {
+		js.Throw(vm, err)
+	}
+	return instance
+}
+
 // Get Make a HTTP GET request.
 func (h *Http) Get(call sobek.FunctionCall, vm *sobek.Runtime) sobek.Value {
 	return h.do(call, vm, http.MethodGet)
@@ -127,7 +190,7 @@ func (h *Http) do(call sobek.FunctionCall, vm *sobek.Runtime, method string) sob
 		defer signal.abort() // release resources
 	}
 
-	res, err := h.Do(req)
+	res, err := doRequest(h, req)
 	if err != nil {
 		js.Throw(vm, err)
 	}
@@ -135,6 +198,33 @@ func (h *Http) do(call sobek.FunctionCall, vm *sobek.Runtime, method string) sob
 	return NewResponse(vm, res)
 }
 
+// redirectErrorKey the request context key of the redirect: "error" option.
+type redirectErrorKey struct{}
+
+// authKey the request context key of the auth option.
+type authKey struct{}
+
+// doRequest sends the request with the auth option, returns an error if
+// the response is redirect and the request option redirect is "error".
+func doRequest(fetch ski.Fetch, req *http.Request) (*http.Response, error) {
+	if auth, ok := req.Context().Value(authKey{}).(ski.FetchMiddleware); ok {
+		fetch = auth(fetch)
+	}
+	res, err := fetch.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Context().Value(redirectErrorKey{}) != nil && res.StatusCode >= 300 && res.StatusCode < 400 &&
+		res.Header.Get("Location") != "" {
+		_ = res.Body.Close()
+		return nil, fmt.Errorf("unexpected redirect to %s", res.Header.Get("Location"))
+	}
+	return res, nil
+}
+
+// requestContext wraps the context to store the values only for the request.
+type requestContext struct{ context.Context }
+
 func buildRequest(
 	method string,
 	call sobek.FunctionCall,
@@ -171,6 +261,12 @@ func buildRequest(
 			}
 		}
 	}
+	if v := opt.Get("compress"); v != nil && body != nil && body != http.NoBody {
+		if body, err = compressBody(body, v.String()); err != nil {
+			js.Throw(vm, fmt.Errorf("options compress is invalid, %s", err))
+		}
+		headers["Content-Encoding"] = v.String()
+	}
 	if v := opt.Get("cache"); v != nil {
 		str := v.String()
 		headers["Cache-Control"] = str
@@ -185,12 +281,64 @@ func buildRequest(
 	} else {
 		ctx = js.Context(vm)
 	}
+	// the options of the request should not be set to the shared ski.Context
+	ctx = requestContext{ctx}
 	if v := opt.Get("proxy"); v != nil {
-		proxy, err := urlpkg.Parse(v.String())
+		if v.String() == "direct" {
+			ctx = ski.WithoutProxy(ctx)
+		} else {
+			proxy, err := urlpkg.Parse(v.String())
+			if err != nil {
+				js.Throw(vm, fmt.Errorf("options proxy is invalid URL, %s", err))
+			}
+			ctx = ski.WithProxyURL(ctx, proxy)
+		}
+	}
+	if v := opt.Get("timeout"); v != nil {
+		timeout, err := toDuration(v.Export())
+		if err != nil {
+			js.Throw(vm, fmt.Errorf("options timeout is invalid, %s", err))
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		js.OnDone(vm, cancel)
+	}
+	if v := opt.Get("redirect"); v != nil {
+		var redirect ski.RedirectOptions
+		switch v.String() {
+		case "follow":
+			// follows the redirects by the policy of the client
+		case "manual":
+			redirect.Disable = true
+		case "error":
+			redirect.Disable = true
+			ctx = context.WithValue(ctx, redirectErrorKey{}, true)
+		default:
+			js.Throw(vm, fmt.Errorf("options redirect is invalid, %s", v))
+		}
+		if v := opt.Get("maxRedirects"); v != nil {
+			redirect.MaxRedirects = int(v.ToInteger())
+		}
+		ctx = ski.WithRedirect(ctx, redirect)
+	} else if v := opt.Get("maxRedirects"); v != nil {
+		ctx = ski.WithRedirect(ctx, ski.RedirectOptions{MaxRedirects: int(v.ToInteger())})
+	}
+	if v := opt.Get("charset"); v != nil {
+		ctx = ski.WithCharset(ctx, v.String())
+	}
+	if v := opt.Get("retry"); v != nil {
+		retry, err := toRetryOptions(v.Export())
+		if err != nil {
+			js.Throw(vm, fmt.Errorf("options retry is invalid, %s", err))
+		}
+		ctx = ski.WithRetry(ctx, retry)
+	}
+	if v := opt.Get("auth"); v != nil {
+		auth, err := toAuthMiddleware(v.Export())
 		if err != nil {
-			js.Throw(vm, fmt.Errorf("options proxy is invalid URL, %s", err))
+			js.Throw(vm, fmt.Errorf("options auth is invalid, %s", err))
 		}
-		ctx = ski.WithProxyURL(ctx, proxy)
+		ctx = context.WithValue(ctx, authKey{}, auth)
 	}
 
 NEW:
@@ -206,6 +354,153 @@ NEW:
 	return
 }
 
+// toRetryOptions converts the retry option, which is the max attempts number
+// or the object containing attempts, minBackoff, maxBackoff, methods, statusCodes.
+func toRetryOptions(v any) (opt ski.RetryOptions, err error) {
+	if m, ok := v.(map[string]any); ok {
+		if opt.MaxAttempts, err = cast.ToIntE(m["attempts"]); err != nil {
+			return
+		}
+		if opt.MinBackoff, err = toDuration(m["minBackoff"]); err != nil {
+			return
+		}
+		if opt.MaxBackoff, err = toDuration(m["maxBackoff"]); err != nil {
+			return
+		}
+		if v, ok := m["methods"]; ok {
+			if opt.Methods, err = cast.ToStringSliceE(v); err != nil {
+				return
+			}
+		}
+		if v, ok := m["statusCodes"]; ok {
+			opt.StatusCodes, err = cast.ToIntSliceE(v)
+		}
+		return
+	}
+	opt.MaxAttempts, err = cast.ToIntE(v)
+	return
+}
+
+// oauth2Cache stores the OAuth2 access tokens of the scripts.
+var oauth2Cache = ski.NewCache()
+
+// toAuthMiddleware converts the auth option to the ski.FetchMiddleware, the option is one of
+// { type: "basic", username, password }, { type: "bearer", token },
+// { type: "digest", username, password },
+// { type: "oauth2", tokenURL, clientID, clientSecret, scopes }.
+// The type is basic if the username present, or bearer if the token present.
+func toAuthMiddleware(v any) (ski.FetchMiddleware, error) {
+	m, err := cast.ToStringMapE(v)
+	if err != nil {
+		return nil, err
+	}
+	typ := strings.ToLower(cast.ToString(m["type"]))
+	if typ == "" {
+		if _, ok := m["username"]; ok {
+			typ = "basic"
+		} else if _, ok := m["token"]; ok {
+			typ = "bearer"
+		}
+	}
+	switch typ {
+	case "basic":
+		return ski.BasicAuthMiddleware(cast.ToString(m["username"]), cast.ToString(m["password"])), nil
+	case "bearer":
+		return ski.BearerAuthMiddleware(cast.ToString(m["token"])), nil
+	case "digest":
+		return ski.DigestAuthMiddleware(cast.ToString(m["username"]), cast.ToString(m["password"])), nil
+	case "oauth2":
+		opt := ski.OAuth2Options{
+			TokenURL:     cast.ToString(m["tokenURL"]),
+			ClientID:     cast.ToString(m["clientID"]),
+			ClientSecret: cast.ToString(m["clientSecret"]),
+			Cache:        oauth2Cache,
+		}
+		if opt.TokenURL == "" {
+			return nil, errors.New("oauth2 tokenURL is required")
+		}
+		switch scopes := m["scopes"].(type) {
+		case nil:
+		case string:
+			opt.Scopes = strings.Fields(scopes)
+		default:
+			if opt.Scopes, err = cast.ToStringSliceE(scopes); err != nil {
+				return nil, err
+			}
+		}
+		return ski.OAuth2Middleware(opt), nil
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", typ)
+	}
+}
+
+// toFetchOptions converts the object containing caFile, certFile, keyFile, minTLSVersion,
+// insecureSkipVerify, http2, maxIdleConns, maxIdleConnsPerHost, maxConnsPerHost,
+// dialTimeout, tlsHandshakeTimeout, responseHeaderTimeout, idleConnTimeout, timeout.
+func toFetchOptions(v any) (opt ski.FetchOptions, err error) {
+	if v == nil {
+		return
+	}
+	m, err := cast.ToStringMapE(v)
+	if err != nil {
+		return
+	}
+	opt.CAFile = cast.ToString(m["caFile"])
+	opt.CertFile = cast.ToString(m["certFile"])
+	opt.KeyFile = cast.ToString(m["keyFile"])
+	opt.MinTLSVersion = cast.ToString(m["minTLSVersion"])
+	opt.InsecureSkipVerify = cast.ToBool(m["insecureSkipVerify"])
+	if v, ok := m["http2"]; ok {
+		opt.DisableHTTP2 = !cast.ToBool(v)
+	}
+	if opt.MaxIdleConns, err = cast.ToIntE(m["maxIdleConns"]); err != nil {
+		return
+	}
+	if opt.MaxIdleConnsPerHost, err = cast.ToIntE(m["maxIdleConnsPerHost"]); err != nil {
+		return
+	}
+	if opt.MaxConnsPerHost, err = cast.ToIntE(m["maxConnsPerHost"]); err != nil {
+		return
+	}
+	for key, d := range map[string]*time.Duration{
+		"dialTimeout":           &opt.DialTimeout,
+		"tlsHandshakeTimeout":   &opt.TLSHandshakeTimeout,
+		"responseHeaderTimeout": &opt.ResponseHeaderTimeout,
+		"idleConnTimeout":       &opt.IdleConnTimeout,
+		"timeout":               &opt.Timeout,
+	} {
+		if *d, err = toDuration(m[key]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// toDuration converts the duration string or milliseconds number to time.Duration.
+func toDuration(v any) (time.Duration, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return time.ParseDuration(t)
+	default:
+		ms, err := cast.ToInt64E(t)
+		return time.Duration(ms) * time.Millisecond, err
+	}
+}
+
+// compressBody returns the compressed body with the content encoding.
+func compressBody(body io.Reader, encoding string) (io.Reader, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = ski.Compress(data, encoding); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
 // processBody process the send request body and set the content-type
 func processBody(body any, headers map[string]string) (io.Reader, error) {
 	switch data := body.(type) {
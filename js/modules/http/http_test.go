@@ -1,12 +1,14 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,7 +23,9 @@ func TestHttp(t *testing.T) {
 	vm := createVM(t)
 	testCase := []string{
 		`assert.equal(http.get(url).text(), "");`,
-		`assert.equal(http.post(url, { body: new FormData({'file': fa, 'name': 'foo'}) }).text(), "♂︎");`,
+		// the UTF-8 body is decoded with the declared charset iso-8859-9 (windows-1254)
+		`assert.equal(http.post(url, { body: new FormData({'file': fa, 'name': 'foo'}) }).text(), "\u00e2\u2122\u201a\u00ef\u00b8\ufffd");`,
+		`assert.equal(http.post(url, { body: new FormData({'file': fa, 'name': 'foo'}) }).text("utf-8"), "♂︎");`,
 		`assert.equal(http.post(url, { body: new URLSearchParams({'key': 'holy', 'value': 'fa'}) }).text(), "key=holy&value=fa");`,
 		`assert.equal(http.head(url).headers["X-Total-Count"], "114514");`,
 		`assert.equal(http.post(url).text(), "");`,
@@ -32,7 +36,7 @@ func TestHttp(t *testing.T) {
 		 .then(res => res.text())
 		 .then(body => assert.equal(body, "put"));`,
 		`fetch(url, { method: 'patch', body: fa })
-		 .then(res => res.text())
+		 .then(res => res.text("utf-8"))
 		 .then(body => assert.equal(body, "♂︎"));`,
 		`fetch(url, { method: 'PATCH', body: new Uint8Array([97]) })
 		 .then(res => res.text())
@@ -43,6 +47,9 @@ func TestHttp(t *testing.T) {
 		`fetch(url, { proxy: proxyURL })
 		 .then(res => res.text())
 		 .then(body => assert.equal(body, "proxy ok"))`,
+		`assert.equal(http.get(url, { proxy: proxyURL }).text(), "proxy ok");
+		 assert.equal(http.get(url, { proxy: "direct" }).text(), "");
+		 assert.equal(http.get(url).text(), "");`,
 		`try {
 			fetch(url, { method: 'put', body: 114514 })
 		 } catch (e) {
@@ -70,9 +77,224 @@ func TestHttp(t *testing.T) {
 	}
 }
 
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	var count atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if count.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprint(w, r.Method)
+	}))
+	t.Cleanup(ts.Close)
+
+	vm := modulestest.New(t, WithFetch(ski.NewRetryFetch(http.DefaultClient, ski.RetryOptions{MaxAttempts: 1})))
+	_ = vm.Runtime().Set("url", ts.URL)
+
+	// the retry options of the request should not be stored on the shared context
+	_, err := vm.RunString(ski.NewContext(context.Background(), nil), `
+		assert.equal(http.get(url).status, 503);
+		assert.equal(http.get(url, { retry: { attempts: 3, minBackoff: 1 } }).text(), "GET");
+		assert.equal(http.get(url).status, 503);
+		assert.equal(http.post(url, { retry: { attempts: 3, minBackoff: "1ms" } }).status, 503);
+		assert.equal(http.post(url, { retry: { attempts: 3, minBackoff: "1ms", methods: ["POST"] } }).text(), "POST");
+		fetch(url, { method: "put", retry: { attempts: 3, minBackoff: 1, statusCodes: [502] } })
+			.then(res => assert.equal(res.status, 503));
+	`)
+	assert.NoError(t, err)
+}
+
+func TestHARReplay(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"method":"%s"}`, r.Method)
+	}))
+	t.Cleanup(ts.Close)
+
+	recorder := ski.NewHARRecorder(http.DefaultClient, ski.HARRecorderOptions{})
+	vm := modulestest.New(t, WithFetch(recorder))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err := vm.RunString(context.Background(), `
+		assert.equal(http.get(url).json().method, "GET");
+		assert.equal(http.post(url, { body: "1" }).json().method, "POST");
+	`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ts.Close()
+
+	vm = modulestest.New(t, WithFetch(ski.NewHARReplay(recorder.HAR(), ski.HARReplayOptions{})))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err = vm.RunString(context.Background(), `
+		assert.equal(http.get(url).json().method, "GET");
+		assert.equal(http.post(url).json().method, "POST");
+		fetch(url, { method: "DELETE" }).catch(e => assert.true(e.toString().includes("not found in HAR"), e.toString()));
+	`)
+	assert.NoError(t, err)
+}
+
+func TestCompressBody(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ski.Decompress(r.Body, r.Header.Get("Content-Encoding"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(body)
+		w.Header().Set("Content-Encoding", "br")
+		data, _ = ski.Compress(data, "br")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(ts.Close)
+
+	vm := modulestest.New(t, WithFetch(ski.NewFetch()))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err := vm.RunString(context.Background(), `
+		assert.equal(http.post(url, { body: "foo", compress: "gzip" }).text(), "foo");
+		assert.equal(http.post(url, { body: { "bar": 1 }, compress: "zstd" }).json().bar, 1);
+		fetch(url, { method: "put", body: "baz", compress: "br" })
+			.then(res => res.text())
+			.then(text => assert.equal(text, "baz"));
+		try {
+			http.post(url, { body: "foo", compress: "lzma" });
+		} catch (e) {
+			assert.true(e.toString().includes("unsupported content encoding"), e.toString());
+		}
+	`)
+	assert.NoError(t, err)
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sleep" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = fmt.Fprint(w, r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	vm := modulestest.New(t, WithFetch(ski.NewFetch()))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err := vm.RunString(context.Background(), `
+		const error = (fn) => {
+			try {
+				fn();
+			} catch (e) {
+				return e.toString();
+			}
+			return "";
+		};
+		assert.true(error(() => http.get(url)).includes("certificate"));
+		const client = http.create({ insecureSkipVerify: true });
+		assert.equal(client.get(url).text(), "HTTP/2.0");
+		assert.equal(http.create({ insecureSkipVerify: true, http2: false }).get(url).text(), "HTTP/1.1");
+		assert.true(error(() => client.get(url + "/sleep", { timeout: 50 })).includes("deadline exceeded"));
+		assert.true(error(() => http.create({ minTLSVersion: "2.0" })).includes("invalid min TLS version"));
+	`)
+	assert.NoError(t, err)
+}
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+	var challenges, tokens atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/digest":
+			if !strings.HasPrefix(auth, `Digest username="user"`) {
+				challenges.Add(1)
+				w.Header().Set("WWW-Authenticate", `Digest realm="ski", qop="auth", nonce="abc"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = fmt.Fprint(w, challenges.Load())
+		case "/token":
+			_, _ = fmt.Fprintf(w, `{"access_token":"token%d","expires_in":3600}`, tokens.Add(1))
+		default:
+			_, _ = fmt.Fprint(w, auth)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	vm := modulestest.New(t, WithFetch(ski.NewFetch()))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err := vm.RunString(context.Background(), `
+		assert.equal(http.get(url, { auth: { username: "user", password: "pass" } }).text(), "Basic dXNlcjpwYXNz");
+		assert.equal(http.get(url, { auth: { type: "bearer", token: "foo" } }).text(), "Bearer foo");
+		assert.equal(http.get(url, { auth: { token: "foo" }, headers: { Authorization: "Token bar" } }).text(), "Token bar");
+		const oauth2 = { type: "oauth2", tokenURL: url + "/token", clientID: "id", clientSecret: "secret", scopes: "read" };
+		assert.equal(http.get(url, { auth: oauth2 }).text(), "Bearer token1");
+		assert.equal(http.get(url, { auth: oauth2 }).text(), "Bearer token1");
+		const client = http.create({ auth: { type: "digest", username: "user", password: "pass" } });
+		assert.equal(client.get(url + "/digest").text(), "1");
+		assert.equal(client.get(url + "/digest").text(), "1");
+		try {
+			http.get(url, { auth: { type: "ntlm" } });
+			assert.true(false);
+		} catch (e) {
+			assert.true(e.toString().includes("unknown auth type"), e.toString());
+		}
+	`)
+	assert.NoError(t, err)
+}
+
+func TestRedirect(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, "done")
+	}))
+	t.Cleanup(ts.Close)
+
+	vm := modulestest.New(t, WithFetch(ski.NewFetch()))
+	_ = vm.Runtime().Set("url", ts.URL)
+	_, err := vm.RunString(context.Background(), `
+		const error = (fn) => {
+			try {
+				fn();
+			} catch (e) {
+				return e.toString();
+			}
+			return "";
+		};
+		let res = http.get(url + "/2");
+		assert.true(res.redirected);
+		assert.equal(res.url, url + "/0");
+		assert.equal(res.redirects, [url + "/2", url + "/1", url + "/0"]);
+		assert.equal(res.text(), "done");
+
+		res = http.get(url + "/0");
+		assert.true(!res.redirected);
+		assert.equal(res.redirects, [url + "/0"]);
+
+		res = http.get(url + "/2", { redirect: "manual" });
+		assert.equal(res.status, 302);
+		assert.equal(res.headers["Location"], "/1");
+		assert.true(!res.redirected);
+
+		assert.true(error(() => http.get(url + "/2", { redirect: "error" })).includes("unexpected redirect"));
+		assert.true(error(() => http.get(url + "/3", { maxRedirects: 2 })).includes("stopped after 2 redirects"));
+		fetch(url + "/1").then(res => {
+			assert.true(res.redirected);
+			assert.equal(res.url, url + "/0");
+		});
+	`)
+	assert.NoError(t, err)
+}
+
 var initial = js.WithInitial(func(rt *sobek.Runtime) {
 	client := http.Client{Transport: &http.Transport{Proxy: ski.ProxyFromRequest}}
-	instance, _ := (&Http{&client}).Instantiate(rt)
+	instance, _ := (&Http{Fetch: &client}).Instantiate(rt)
 	_ = rt.Set("http", instance)
 	f, _ := (&Fetch{&client}).Instantiate(rt)
 	_ = rt.Set("fetch", f)
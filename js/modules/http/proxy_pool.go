@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/grafana/sobek"
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+)
+
+// ProxyPool selects the proxy from the ski.ProxyPool for the requests,
+// the selected proxy is used by the request option `proxy`:
+//
+//	import http from "ski/http";
+//	import proxyPool from "ski/proxyPool";
+//
+//	const url = "https://example.com";
+//	http.get(url, { proxy: proxyPool.next(url) });
+type ProxyPool struct{ *ski.ProxyPool }
+
+// RegisterProxyPool registers the proxyPool module with the given ski.ProxyPool,
+// the Fetch of the http module should apply the ski.ProxyMiddleware with the same
+// ski.ProxyPool to record the health of the selected proxies.
+func RegisterProxyPool(pool *ski.ProxyPool) {
+	js.Register("proxyPool", &ProxyPool{pool})
+}
+
+func (p *ProxyPool) Instantiate(rt *sobek.Runtime) (sobek.Value, error) {
+	return rt.ToValue(map[string]func(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value{
+		"next":   p.Next,
+		"status": p.Status,
+	}), nil
+}
+
+// Next returns the proxy URL for the host of the given URL,
+// throws if all proxies are benched or the proxy pool is not configured.
+func (p *ProxyPool) Next(call sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
+	if p.ProxyPool == nil {
+		js.Throw(rt, errors.New("proxy pool is not configured"))
+	}
+	var host string
+	if v := call.Argument(0); !sobek.IsUndefined(v) && !sobek.IsNull(v) {
+		u, err := url.Parse(v.String())
+		if err != nil {
+			js.Throw(rt, err)
+		}
+		host = u.Host
+	}
+	proxy, err := p.ProxyPool.Next(host)
+	if err != nil {
+		js.Throw(rt, err)
+	}
+	return rt.ToValue(proxy.String())
+}
+
+// Status returns the health status of the proxies,
+// the benched is the unix milliseconds until the proxy is available.
+func (p *ProxyPool) Status(_ sobek.FunctionCall, rt *sobek.Runtime) sobek.Value {
+	if p.ProxyPool == nil {
+		return rt.NewArray()
+	}
+	status := p.ProxyPool.Status()
+	ret := make([]any, 0, len(status))
+	for _, s := range status {
+		o := rt.NewObject()
+		_ = o.Set("url", s.URL)
+		_ = o.Set("failures", s.Failures)
+		if s.Benched == nil {
+			_ = o.Set("benched", sobek.Null())
+		} else {
+			_ = o.Set("benched", s.Benched.UnixMilli())
+		}
+		ret = append(ret, o)
+	}
+	return rt.NewArray(ret...)
+}
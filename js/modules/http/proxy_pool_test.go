@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+	"github.com/shiroyk/ski/js/modulestest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyPool(t *testing.T) {
+	t.Parallel()
+	// the http proxy receives the absolute URL of the target
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "proxied "+r.URL.String())
+	}))
+	t.Cleanup(proxy.Close)
+
+	pool, err := ski.NewProxyPool([]string{proxy.URL, "http://127.0.0.1:1"}, ski.ProxyPoolOptions{MaxFailures: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: ski.ProxyFromRequest, DisableKeepAlives: true}}
+	fetch := ski.ChainFetch(client, ski.ProxyMiddleware(pool))
+
+	vm := modulestest.New(t, js.WithInitial(func(rt *sobek.Runtime) {
+		instance, _ := (&ProxyPool{pool}).Instantiate(rt)
+		_ = rt.Set("proxyPool", instance)
+		instance, _ = (&Http{Fetch: fetch}).Instantiate(rt)
+		_ = rt.Set("http", instance)
+	}))
+	_ = vm.Runtime().Set("proxy", proxy.URL)
+
+	_, err = vm.RunString(context.Background(), `
+		const url = "http://example.com/foo";
+		let p = proxyPool.next(url);
+		assert.equal(p, proxy);
+		assert.equal(http.get(url, { proxy: p }).text(), "proxied " + url);
+		p = proxyPool.next(url);
+		assert.equal(p, "http://127.0.0.1:1");
+		try {
+			http.get(url, { proxy: p });
+			assert.true(false, "unreachable proxy should throw");
+		} catch (e) {}
+		const status = proxyPool.status();
+		assert.equal(status.length, 2);
+		assert.equal(status[0].url, proxy);
+		assert.equal(status[0].failures, 0);
+		assert.equal(status[0].benched, null);
+		assert.equal(status[1].failures, 1);
+		assert.true(status[1].benched > Date.now());
+		// the benched proxy is skipped
+		assert.equal(proxyPool.next(url), proxy);
+		assert.equal(proxyPool.next(), proxy);
+	`)
+	assert.NoError(t, err)
+}
+
+func TestProxyPoolNotConfigured(t *testing.T) {
+	t.Parallel()
+	vm := modulestest.New(t, js.WithInitial(func(rt *sobek.Runtime) {
+		instance, _ := (&ProxyPool{}).Instantiate(rt)
+		_ = rt.Set("proxyPool", instance)
+	}))
+
+	_, err := vm.RunString(context.Background(), `
+		assert.equal(proxyPool.status().length, 0);
+		proxyPool.next("http://example.com");
+	`)
+	assert.ErrorContains(t, err, "proxy pool is not configured")
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/grafana/sobek"
+	"github.com/shiroyk/ski"
 	"github.com/shiroyk/ski/js"
 )
 
@@ -46,10 +47,19 @@ func NewResponse(rt *sobek.Runtime, res *http.Response) sobek.Value {
 	defineGetter(rt, object, "headers", func() any { return joinHeader(res.Header) })
 	defineGetter(rt, object, "status", func() any { return res.StatusCode })
 	defineGetter(rt, object, "statusText", func() any { return res.Status })
+	defineGetter(rt, object, "url", func() any { return responseURL(res) })
+	defineGetter(rt, object, "redirected", func() any { return len(ski.RedirectChain(res)) > 1 })
+	defineGetter(rt, object, "redirects", func() any { return redirectChain(res) })
 	defineGetter(rt, object, "ok", func() any {
 		return res.StatusCode >= 200 && res.StatusCode < 300
 	})
-	_ = object.Set("text", func(sobek.FunctionCall) sobek.Value { return rt.ToValue(string(readBody())) })
+	_ = object.Set("text", func(call sobek.FunctionCall) sobek.Value {
+		text, err := decodeText(res, readBody(), call.Argument(0))
+		if err != nil {
+			js.Throw(rt, err)
+		}
+		return rt.ToValue(text)
+	})
 	_ = object.Set("json", func(call sobek.FunctionCall) sobek.Value {
 		var data any
 		if err := json.Unmarshal(readBody(), &data); err != nil {
@@ -94,16 +104,20 @@ func NewAsyncResponse(rt *sobek.Runtime, res *http.Response) sobek.Value {
 	defineGetter(rt, object, "headers", func() any { return joinHeader(res.Header) })
 	defineGetter(rt, object, "status", func() any { return res.StatusCode })
 	defineGetter(rt, object, "statusText", func() any { return res.Status })
+	defineGetter(rt, object, "url", func() any { return responseURL(res) })
+	defineGetter(rt, object, "redirected", func() any { return len(ski.RedirectChain(res)) > 1 })
+	defineGetter(rt, object, "redirects", func() any { return redirectChain(res) })
 	defineGetter(rt, object, "ok", func() any {
 		return res.StatusCode >= 200 && res.StatusCode < 300
 	})
-	_ = object.Set("text", func(sobek.FunctionCall) sobek.Value {
+	_ = object.Set("text", func(call sobek.FunctionCall) sobek.Value {
+		label := call.Argument(0)
 		return rt.ToValue(js.NewPromise(rt, func() (any, error) {
 			data, err := readBody()
 			if err != nil {
 				return nil, err
 			}
-			return string(data), nil
+			return decodeText(res, data, label)
 		}))
 	})
 	_ = object.Set("json", func(sobek.FunctionCall) sobek.Value {
@@ -131,6 +145,40 @@ func NewAsyncResponse(rt *sobek.Runtime, res *http.Response) sobek.Value {
 	return object
 }
 
+// responseURL returns the final URL of the response.
+func responseURL(res *http.Response) string {
+	if res.Request == nil {
+		return ""
+	}
+	return res.Request.URL.String()
+}
+
+// redirectChain returns the URLs of the redirect chain.
+func redirectChain(res *http.Response) []any {
+	chain := ski.RedirectChain(res)
+	ret := make([]any, 0, len(chain))
+	for _, u := range chain {
+		ret = append(ret, u.String())
+	}
+	return ret
+}
+
+// decodeText transcodes the body to UTF-8 string, the charset is the label if present,
+// or the charset option of the request, otherwise detected from the response.
+func decodeText(res *http.Response, body []byte, label sobek.Value) (string, error) {
+	var charset string
+	if !sobek.IsUndefined(label) && !sobek.IsNull(label) {
+		charset = label.String()
+	} else if res.Request != nil {
+		charset = ski.CharsetFromContext(res.Request.Context())
+	}
+	text, err := ski.DecodeCharset(body, res.Header.Get("Content-Type"), charset)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
 func joinHeader(header http.Header) map[string]string {
 	h := make(map[string]string, len(header))
 	for k, vs := range header {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/shiroyk/ski/js/modulestest"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
 )
 
 func TestResponse(t *testing.T) {
@@ -73,6 +74,38 @@ func TestResponse(t *testing.T) {
 	}
 }
 
+func TestResponseCharset(t *testing.T) {
+	t.Parallel()
+	vm := modulestest.New(t, initial)
+
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("你好")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/header":
+			w.Header().Set("Content-Type", "text/html; charset=gbk")
+			_, _ = fmt.Fprint(w, gbk)
+		case "/meta":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, `<meta charset="gbk">`+gbk)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprint(w, gbk)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	_ = vm.Runtime().Set("url", ts.URL)
+
+	_, err := vm.RunString(context.Background(), `
+		assert.equal(http.get(url+'/header').text(), "你好");
+		assert.equal(http.get(url+'/meta').text(), '<meta charset="gbk">你好');
+		assert.equal(http.get(url).text("gbk"), "你好");
+		assert.equal(http.get(url, { charset: "gbk" }).text(), "你好");
+		fetch(url+'/header').then(res => res.text()).then(text => assert.equal(text, "你好"));
+		fetch(url).then(res => res.text("gb18030")).then(text => assert.equal(text, "你好"));
+	`)
+	assert.NoError(t, err)
+}
+
 func TestAsyncResponse(t *testing.T) {
 	vm := modulestest.New(t, initial)
 
@@ -204,6 +204,7 @@ func (vm *vmImpl) Run(ctx context.Context, task func()) {
 				frame.Write(buf)
 			}
 			ski.Logger(ctx).Error(fmt.Sprintf("vm run error: %s", x),
+				slog.String(ski.LogSubsystemKey, "js"),
 				slog.String("go_stack", string(debug.Stack())),
 				slog.String("js_stack", buf.String()))
 		}
@@ -0,0 +1,272 @@
+package ski
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoProxy all proxies of the ProxyPool are benched.
+var ErrNoProxy = errors.New("no available proxy")
+
+// ProxyStrategy the strategy of the ProxyPool selects the proxy.
+type ProxyStrategy string
+
+const (
+	// ProxyRoundRobin selects the proxies in round-robin order.
+	ProxyRoundRobin ProxyStrategy = "round-robin"
+	// ProxyRandom selects the proxies randomly.
+	ProxyRandom ProxyStrategy = "random"
+	// ProxySticky selects the same proxy for the same host,
+	// the host is reassigned to another proxy when the proxy is benched.
+	ProxySticky ProxyStrategy = "sticky"
+)
+
+const (
+	// DefaultProxyMaxFailures default consecutive failures to bench the proxy
+	DefaultProxyMaxFailures = 3
+	// DefaultProxyBenchTime default duration of the benched proxy
+	DefaultProxyBenchTime = time.Minute
+	// proxyStickyHosts the max hosts of the sticky strategy are remembered.
+	proxyStickyHosts = 10000
+)
+
+// ProxyPoolOptions the options of the ProxyPool.
+type ProxyPoolOptions struct {
+	// Strategy the proxy selection strategy, default is ProxyRoundRobin.
+	Strategy ProxyStrategy `yaml:"strategy" json:"strategy"`
+	// MaxFailures the consecutive failures to bench the proxy.
+	MaxFailures int `yaml:"max-failures" json:"maxFailures"`
+	// BenchTime the duration of the benched proxy is not selected.
+	BenchTime time.Duration `yaml:"bench-time" json:"benchTime"`
+}
+
+// ProxyPool selects the proxy from the proxies with the strategy,
+// and benches the proxy that fails consecutively.
+type ProxyPool struct {
+	opt     ProxyPoolOptions
+	mu      sync.Mutex
+	proxies []*proxyState
+	next    int
+	sticky  map[string]*proxyState
+}
+
+type proxyState struct {
+	url      *url.URL
+	failures int
+	benched  time.Time // benched until
+}
+
+// ProxyStatus the health status of the proxy in the ProxyPool.
+type ProxyStatus struct {
+	URL      string `json:"url"`
+	Failures int    `json:"failures"`
+	// Benched the proxy is benched until, nil if the proxy is available.
+	Benched *time.Time `json:"benched,omitempty"`
+}
+
+// NewProxyPool returns a ProxyPool of the proxies, the http, https,
+// socks5 and socks5h proxies are supported.
+func NewProxyPool(proxies []string, opt ProxyPoolOptions) (*ProxyPool, error) {
+	switch opt.Strategy {
+	case "":
+		opt.Strategy = ProxyRoundRobin
+	case ProxyRoundRobin, ProxyRandom, ProxySticky:
+	default:
+		return nil, fmt.Errorf("invalid proxy strategy %q", opt.Strategy)
+	}
+	if opt.MaxFailures <= 0 {
+		opt.MaxFailures = DefaultProxyMaxFailures
+	}
+	if opt.BenchTime <= 0 {
+		opt.BenchTime = DefaultProxyBenchTime
+	}
+	if len(proxies) == 0 {
+		return nil, errors.New("proxy pool is empty")
+	}
+
+	pool := &ProxyPool{opt: opt, sticky: make(map[string]*proxyState)}
+	for _, proxy := range proxies {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", proxy, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxy)
+		}
+		pool.proxies = append(pool.proxies, &proxyState{url: u})
+	}
+	return pool, nil
+}
+
+// Next returns the proxy for the host, returns ErrNoProxy if all proxies are benched.
+func (p *ProxyPool) Next(host string) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+
+	if p.opt.Strategy == ProxySticky {
+		if s, ok := p.sticky[host]; ok && s.available(now) {
+			return s.url, nil
+		}
+	}
+
+	var s *proxyState
+	switch p.opt.Strategy {
+	case ProxyRandom:
+		available := make([]*proxyState, 0, len(p.proxies))
+		for _, s := range p.proxies {
+			if s.available(now) {
+				available = append(available, s)
+			}
+		}
+		if len(available) > 0 {
+			s = available[rand.Intn(len(available))] //nolint:gosec
+		}
+	default:
+		for i := 0; i < len(p.proxies); i++ {
+			c := p.proxies[(p.next+i)%len(p.proxies)]
+			if c.available(now) {
+				s = c
+				p.next = (p.next + i + 1) % len(p.proxies)
+				break
+			}
+		}
+	}
+	if s == nil {
+		return nil, ErrNoProxy
+	}
+	if p.opt.Strategy == ProxySticky {
+		if _, ok := p.sticky[host]; !ok && len(p.sticky) >= proxyStickyHosts {
+			p.evictSticky(now)
+		}
+		p.sticky[host] = s
+	}
+	return s.url, nil
+}
+
+// evictSticky removes the hosts of the benched proxies, then the arbitrary hosts
+// until a quarter of the sticky hosts are removed. Must be called with the p.mu held.
+func (p *ProxyPool) evictSticky(now time.Time) {
+	for host, s := range p.sticky {
+		if !s.available(now) {
+			delete(p.sticky, host)
+		}
+	}
+	for host := range p.sticky {
+		if len(p.sticky) <= proxyStickyHosts*3/4 {
+			break
+		}
+		delete(p.sticky, host)
+	}
+}
+
+// Success resets the failures of the proxy.
+func (p *ProxyPool) Success(proxy *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s := p.state(proxy); s != nil {
+		s.failures = 0
+		s.benched = time.Time{}
+	}
+}
+
+// Failure records a failure of the proxy, benches the proxy
+// if the consecutive failures reach the MaxFailures.
+func (p *ProxyPool) Failure(proxy *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s := p.state(proxy); s != nil {
+		s.failures++
+		if s.failures >= p.opt.MaxFailures {
+			s.benched = time.Now().Add(p.opt.BenchTime)
+		}
+	}
+}
+
+// Status returns the health status of the proxies.
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	ret := make([]ProxyStatus, 0, len(p.proxies))
+	for _, s := range p.proxies {
+		status := ProxyStatus{URL: s.url.String(), Failures: s.failures}
+		if !s.available(now) {
+			benched := s.benched
+			status.Benched = &benched
+		}
+		ret = append(ret, status)
+	}
+	return ret
+}
+
+func (p *ProxyPool) state(proxy *url.URL) *proxyState {
+	for _, s := range p.proxies {
+		if s.url == proxy || s.url.String() == proxy.String() {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *proxyState) available(now time.Time) bool {
+	return s.benched.IsZero() || now.After(s.benched)
+}
+
+// ProxyMiddleware returns the FetchMiddleware which sends the requests through
+// the proxy selected from the ProxyPool, the Fetch should use the ProxyFromRequest.
+// The request with the proxy on context (WithProxyURL, WithoutProxy) is not changed,
+// if the proxy is one of the ProxyPool, such as selected by the ProxyPool.Next,
+// its health is recorded also.
+// A connection error or 407 Proxy Authentication Required response is the failure of the proxy.
+func ProxyMiddleware(pool *ProxyPool) FetchMiddleware {
+	return func(fetch Fetch) Fetch {
+		return FetchFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			if v := ctx.Value(&requestProxyKey); v != nil {
+				res, err := fetch.Do(req)
+				if proxy, ok := v.(*url.URL); ok && proxy != noProxy && pool.Contains(proxy) {
+					pool.record(proxy, res, err)
+				}
+				return res, err
+			}
+			proxy, err := pool.Next(req.URL.Host)
+			if err != nil {
+				return nil, err
+			}
+			// the context.WithValue avoids to modify the shared ski.Context
+			res, err := fetch.Do(req.WithContext(context.WithValue(ctx, &requestProxyKey, proxy)))
+			pool.record(proxy, res, err)
+			return res, err
+		})
+	}
+}
+
+// Contains reports whether the proxy is one of the ProxyPool.
+func (p *ProxyPool) Contains(proxy *url.URL) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state(proxy) != nil
+}
+
+// record records the success or failure of the proxy by the result of the request.
+func (p *ProxyPool) record(proxy *url.URL, res *http.Response, err error) {
+	switch {
+	case err != nil:
+		if !errors.Is(err, context.Canceled) {
+			p.Failure(proxy)
+		}
+	case res.StatusCode == http.StatusProxyAuthRequired:
+		p.Failure(proxy)
+	default:
+		p.Success(proxy)
+	}
+}
@@ -0,0 +1,239 @@
+package ski
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyPool(t *testing.T) {
+	t.Parallel()
+	proxies := []string{"http://127.0.0.1:1", "http://127.0.0.1:2", "socks5://127.0.0.1:3"}
+
+	_, err := NewProxyPool(proxies, ProxyPoolOptions{Strategy: "unknown"})
+	assert.Error(t, err)
+	_, err = NewProxyPool([]string{"ftp://127.0.0.1"}, ProxyPoolOptions{})
+	assert.Error(t, err)
+	_, err = NewProxyPool(nil, ProxyPoolOptions{})
+	assert.Error(t, err)
+
+	t.Run("round-robin", func(t *testing.T) {
+		pool, err := NewProxyPool(proxies, ProxyPoolOptions{MaxFailures: 2})
+		if !assert.NoError(t, err) {
+			return
+		}
+		for i := 0; i < 6; i++ {
+			u, err := pool.Next("example.com")
+			if assert.NoError(t, err) {
+				assert.Equal(t, proxies[i%3], u.String())
+			}
+		}
+
+		bad, _ := url.Parse(proxies[1])
+		pool.Failure(bad)
+		pool.Failure(bad)
+		assert.NotNil(t, pool.Status()[1].Benched)
+		for i := 0; i < 4; i++ {
+			u, _ := pool.Next("example.com")
+			assert.NotEqual(t, proxies[1], u.String())
+		}
+
+		data, err := json.Marshal(pool.Status()[1])
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(data), `"benched":"`)
+		}
+
+		pool.Success(bad)
+		assert.Equal(t, ProxyStatus{URL: proxies[1]}, pool.Status()[1])
+		data, err = json.Marshal(pool.Status()[1])
+		if assert.NoError(t, err) {
+			assert.NotContains(t, string(data), "benched")
+		}
+	})
+
+	t.Run("sticky", func(t *testing.T) {
+		pool, err := NewProxyPool(proxies, ProxyPoolOptions{Strategy: ProxySticky, MaxFailures: 1})
+		if !assert.NoError(t, err) {
+			return
+		}
+		a, _ := pool.Next("a.com")
+		b, _ := pool.Next("b.com")
+		assert.NotEqual(t, a, b)
+		for i := 0; i < 3; i++ {
+			u, _ := pool.Next("a.com")
+			assert.Equal(t, a, u)
+		}
+		pool.Failure(a)
+		u, _ := pool.Next("a.com")
+		assert.NotEqual(t, a, u)
+
+		// the sticky hosts are bounded
+		for i := 0; i < proxyStickyHosts+1; i++ {
+			_, _ = pool.Next(strconv.Itoa(i) + ".com")
+		}
+		assert.LessOrEqual(t, len(pool.sticky), proxyStickyHosts)
+	})
+
+	t.Run("benched", func(t *testing.T) {
+		pool, err := NewProxyPool(proxies, ProxyPoolOptions{Strategy: ProxyRandom, MaxFailures: 1, BenchTime: 50 * time.Millisecond})
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, s := range pool.Status() {
+			u, _ := url.Parse(s.URL)
+			pool.Failure(u)
+		}
+		_, err = pool.Next("example.com")
+		assert.ErrorIs(t, err, ErrNoProxy)
+		time.Sleep(60 * time.Millisecond)
+		_, err = pool.Next("example.com")
+		assert.NoError(t, err)
+	})
+}
+
+func TestProxyMiddleware(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(ts.Close)
+
+	socks := newSocks5Server(t)
+	pool, err := NewProxyPool([]string{"socks5://" + socks.Addr().String(), "http://127.0.0.1:1"}, ProxyPoolOptions{MaxFailures: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := &http.Client{Transport: &http.Transport{Proxy: ProxyFromRequest, DisableKeepAlives: true}}
+	fetch := ChainFetch(client, ProxyMiddleware(pool))
+
+	do := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		res, err := fetch.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(t, "ok", string(body))
+		return nil
+	}
+
+	ctx := context.Background()
+	assert.NoError(t, do(ctx))
+	// the unreachable proxy is benched
+	assert.Error(t, do(ctx))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, do(ctx))
+	}
+	assert.EqualValues(t, 4, socks.count.Load())
+	assert.NotNil(t, pool.Status()[1].Benched)
+
+	assert.NoError(t, do(WithoutProxy(NewContext(ctx, nil))))
+	assert.EqualValues(t, 4, socks.count.Load())
+
+	// the health of the proxy selected explicitly from the pool is recorded
+	unreachable, _ := url.Parse("http://127.0.0.1:1")
+	assert.True(t, pool.Contains(unreachable))
+	pool.Success(unreachable)
+	assert.Error(t, do(WithProxyURL(NewContext(ctx, nil), unreachable)))
+	assert.Equal(t, 1, pool.Status()[1].Failures)
+	other, _ := url.Parse("http://127.0.0.1:2")
+	assert.False(t, pool.Contains(other))
+}
+
+type socks5Server struct {
+	net.Listener
+	count atomic.Int32
+}
+
+// newSocks5Server returns a SOCKS5 server only supports the no authentication CONNECT.
+func newSocks5Server(t *testing.T) *socks5Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	s := &socks5Server{Listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.count.Add(1)
+			go func() {
+				defer conn.Close()
+				if err := s.serve(conn); err != nil && !errors.Is(err, io.EOF) {
+					t.Log(err)
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) serve(conn net.Conn) error {
+	buf := make([]byte, 262)
+	// version, methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return err
+	}
+	// version, command, reserved, address type
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return err
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return err
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return err
+		}
+		n := buf[0]
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return err
+		}
+		host = string(buf[:n])
+	default:
+		return errors.New("unsupported address type")
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return err
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	defer target.Close()
+	if _, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return err
+	}
+	go func() { _, _ = io.Copy(target, conn) }()
+	_, err = io.Copy(conn, target)
+	return err
+}
@@ -0,0 +1,88 @@
+package ski
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// DefaultMaxRedirects default max redirects to follow
+const DefaultMaxRedirects = 10
+
+// RedirectOptions the redirect policy of the http.Client created by NewFetchWithOptions.
+type RedirectOptions struct {
+	// Disable returns the redirect response instead of following it.
+	Disable bool `yaml:"disable" json:"disable"`
+	// MaxRedirects the max redirects to follow, default is 10.
+	MaxRedirects int `yaml:"max-redirects" json:"maxRedirects"`
+	// SameHost only follows the redirects to the same host,
+	// returns the redirect response of the cross-host redirect.
+	SameHost bool `yaml:"same-host" json:"sameHost"`
+}
+
+var requestRedirectKey byte
+
+// WithRedirect returns a copy of parent context in which the RedirectOptions associated with context,
+// the non-zero fields of RedirectOptions overrides the redirect policy of the http.Client.
+// The options are not set to the shared Context, only the requests of the returned context are affected.
+func WithRedirect(ctx context.Context, opt RedirectOptions) context.Context {
+	return context.WithValue(ctx, &requestRedirectKey, opt)
+}
+
+// RedirectFromContext returns the RedirectOptions on context.
+func RedirectFromContext(ctx context.Context) (RedirectOptions, bool) {
+	opt, ok := ctx.Value(&requestRedirectKey).(RedirectOptions)
+	return opt, ok
+}
+
+// checkRedirect is the http.Client CheckRedirect of the policy.
+func (opt RedirectOptions) checkRedirect(req *http.Request, via []*http.Request) error {
+	if o, ok := RedirectFromContext(req.Context()); ok {
+		opt = opt.merge(o)
+	}
+	if opt.Disable {
+		return http.ErrUseLastResponse
+	}
+	if opt.SameHost && req.URL.Host != via[0].URL.Host {
+		return http.ErrUseLastResponse
+	}
+	maxRedirects := opt.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return nil
+}
+
+// merge returns the options overridden by the non-zero fields of the other.
+func (opt RedirectOptions) merge(other RedirectOptions) RedirectOptions {
+	if other.Disable {
+		opt.Disable = true
+	}
+	if other.MaxRedirects != 0 {
+		opt.MaxRedirects = other.MaxRedirects
+	}
+	if other.SameHost {
+		opt.SameHost = true
+	}
+	return opt
+}
+
+// RedirectChain returns the URLs of the requests followed to the response,
+// the first is the original request URL and the last is the final URL.
+func RedirectChain(res *http.Response) []*url.URL {
+	var chain []*url.URL
+	for req := res.Request; req != nil; {
+		chain = append(chain, req.URL)
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	slices.Reverse(chain)
+	return chain
+}
@@ -0,0 +1,94 @@
+package ski
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	t.Parallel()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(other.Close)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/other":
+			http.Redirect(w, r, other.URL, http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/redirect/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+			if n > 0 {
+				http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	do := func(ctx context.Context, opt RedirectOptions, path string) (*http.Response, error) {
+		fetch, _ := NewFetchWithOptions(FetchOptions{Redirect: opt})
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+path, nil)
+		res, err := fetch.Do(req)
+		if err == nil {
+			_ = res.Body.Close()
+		}
+		return res, err
+	}
+	ctx := context.Background()
+
+	res, err := do(ctx, RedirectOptions{}, "/redirect/3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		chain := RedirectChain(res)
+		if assert.Len(t, chain, 4) {
+			assert.Equal(t, ts.URL+"/redirect/3", chain[0].String())
+			assert.Equal(t, ts.URL+"/redirect/0", chain[3].String())
+		}
+	}
+
+	res, err = do(ctx, RedirectOptions{Disable: true}, "/redirect/3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+		assert.Len(t, RedirectChain(res), 1)
+	}
+
+	_, err = do(ctx, RedirectOptions{MaxRedirects: 2}, "/redirect/3")
+	assert.ErrorContains(t, err, "stopped after 2 redirects")
+	_, err = do(ctx, RedirectOptions{MaxRedirects: 3}, "/redirect/3")
+	assert.NoError(t, err)
+
+	res, err = do(ctx, RedirectOptions{SameHost: true}, "/other")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+	}
+	res, err = do(ctx, RedirectOptions{}, "/other")
+	if assert.NoError(t, err) {
+		assert.Equal(t, other.URL, res.Request.URL.String())
+	}
+
+	res, err = do(WithRedirect(ctx, RedirectOptions{Disable: true}), RedirectOptions{}, "/redirect/1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+	}
+
+	// the options of the context are merged over the client policy
+	res, err = do(WithRedirect(ctx, RedirectOptions{MaxRedirects: 3}), RedirectOptions{SameHost: true}, "/other")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+	}
+	_, err = do(WithRedirect(ctx, RedirectOptions{MaxRedirects: 2}), RedirectOptions{MaxRedirects: 5}, "/redirect/3")
+	assert.ErrorContains(t, err, "stopped after 2 redirects")
+	res, err = do(WithRedirect(ctx, RedirectOptions{MaxRedirects: 5}), RedirectOptions{Disable: true}, "/redirect/1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusFound, res.StatusCode)
+	}
+
+	// the options are not set to the shared context
+	shared := NewContext(ctx, nil)
+	_ = WithRedirect(shared, RedirectOptions{Disable: true})
+	_, ok := RedirectFromContext(shared)
+	assert.False(t, ok)
+}
@@ -0,0 +1,406 @@
+package ski
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// A Sink writes the results of the Executor, such as:
+//
+//	ret, err := executor.Exec(ctx, arg)
+//	if err != nil {
+//		return err
+//	}
+//	return sink.Write(ctx, ret)
+type Sink interface {
+	// Write writes the result, the slice or Iterator result is written as the items
+	// except the webhook sink posts the whole result.
+	Write(ctx context.Context, v any) error
+	// Close flushes and closes the sink.
+	Close() error
+}
+
+// sinkItems returns the items of the result.
+func sinkItems(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case Iterator:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = v.At(i)
+		}
+		return items
+	default:
+		return []any{v}
+	}
+}
+
+// multiSink writes the result to all the sinks.
+type multiSink []Sink
+
+// MultiSink returns the Sink writes the result to all the sinks.
+func MultiSink(sinks ...Sink) Sink { return multiSink(sinks) }
+
+func (s multiSink) Write(ctx context.Context, v any) error {
+	var errs []error
+	for _, sink := range s {
+		errs = append(errs, sink.Write(ctx, v))
+	}
+	return errors.Join(errs...)
+}
+
+func (s multiSink) Close() error {
+	var errs []error
+	for _, sink := range s {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// FileSinkOptions the options of the rotating file sink.
+type FileSinkOptions struct {
+	// MaxSize the max bytes of the file, the file is rotated when exceeded, 0 means never rotate.
+	MaxSize int64 `yaml:"max-size" json:"maxSize"`
+	// MaxBackups the max number of the rotated files to keep, 0 means keep all.
+	MaxBackups int `yaml:"max-backups" json:"maxBackups"`
+}
+
+// fileSink appends the items as JSON Lines to the file.
+type fileSink struct {
+	sync.Mutex
+	name string
+	opt  FileSinkOptions
+	file *os.File
+	size int64
+}
+
+// NewFileSink returns the Sink appends the items as JSON Lines to the file,
+// the rotated file is renamed with the timestamp, such as out-20060102T150405.000.jsonl.
+func NewFileSink(name string, opt FileSinkOptions) (Sink, error) {
+	s := &fileSink{name: name, opt: opt}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	if dir := filepath.Dir(s.name); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(s.name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.file, s.size = f, stat.Size()
+	return nil
+}
+
+func (s *fileSink) Write(_ context.Context, v any) error {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	for _, item := range sinkItems(v) {
+		if err := enc.Encode(item); err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if s.opt.MaxSize > 0 && s.size > 0 && s.size+int64(buf.Len()) > s.opt.MaxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	return err
+}
+
+// fileSinkBackupLayout the timestamp layout of the backup file name.
+const fileSinkBackupLayout = "20060102T150405.000"
+
+// rotate renames the current file with the timestamp and removes the old backups.
+func (s *fileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	ext := filepath.Ext(s.name)
+	prefix := strings.TrimSuffix(s.name, ext) + "-"
+	if err := os.Rename(s.name, prefix+time.Now().Format(fileSinkBackupLayout)+ext); err != nil {
+		return err
+	}
+	if s.opt.MaxBackups > 0 {
+		backups, err := s.backups()
+		if err != nil {
+			return err
+		}
+		// the timestamp names are sorted by time
+		slices.Sort(backups)
+		for len(backups) > s.opt.MaxBackups {
+			if err = os.Remove(backups[0]); err != nil {
+				return err
+			}
+			backups = backups[1:]
+		}
+	}
+	return s.open()
+}
+
+// backups returns the backup files, the name is the file name with the timestamp
+// of the fileSinkBackupLayout before the extension, such as out-20060102T150405.000.jsonl.
+func (s *fileSink) backups() ([]string, error) {
+	dir, base := filepath.Split(s.name)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := name[len(prefix) : len(name)-len(ext)]
+		if len(stamp) != len(fileSinkBackupLayout) {
+			continue
+		}
+		if _, err = time.Parse(fileSinkBackupLayout, stamp); err == nil {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	return backups, nil
+}
+
+func (s *fileSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// DirSinkOptions the options of the directory sink.
+type DirSinkOptions struct {
+	// Key the key of the object item as the file name, such as "id",
+	// default is the timestamp and the sequence.
+	Key string `yaml:"key" json:"key"`
+}
+
+// dirSink writes each item to the JSON file of the directory.
+type dirSink struct {
+	sync.Mutex
+	dir string
+	opt DirSinkOptions
+	seq int
+}
+
+// NewDirSink returns the Sink writes each item to the JSON file of the directory,
+// the directory will be created if it does not exist.
+func NewDirSink(dir string, opt DirSinkOptions) (Sink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dirSink{dir: dir, opt: opt}, nil
+}
+
+func (s *dirSink) name(item any) string {
+	if m, ok := item.(map[string]any); ok && s.opt.Key != "" {
+		if v, ok := m[s.opt.Key]; ok && v != nil {
+			// the file name is sanitized
+			name := strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+					return r
+				}
+				return '_'
+			}, fmt.Sprint(v))
+			if name = strings.Trim(name, "."); name != "" {
+				return name
+			}
+		}
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.seq++
+	return fmt.Sprintf("%s-%06d", time.Now().Format("20060102T150405"), s.seq)
+}
+
+func (s *dirSink) Write(_ context.Context, v any) error {
+	for _, item := range sinkItems(v) {
+		data, err := json.MarshalIndent(item, "", "  ")
+		if err != nil {
+			return err
+		}
+		// write to the temporary file then rename for atomic replacement
+		tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+		if err != nil {
+			return err
+		}
+		if _, err = tmp.Write(data); err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+		if err = tmp.Close(); err != nil {
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+		if err = os.Rename(tmp.Name(), filepath.Join(s.dir, s.name(item)+".json")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *dirSink) Close() error { return nil }
+
+// WebhookOptions the options of the webhook sink.
+type WebhookOptions struct {
+	// Method the request method, default is POST.
+	Method string `yaml:"method" json:"method"`
+	// Header the request headers.
+	Header http.Header `yaml:"header" json:"header"`
+}
+
+// webhookSink posts the result as JSON to the URL.
+type webhookSink struct {
+	fetch Fetch
+	url   string
+	opt   WebhookOptions
+}
+
+// NewWebhookSink returns the Sink posts the whole result as JSON to the URL,
+// the response status other than 2xx is an error.
+func NewWebhookSink(fetch Fetch, url string, opt WebhookOptions) Sink {
+	if opt.Method == "" {
+		opt.Method = http.MethodPost
+	}
+	return &webhookSink{fetch, url, opt}
+}
+
+func (s *webhookSink) Write(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, s.opt.Method, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, values := range s.opt.Header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.fetch.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: %s", s.url, res.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Close() error { return nil }
+
+// sqlSink inserts each item as the JSON row to the table.
+type sqlSink struct {
+	db     *sql.DB
+	insert string
+}
+
+// NewSQLSink returns the Sink inserts each item as the JSON text row to the table,
+// the table will be created if it does not exist:
+//
+//	CREATE TABLE IF NOT EXISTS "table" (
+//		id INTEGER PRIMARY KEY AUTOINCREMENT,
+//		created_at TIMESTAMP NOT NULL,
+//		data TEXT NOT NULL
+//	)
+//
+// The statements are SQLite flavored, the database should be opened with a SQLite driver.
+// Close does not close the database.
+func NewSQLSink(ctx context.Context, db *sql.DB, table string) (Sink, error) {
+	if !isValidTable(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "`+table+`" (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at TIMESTAMP NOT NULL,
+	data TEXT NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlSink{db, `INSERT INTO "` + table + `" (created_at, data) VALUES (?, ?)`}, nil
+}
+
+func isValidTable(s string) bool {
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *sqlSink) Write(ctx context.Context, v any) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	now := time.Now().UTC()
+	var data []byte
+	for _, item := range sinkItems(v) {
+		if data, err = json.Marshal(item); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, s.insert, now, string(data)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlSink) Close() error { return nil }
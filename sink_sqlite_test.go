@@ -0,0 +1,61 @@
+//go:build sqlite
+
+package ski
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLSinkSQLite(t *testing.T) {
+	t.Parallel()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "sink.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	sink, err := NewSQLSink(ctx, db, "items")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sink.Write(ctx, []any{map[string]any{"a": 1}, "b"}))
+	assert.NoError(t, sink.Write(ctx, nil))
+	// the existing table is reused
+	sink, err = NewSQLSink(ctx, db, "items")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sink.Write(ctx, "c"))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, sink.Write(canceled, "d"))
+
+	rows, err := db.QueryContext(ctx, `SELECT id, created_at, data FROM "items" ORDER BY id`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rows.Close()
+	var data []string
+	for rows.Next() {
+		var (
+			id        int
+			createdAt time.Time
+			item      string
+		)
+		if assert.NoError(t, rows.Scan(&id, &createdAt, &item)) {
+			assert.Equal(t, len(data)+1, id)
+			assert.WithinDuration(t, time.Now(), createdAt, time.Minute)
+			data = append(data, item)
+		}
+	}
+	assert.NoError(t, rows.Err())
+	assert.Equal(t, []string{`{"a":1}`, `"b"`, "null", `"c"`}, data)
+}
@@ -0,0 +1,171 @@
+package ski
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.jsonl")
+	// the unrelated files are not the backups
+	unrelated := []string{"out-final.jsonl", "out-20200101T000000.000.json", "out-20200101T000000.jsonl"}
+	for _, file := range unrelated {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0o600))
+	}
+	sink, err := NewFileSink(name, FileSinkOptions{MaxSize: 20, MaxBackups: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx := context.Background()
+	assert.NoError(t, sink.Write(ctx, []any{map[string]any{"a": 1}, "b"}))
+	data, _ := os.ReadFile(name)
+	assert.Equal(t, "{\"a\":1}\n\"b\"\n", string(data))
+
+	// exceeds the max size
+	assert.NoError(t, sink.Write(ctx, "cccccccccccc"))
+	data, _ = os.ReadFile(name)
+	assert.Equal(t, "\"cccccccccccc\"\n", string(data))
+	assert.NoError(t, sink.Write(ctx, "dddddddddddd"))
+	assert.NoError(t, sink.Close())
+
+	for _, file := range unrelated {
+		assert.FileExists(t, filepath.Join(dir, file))
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "out-2*.*.jsonl"))
+	if assert.Len(t, backups, 1) {
+		data, _ = os.ReadFile(backups[0])
+		assert.Equal(t, "\"cccccccccccc\"\n", string(data))
+	}
+	assert.ErrorIs(t, sink.Write(ctx, "e"), os.ErrClosed)
+}
+
+func TestDirSink(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "items")
+	sink, err := NewDirSink(dir, DirSinkOptions{Key: "id"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sink.Write(context.Background(), NewIterator([]any{
+		map[string]any{"id": "a/b", "v": 1},
+		map[string]any{"id": 2, "v": 2},
+		map[string]any{"v": 3},
+	})))
+	entries, _ := os.ReadDir(dir)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if assert.Len(t, names, 3) {
+		assert.Equal(t, "2.json", names[0])
+		assert.True(t, strings.HasSuffix(names[1], "-000001.json"))
+		assert.Equal(t, "a_b.json", names[2])
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "2.json"))
+	assert.JSONEq(t, `{"id":2,"v":2}`, string(data))
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	sink := NewWebhookSink(http.DefaultClient, ts.URL, WebhookOptions{Header: http.Header{"X-Token": {"token"}}})
+	assert.NoError(t, sink.Write(ctx, []any{1, "a"}))
+	assert.Equal(t, []string{`POST application/json [1,"a"]`}, received)
+
+	sink = NewWebhookSink(http.DefaultClient, ts.URL, WebhookOptions{})
+	assert.ErrorContains(t, sink.Write(ctx, 1), "401 Unauthorized")
+}
+
+func TestSQLSink(t *testing.T) {
+	t.Parallel()
+	rec := new(sqlRecorder)
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err := NewSQLSink(ctx, db, `items"; DROP TABLE items`)
+	assert.ErrorContains(t, err, "invalid table name")
+
+	sink, err := NewSQLSink(ctx, db, "items")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sink.Write(ctx, []any{map[string]any{"a": 1}, "b"}))
+	rec.fail = true
+	assert.Error(t, sink.Write(ctx, "c"))
+
+	rec.Lock()
+	defer rec.Unlock()
+	if assert.Len(t, rec.execs, 6) {
+		assert.True(t, strings.HasPrefix(rec.execs[0], `CREATE TABLE IF NOT EXISTS "items"`))
+		assert.Equal(t, []string{
+			`INSERT INTO "items" (created_at, data) VALUES (?, ?) {"a":1}`,
+			`INSERT INTO "items" (created_at, data) VALUES (?, ?) "b"`,
+			"COMMIT",
+			`INSERT INTO "items" (created_at, data) VALUES (?, ?) "c"`,
+			"ROLLBACK",
+		}, rec.execs[1:])
+	}
+}
+
+// sqlRecorder the database/sql driver records the executed statements.
+type sqlRecorder struct {
+	sync.Mutex
+	execs []string
+	fail  bool
+}
+
+func (r *sqlRecorder) Connect(context.Context) (driver.Conn, error) { return r, nil }
+func (r *sqlRecorder) Driver() driver.Driver                        { return nil }
+func (r *sqlRecorder) Close() error                                 { return nil }
+func (r *sqlRecorder) Begin() (driver.Tx, error)                    { return r, nil }
+func (r *sqlRecorder) Commit() error                                { r.record("COMMIT"); return nil }
+func (r *sqlRecorder) Rollback() error                              { r.record("ROLLBACK"); return nil }
+
+func (r *sqlRecorder) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *sqlRecorder) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 2 {
+		query += " " + args[1].Value.(string)
+	}
+	r.record(query)
+	r.Lock()
+	defer r.Unlock()
+	if r.fail {
+		return nil, errors.New("failed")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (r *sqlRecorder) record(s string) {
+	r.Lock()
+	defer r.Unlock()
+	r.execs = append(r.execs, s)
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+	jscache "github.com/shiroyk/ski/js/modules/cache"
+	"gopkg.in/yaml.v3"
+)
+
+var configFlag = flag.String("config", "", "config file, YAML or TOML by the .toml extension (default $SKI_CONFIG, or ski/config.yaml of the user config directory)")
+
+// cliConfig the configuration of the CLI, the precedence is the command line flags,
+// the SKI_ environment variables such as SKI_FETCH_TIMEOUT, the config file, then the defaults.
+type cliConfig struct {
+	// Timeout the run timeout, same as -t.
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+	// Charset the charset of the response body, same as -charset.
+	Charset   string              `yaml:"charset" json:"charset"`
+	Scheduler js.SchedulerOptions `yaml:"scheduler" json:"scheduler"`
+	Loader    loaderConfig        `yaml:"loader" json:"loader"`
+	Fetch     fetchConfig         `yaml:"fetch" json:"fetch"`
+	Proxy     proxyConfig         `yaml:"proxy" json:"proxy"`
+	Cache     cacheConfig         `yaml:"cache" json:"cache"`
+	Log       logConfig           `yaml:"log" json:"log"`
+}
+
+// loaderConfig the JS module loader config.
+type loaderConfig struct {
+	// BasePath the base directory of the relative modules, default is the working directory.
+	BasePath string `yaml:"base-path" json:"basePath"`
+}
+
+// fetchConfig the HTTP client config.
+type fetchConfig struct {
+	ski.FetchOptions `yaml:",inline"`
+	Headers          []string `yaml:"headers" json:"headers"`
+	UserAgents       []string `yaml:"user-agents" json:"userAgents"`
+	Retry            int      `yaml:"retry" json:"retry"`
+	Rate             float64  `yaml:"rate" json:"rate"`
+	Concurrency      int      `yaml:"concurrency" json:"concurrency"`
+}
+
+// proxyConfig the proxy config.
+type proxyConfig struct {
+	URLs     []string `yaml:"urls" json:"urls"`
+	File     string   `yaml:"file" json:"file"`
+	Strategy string   `yaml:"strategy" json:"strategy"`
+}
+
+// cacheConfig the cache config.
+type cacheConfig struct {
+	// Backend the backend of the JS cache module: memory, file.
+	Backend string `yaml:"backend" json:"backend"`
+	// Dir the directory of the file backend.
+	Dir string `yaml:"dir" json:"dir"`
+	// HTTP the directory of the HTTP responses cache, same as -http-cache.
+	HTTP    string `yaml:"http" json:"http"`
+	Offline bool   `yaml:"offline" json:"offline"`
+}
+
+// logConfig the logger config.
+type logConfig struct {
+	// Level the min level: debug, info, warn, error.
+	Level string `yaml:"level" json:"level"`
+	// Format the handler format: text, json.
+	Format string `yaml:"format" json:"format"`
+	// Output the destination: stderr, stdout, or the file path.
+	Output string `yaml:"output" json:"output"`
+	// Filter the levels of the subsystems, such as fetch=warn,schedule=debug.
+	Filter string `yaml:"filter" json:"filter"`
+}
+
+// conf the effective configuration.
+var conf = defaultConfig()
+
+func defaultConfig() cliConfig {
+	return cliConfig{
+		Scheduler: js.SchedulerOptions{
+			MaxVMs:             uint(runtime.GOMAXPROCS(0)),
+			MaxRetriesGetVM:    js.DefaultMaxRetriesGetVM,
+			MaxTimeToWaitGetVM: js.DefaultMaxTimeToWaitGetVM,
+		},
+		Cache: cacheConfig{Backend: "memory"},
+		Log:   logConfig{Level: "info", Format: "text", Output: "stderr"},
+	}
+}
+
+// configFlags the config paths are the same as the flags.
+var configFlags = map[string]string{
+	"timeout":                       "t",
+	"charset":                       "charset",
+	"fetch.ca-file":                 "cacert",
+	"fetch.cert-file":               "cert",
+	"fetch.key-file":                "key",
+	"fetch.min-tls-version":         "tls-min",
+	"fetch.insecure-skip-verify":    "insecure",
+	"fetch.disable-http2":           "http1",
+	"fetch.max-idle-conns":          "max-idle-conns",
+	"fetch.max-idle-conns-per-host": "max-idle-conns-per-host",
+	"fetch.tls-handshake-timeout":   "tls-handshake-timeout",
+	"fetch.response-header-timeout": "response-header-timeout",
+	"fetch.idle-conn-timeout":       "idle-conn-timeout",
+	"fetch.max-conns-per-host":      "max-conns-per-host",
+	"fetch.dial-timeout":            "connect-timeout",
+	"fetch.timeout":                 "request-timeout",
+	"fetch.redirect.max-redirects":  "max-redirects",
+	"fetch.redirect.same-host":      "same-host-redirect",
+	"fetch.headers":                 "H",
+	"fetch.user-agents":             "A",
+	"fetch.retry":                   "retry",
+	"fetch.rate":                    "rate",
+	"fetch.concurrency":             "concurrency",
+	"proxy.urls":                    "x",
+	"proxy.file":                    "proxy-file",
+	"proxy.strategy":                "proxy-strategy",
+	"cache.http":                    "http-cache",
+	"cache.offline":                 "offline",
+	"log.level":                     "log-level",
+	"log.format":                    "log-format",
+	"log.output":                    "log-output",
+	"log.filter":                    "log-filter",
+}
+
+// loadConfig loads the config file and the environment variables, syncs the
+// values with the flags, then applies the logger and cache config.
+func loadConfig() error {
+	if err := readConfig(flag.CommandLine, &conf); err != nil {
+		return err
+	}
+	return applyConfig()
+}
+
+// readConfig reads the config file of the -config flag and the environment variables
+// to the config, then syncs the values with the flags of the FlagSet. The flag set
+// explicitly overrides the environment variable, which overrides the config file.
+func readConfig(fs *flag.FlagSet, c *cliConfig) error {
+	set := make(map[string]bool)
+	name, required := "", true
+	if f := fs.Lookup("config"); f != nil {
+		name = f.Value.String()
+	}
+	if name == "" {
+		name = os.Getenv("SKI_CONFIG")
+	}
+	if name == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			name, required = filepath.Join(dir, "ski", "config.yaml"), false
+		}
+	}
+	if name != "" {
+		data, err := os.ReadFile(name) //nolint:gosec
+		switch {
+		case err == nil:
+			if err = decodeConfig(name, data, c, set); err != nil {
+				return fmt.Errorf("config %s: %w", name, err)
+			}
+		case required || !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	return walkConfig(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.Value) error {
+		env := "SKI_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+		if value, ok := os.LookupEnv(env); ok {
+			if err := setField(field, value); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+			set[path] = true
+		}
+		f := fs.Lookup(configFlags[path])
+		if f == nil {
+			return nil
+		}
+		switch {
+		case explicit[f.Name]:
+			// the flag overrides the config
+		case set[path]:
+			if field.Kind() == reflect.Slice {
+				for i := 0; i < field.Len(); i++ {
+					if err := f.Value.Set(field.Index(i).String()); err != nil {
+						return fmt.Errorf("%s: %w", path, err)
+					}
+				}
+				return nil
+			}
+			if err := f.Value.Set(formatField(field)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			return nil
+		}
+		if s, ok := f.Value.(*stringsFlag); ok {
+			field.Set(reflect.ValueOf([]string(*s)))
+			return nil
+		}
+		return setField(field, f.Value.String())
+	})
+}
+
+// decodeConfig decodes the YAML or TOML config, the set is the paths of the config.
+func decodeConfig(name string, data []byte, c *cliConfig, set map[string]bool) error {
+	var m map[string]any
+	if strings.EqualFold(filepath.Ext(name), ".toml") {
+		var err error
+		if err = toml.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(m); err != nil {
+			return err
+		}
+	} else if err := yaml.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	var paths func(prefix string, m map[string]any)
+	paths = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			set[prefix+k] = true
+			if sub, ok := v.(map[string]any); ok {
+				paths(prefix+k+".", sub)
+			}
+		}
+	}
+	paths("", m)
+	return nil
+}
+
+// applyConfig initializes the logger and registers the cache backend of the JS cache module.
+func applyConfig() error {
+	if err := initLogger(); err != nil {
+		return err
+	}
+
+	switch conf.Cache.Backend {
+	case "", "memory":
+	case "file":
+		if conf.Cache.Dir == "" {
+			return errors.New("cache backend file requires the cache dir")
+		}
+		cache, err := ski.NewFileCache(conf.Cache.Dir)
+		if err != nil {
+			return err
+		}
+		js.Register("cache", &jscache.Cache{Cache: cache})
+	default:
+		return fmt.Errorf("invalid cache backend %q", conf.Cache.Backend)
+	}
+	return nil
+}
+
+// initScheduler creates the scheduler with the loader, the remote modules
+// are loaded by the fetch.
+func initScheduler(fetch ski.Fetch) error {
+	fallback := js.DefaultFileLoader(fetch)
+	loaderOptions := []js.LoaderOption{js.WithFileLoader(fallback)}
+	if conf.Loader.BasePath != "" {
+		base, err := filepath.Abs(conf.Loader.BasePath)
+		if err != nil {
+			return err
+		}
+		// the default file loader only reads the relative paths of the working directory
+		loaderOptions = []js.LoaderOption{
+			js.WithBaseLoader(&url.URL{Scheme: "file", Path: filepath.ToSlash(base)}),
+			js.WithFileLoader(func(specifier *url.URL, name string) ([]byte, error) {
+				if specifier.Scheme == "file" {
+					return os.ReadFile(filepath.FromSlash(specifier.Path))
+				}
+				return fallback(specifier, name)
+			}),
+		}
+	}
+	opt := conf.Scheduler
+	opt.Loader = js.NewModuleLoader(loaderOptions...)
+	prev := js.GetScheduler()
+	js.SetScheduler(js.NewScheduler(opt))
+	_ = prev.Close()
+	return nil
+}
+
+// walkConfig calls the fn with the path of the yaml tags and the value of each field.
+func walkConfig(v reflect.Value, prefix string, fn func(path string, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" || !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+		if opts == "inline" {
+			if err := walkConfig(field, prefix, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		switch field.Interface().(type) {
+		case time.Duration, []string:
+		default:
+			if field.Kind() == reflect.Struct {
+				if err := walkConfig(field, prefix+name+".", fn); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := fn(prefix+name, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField sets the field with the string value, the slice is comma separated.
+func setField(field reflect.Value, s string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case []string:
+		var values []string
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported config type %s", field.Type())
+	}
+	return nil
+}
+
+func formatField(field reflect.Value) string {
+	if d, ok := field.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// runConfig runs the config subcommands:
+//
+//	print   prints the effective configuration as YAML, or JSON with -format json
+func runConfig(ski.Fetch) error {
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "print":
+		buf := new(bytes.Buffer)
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(conf); err != nil {
+			return err
+		}
+		if *formatFlag != formatJSON {
+			_, err := buf.WriteTo(os.Stdout)
+			return err
+		}
+		// the JSON keys are same as the YAML
+		var v any
+		if err := yaml.Unmarshal(buf.Bytes(), &v); err != nil {
+			return err
+		}
+		out := json.NewEncoder(os.Stdout)
+		out.SetIndent("", "  ")
+		return out.Encode(v)
+	default:
+		return fmt.Errorf("unknown config command %q, available: print", command)
+	}
+}
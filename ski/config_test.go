@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeTOMLConfig(t *testing.T) {
+	t.Parallel()
+	data := `
+# the comment
+timeout = "1m" # the trailing comment
+"charset" = 'gbk'
+
+[fetch]
+headers = [
+  "A: 1",
+  "B: #2, 3", # the comment in the array
+  'C: "4"',
+]
+max-idle-conns = 1_000
+rate = 2.5
+user-agents = []
+redirect = { same-host = true }
+
+[log]
+level = """debug"""
+`
+	conf, set := defaultConfig(), make(map[string]bool)
+	if assert.NoError(t, decodeConfig("config.toml", []byte(data), &conf, set)) {
+		assert.Equal(t, time.Minute, conf.Timeout)
+		assert.Equal(t, "gbk", conf.Charset)
+		assert.Equal(t, []string{"A: 1", "B: #2, 3", `C: "4"`}, conf.Fetch.Headers)
+		assert.Equal(t, 1000, conf.Fetch.MaxIdleConns)
+		assert.Equal(t, 2.5, conf.Fetch.Rate)
+		assert.Empty(t, conf.Fetch.UserAgents)
+		assert.True(t, conf.Fetch.Redirect.SameHost)
+		assert.Equal(t, "debug", conf.Log.Level)
+		assert.True(t, set["fetch.redirect.same-host"])
+	}
+
+	for _, data := range []string{
+		"[fetch", "timeout", `timeout = 1m`, `charset = "gbk`, `headers = ["A", 1m]`, "[fetch]\n[fetch]",
+	} {
+		conf := defaultConfig()
+		assert.Error(t, decodeConfig("config.toml", []byte(data), &conf, make(map[string]bool)), data)
+	}
+	conf = defaultConfig()
+	err := decodeConfig("config.toml", []byte("\n\ntimeout = 1m"), &conf, make(map[string]bool))
+	assert.ErrorContains(t, err, "line 3")
+}
+
+func TestDecodeConfig(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct{ name, data string }{
+		{"config.yaml", "timeout: 1m\nfetch:\n  retry: 2\n  redirect:\n    max-redirects: 3\n  headers: [\"A: 1\"]\n"},
+		{"config.toml", "timeout = \"1m\"\n[fetch]\nretry = 2\nheaders = [\"A: 1\"]\n[fetch.redirect]\nmax-redirects = 3\n"},
+	} {
+		conf, set := defaultConfig(), make(map[string]bool)
+		if !assert.NoError(t, decodeConfig(c.name, []byte(c.data), &conf, set), c.name) {
+			continue
+		}
+		assert.Equal(t, time.Minute, conf.Timeout, c.name)
+		assert.Equal(t, 2, conf.Fetch.Retry, c.name)
+		assert.Equal(t, 3, conf.Fetch.Redirect.MaxRedirects, c.name)
+		assert.Equal(t, []string{"A: 1"}, conf.Fetch.Headers, c.name)
+		assert.Equal(t, "info", conf.Log.Level, c.name)
+		assert.Equal(t, map[string]bool{
+			"timeout": true, "fetch": true, "fetch.retry": true, "fetch.headers": true,
+			"fetch.redirect": true, "fetch.redirect.max-redirects": true,
+		}, set, c.name)
+	}
+
+	conf := defaultConfig()
+	assert.Error(t, decodeConfig("config.yaml", []byte("unknown: 1"), &conf, make(map[string]bool)))
+	assert.Error(t, decodeConfig("config.toml", []byte("[fetch]\nunknown = 1"), &conf, make(map[string]bool)))
+}
+
+// the test can not be parallel, it sets the environment variables.
+func TestReadConfig(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(name, []byte(`
+timeout: 1m
+charset: gbk
+fetch:
+  retry: 2
+  rate: 1.5
+  headers: ["A: 1"]
+  max-conns-per-host: 4
+  tls-handshake-timeout: 5s
+log:
+  level: debug
+  format: json
+`), 0o600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	fs := flag.NewFlagSet("ski", flag.ContinueOnError)
+	fs.String("config", "", "")
+	timeout := fs.Duration("t", 0, "")
+	charset := fs.String("charset", "", "")
+	retry := fs.Int("retry", 0, "")
+	rate := fs.Float64("rate", 0, "")
+	logLevel := fs.String("log-level", "info", "")
+	logFormat := fs.String("log-format", "text", "")
+	logOutput := fs.String("log-output", "stderr", "")
+	idlePerHost := fs.Int("max-idle-conns-per-host", 2, "")
+	tlsTimeout := fs.Duration("tls-handshake-timeout", 10*time.Second, "")
+	var headers stringsFlag
+	fs.Var(&headers, "H", "")
+	if !assert.NoError(t, fs.Parse([]string{"-config", name, "-t", "30s", "-log-format", "text", "-H", "B: 2"})) {
+		return
+	}
+
+	t.Setenv("SKI_CONFIG", "")
+	t.Setenv("SKI_TIMEOUT", "2m")
+	t.Setenv("SKI_FETCH_RETRY", "3")
+	t.Setenv("SKI_LOG_LEVEL", "warn")
+	t.Setenv("SKI_FETCH_MAX_CONNS_PER_HOST", "8")
+	t.Setenv("SKI_FETCH_REDIRECT_SAME_HOST", "true")
+	t.Setenv("SKI_SCHEDULER_MAX_TIME_TO_WAIT_GET_VM", "3s")
+	t.Setenv("SKI_CACHE_BACKEND", "file")
+	t.Setenv("SKI_FETCH_MAX_IDLE_CONNS_PER_HOST", "6")
+
+	conf := defaultConfig()
+	if !assert.NoError(t, readConfig(fs, &conf)) {
+		return
+	}
+	// the flag overrides the environment variable and the config file
+	assert.Equal(t, 30*time.Second, conf.Timeout)
+	assert.Equal(t, 30*time.Second, *timeout)
+	assert.Equal(t, "text", conf.Log.Format)
+	assert.Equal(t, []string{"B: 2"}, conf.Fetch.Headers)
+	// the environment variable overrides the config file
+	assert.Equal(t, 3, conf.Fetch.Retry)
+	assert.Equal(t, 3, *retry)
+	assert.Equal(t, "warn", conf.Log.Level)
+	assert.Equal(t, "warn", *logLevel)
+	assert.Equal(t, 8, conf.Fetch.MaxConnsPerHost)
+	assert.True(t, conf.Fetch.Redirect.SameHost)
+	assert.Equal(t, 3*time.Second, conf.Scheduler.MaxTimeToWaitGetVM)
+	assert.Equal(t, "file", conf.Cache.Backend)
+	assert.Equal(t, 6, *idlePerHost)
+	// the config file overrides the default
+	assert.Equal(t, "gbk", conf.Charset)
+	assert.Equal(t, "gbk", *charset)
+	assert.Equal(t, 1.5, conf.Fetch.Rate)
+	assert.Equal(t, 1.5, *rate)
+	assert.Equal(t, 5*time.Second, *tlsTimeout)
+	// the default of the flag
+	assert.Equal(t, "stderr", conf.Log.Output)
+	assert.Equal(t, "stderr", *logOutput)
+	assert.Equal(t, "text", *logFormat)
+
+	// the config paths are the flags of the CLI
+	for path, name := range configFlags {
+		assert.NotNil(t, flag.Lookup(name), path)
+	}
+
+	t.Setenv("SKI_FETCH_RETRY", "three")
+	assert.ErrorContains(t, readConfig(fs, &conf), "SKI_FETCH_RETRY")
+
+	// the config of the SKI_CONFIG is required
+	t.Setenv("SKI_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, readConfig(flag.NewFlagSet("ski", flag.ContinueOnError), &conf), os.ErrNotExist)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"github.com/shiroyk/ski"
+)
+
+var (
+	linksFlag      = flag.String("links", "", "crawl link-extraction model, the result is the URLs of the page")
+	maxDepthFlag   = flag.Int("max-depth", 0, "crawl max depth of the links, 0 means unlimited")
+	maxPagesFlag   = flag.Int("max-pages", 0, "crawl max pages, 0 means unlimited")
+	workersFlag    = flag.Int("workers", 1, "crawl concurrent requests")
+	delayFlag      = flag.Duration("delay", 0, "crawl min interval between the requests of each host")
+	crawlSameFlag  = flag.Bool("same-host", false, "crawl only the links of the seed hosts")
+	checkpointFlag = flag.String("checkpoint", "", "crawl checkpoint file, the crawl is resumed from it if exists")
+	seenCacheFlag  = flag.String("seen-cache", "", "crawl stores the seen URLs in the directory, such as shared by the crawls")
+)
+
+// runCrawl crawls from the seed URLs of the arguments and the -i list, the -links model
+// extracts the links of each page, the -m model extracts the data. The results are
+// written as JSON Lines to the -o file or stdout, or to the -sink sinks.
+func runCrawl(fetch ski.Fetch) (err error) {
+	if *linksFlag == "" && *modelFlag == "" {
+		return errors.New("crawl requires -links or -m model")
+	}
+	opt := ski.CrawlOptions{
+		MaxDepth:    *maxDepthFlag,
+		MaxPages:    *maxPagesFlag,
+		Concurrency: *workersFlag,
+		Delay:       *delayFlag,
+		SameHost:    *crawlSameFlag,
+		Checkpoint:  *checkpointFlag,
+	}
+	if *linksFlag != "" {
+		if opt.Links, err = compileModel(*linksFlag); err != nil {
+			return err
+		}
+	}
+	if *modelFlag != "" {
+		if opt.Extract, err = compileModel(*modelFlag); err != nil {
+			return err
+		}
+	}
+	if *seenCacheFlag != "" {
+		if opt.Seen, err = ski.NewFileCache(*seenCacheFlag); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *charsetFlag != "" {
+		ctx = ski.WithCharset(ctx, *charsetFlag)
+	}
+	logger := newLogger("crawl")
+	ctx = ski.WithLogger(ctx, logger)
+
+	sink, err := openSinks(ctx, fetch, sinkFlag)
+	if err != nil {
+		return err
+	}
+	if sink != nil {
+		defer func() { err = errors.Join(err, sink.Close()) }()
+	}
+
+	crawler := ski.NewCrawler(fetch, opt)
+	resumed := false
+	if *checkpointFlag != "" {
+		if resumed, err = crawler.Resume(ctx, *checkpointFlag); err != nil {
+			return err
+		}
+	}
+	if !resumed {
+		seeds := slices.Clone(args)
+		if *inputFlag != "" {
+			inputs, err := batchInputs(ctx, fetch, *inputFlag)
+			if err != nil {
+				return err
+			}
+			seeds = append(seeds, inputs...)
+		}
+		if len(seeds) == 0 {
+			return errors.New("crawl requires the seed URLs")
+		}
+		for _, seed := range seeds {
+			if err = crawler.Add(ctx, seed, 0); err != nil {
+				return err
+			}
+		}
+	} else {
+		logger.Info(fmt.Sprintf("crawl resumed from %s with %d URLs", *checkpointFlag, crawler.Len()))
+	}
+
+	var w io.Writer = os.Stdout
+	if *outputFlag != "" {
+		// the resumed crawl appends to the output
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if resumed {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(*outputFlag, flags, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+
+	err = crawler.Run(ctx, func(res ski.CrawlResult) error {
+		if res.Error != "" {
+			logger.Warn("crawl", "url", res.URL, "error", res.Error)
+		}
+		if sink != nil {
+			if err := sink.Write(ctx, res); err != nil || *outputFlag == "" {
+				return err
+			}
+		}
+		return enc.Encode(res)
+	})
+	if *checkpointFlag != "" {
+		switch {
+		case err == nil && crawler.Len() == 0:
+			// the crawl is finished
+			return ski.RemoveCheckpoint(*checkpointFlag)
+		case errors.Is(err, context.Canceled):
+			logger.Info(fmt.Sprintf("crawl interrupted, %d URLs saved to %s", crawler.Len(), *checkpointFlag))
+			return nil
+		}
+	}
+	return err
+}
+
+// compileModel compiles the model file.
+func compileModel(name string) (ski.Executor, error) {
+	source, err := os.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return ski.Compile(string(source))
+}
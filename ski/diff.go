@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/shiroyk/ski"
+)
+
+var diffStateFlag = flag.String("diff-state", "", "diff stores the previous results in the directory (default the user cache directory)")
+
+// diffRecord the changes of the input.
+type diffRecord struct {
+	Input   string       `json:"input"`
+	Changes []ski.Change `json:"changes"`
+}
+
+// runDiff runs the -m model over the -i input, or each input of the -batch list,
+// and writes only the changes against the previous run as JSON Lines
+// to the -o file or stdout, or to the -sink sinks.
+func runDiff(fetch ski.Fetch) (err error) {
+	if *modelFlag == "" {
+		return errors.New("diff requires -m model")
+	}
+	executor, err := compileModel(*modelFlag)
+	if err != nil {
+		return err
+	}
+
+	dir := *diffStateFlag
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(cacheDir, "ski", "diff")
+	}
+	cache, err := ski.NewFileCache(dir)
+	if err != nil {
+		return err
+	}
+	detector := ski.NewChangeDetector(cache)
+
+	inputs := []string{*inputFlag}
+	if *inputFlag != "" {
+		batch := *batchFlag
+		if stat, err := os.Stat(*inputFlag); err == nil && stat.IsDir() {
+			batch = true
+		}
+		if batch {
+			ctx, cancel := modelContext()
+			inputs, err = batchInputs(ctx, fetch, *inputFlag)
+			cancel()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx, cancel := modelContext()
+	sink, err := openSinks(ctx, fetch, sinkFlag)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if sink != nil {
+		defer func() { err = errors.Join(err, sink.Close()) }()
+	}
+	var w io.Writer = os.Stdout
+	if *outputFlag != "" {
+		f, err := os.Create(*outputFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+
+	// the model is the part of the key, the same input of the other models is not compared
+	model, _ := filepath.Abs(*modelFlag)
+	var errs []error
+	for _, input := range inputs {
+		ret, err := execModel(executor, fetch, input)
+		if err == nil {
+			ctx, cancel := modelContext()
+			var changes []ski.Change
+			changes, err = detector.Detect(ctx, model+" "+input, ret)
+			cancel()
+			if err == nil && len(changes) > 0 {
+				record := diffRecord{input, changes}
+				err = emit(sink, record, func() error { return enc.Encode(record) })
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", input, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shiroyk/ski"
+)
+
+// isURL reports whether the input is the HTTP URL.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// readInput reads the input, which is the file path, "-" for stdin, or the URL fetched with the Fetch.
+func readInput(ctx context.Context, fetch ski.Fetch, name string) ([]byte, error) {
+	switch {
+	case name == "-":
+		return io.ReadAll(os.Stdin)
+	case isURL(name):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := fetch.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return nil, fmt.Errorf("fetch %s: %s", name, res.Status)
+		}
+		if contentType := res.Header.Get("Content-Type"); ski.IsTextContent(data, contentType) {
+			return ski.DecodeCharset(data, contentType, ski.CharsetFromContext(ctx))
+		}
+		return data, nil
+	default:
+		return os.ReadFile(name) //nolint:gosec
+	}
+}
+
+// parseInput parses the input content with the -input-format, text returns the string.
+func parseInput(data []byte, format string) (any, error) {
+	switch strings.ToLower(format) {
+	case "", "text":
+		return string(data), nil
+	case "json":
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON input: %w", err)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("invalid -input-format %q", format)
+	}
+}
+
+// batchInputs returns the inputs of the batch mode, which are the files of the directory,
+// or the lines of the list which are the URLs or file paths. The empty lines and
+// lines start with # are ignored.
+func batchInputs(ctx context.Context, fetch ski.Fetch, name string) ([]string, error) {
+	if name != "-" && !isURL(name) {
+		stat, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if stat.IsDir() {
+			entries, err := os.ReadDir(name)
+			if err != nil {
+				return nil, err
+			}
+			inputs := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+					inputs = append(inputs, filepath.Join(name, entry.Name()))
+				}
+			}
+			return inputs, nil
+		}
+	}
+
+	data, err := readInput(ctx, fetch, name)
+	if err != nil {
+		return nil, err
+	}
+	var inputs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			inputs = append(inputs, line)
+		}
+	}
+	return inputs, scanner.Err()
+}
+
+var unsafeFilename = regexp.MustCompile(`[^\w.-]+`)
+
+// batchOutputName returns the unique output file name of the input without the extension,
+// the file name without extension, or the host and path of the URL.
+func batchOutputName(input string, used map[string]bool) string {
+	var name string
+	if isURL(input) {
+		name = strings.TrimPrefix(strings.TrimPrefix(input, "http://"), "https://")
+		name = strings.Trim(unsafeFilename.ReplaceAllString(name, "_"), "_.")
+	} else {
+		name = filepath.Base(input)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == "" {
+		name = "output"
+	}
+	unique := name
+	for i := 1; used[unique]; i++ {
+		unique = name + "_" + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
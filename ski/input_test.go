@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setFlags sets the command line flags, restored when the test finishes.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		require.NotNil(t, f, name)
+		old := f.Value.String()
+		require.NoError(t, f.Value.Set(value))
+		t.Cleanup(func() { _ = f.Value.Set(old) })
+	}
+}
+
+// setStdio replaces the os.Stdin with the content, returns the path of os.Stdout replacement,
+// restored when the test finishes.
+func setStdio(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "stdin")
+	require.NoError(t, os.WriteFile(in, []byte(content), 0o600))
+	stdin, err := os.Open(in)
+	require.NoError(t, err)
+	out := filepath.Join(dir, "stdout")
+	stdout, err := os.Create(out)
+	require.NoError(t, err)
+
+	oldStdin, oldStdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, stdout
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = oldStdin, oldStdout
+		_ = stdin.Close()
+		_ = stdout.Close()
+	})
+	return out
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"input.html": "file"})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte("\xff\xfe\xe9"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=iso-8859-1")
+		_, _ = w.Write([]byte("caf\xe9"))
+	}))
+	t.Cleanup(ts.Close)
+	setStdio(t, "stdin")
+	ctx := context.Background()
+
+	data, err := readInput(ctx, http.DefaultClient, filepath.Join(dir, "input.html"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "file", string(data))
+	}
+	data, err = readInput(ctx, http.DefaultClient, "-")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "stdin", string(data))
+	}
+	data, err = readInput(ctx, http.DefaultClient, ts.URL)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "café", string(data))
+	}
+
+	// the binary input is not transcoded
+	data, err = readInput(ctx, http.DefaultClient, ts.URL+"/binary")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "\xff\xfe\xe9", string(data))
+	}
+
+	_, err = readInput(ctx, http.DefaultClient, ts.URL+"/missing")
+	assert.ErrorContains(t, err, "404 Not Found")
+	_, err = readInput(ctx, http.DefaultClient, filepath.Join(dir, "missing.html"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestBatchInputs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"b.html":    "",
+		"a.html":    "",
+		".hidden":   "",
+		"list.txt":  "# comment\n\nhttps://example.com/a\n  b.html  \n",
+		"empty.txt": "",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	ctx := context.Background()
+
+	inputs, err := batchInputs(ctx, http.DefaultClient, filepath.Join(dir, "list.txt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"https://example.com/a", "b.html"}, inputs)
+	}
+	inputs, err = batchInputs(ctx, http.DefaultClient, filepath.Join(dir, "empty.txt"))
+	if assert.NoError(t, err) {
+		assert.Empty(t, inputs)
+	}
+	inputs, err = batchInputs(ctx, http.DefaultClient, dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			filepath.Join(dir, "a.html"),
+			filepath.Join(dir, "b.html"),
+			filepath.Join(dir, "empty.txt"),
+			filepath.Join(dir, "list.txt"),
+		}, inputs)
+	}
+	_, err = batchInputs(ctx, http.DefaultClient, filepath.Join(dir, "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestBatchOutputName(t *testing.T) {
+	t.Parallel()
+	used := make(map[string]bool)
+	assert.Equal(t, "a", batchOutputName("dir/a.html", used))
+	assert.Equal(t, "a_1", batchOutputName("other/a.txt", used))
+	assert.Equal(t, "a_2", batchOutputName("a", used))
+	assert.Equal(t, "example.com_p_q_1", batchOutputName("https://example.com/p?q=1", used))
+	assert.Equal(t, "output", batchOutputName("https://", used))
+}
+
+func TestRunModelBatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"model.yaml": "$gq: title",
+		"c.html":     "<title>C</title>",
+		"a.html":     "<title>A</title>",
+		"b.html":     "<title>B</title>",
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("<title>URL</title>"))
+	}))
+	t.Cleanup(ts.Close)
+	writeFiles(t, dir, map[string]string{
+		"list.txt": filepath.Join(dir, "c.html") + "\n" + ts.URL + "\nmissing.html\n" + filepath.Join(dir, "a.html") + "\n",
+	})
+	model, list := filepath.Join(dir, "model.yaml"), filepath.Join(dir, "list.txt")
+
+	t.Run("stdout", func(t *testing.T) {
+		stdout := setStdio(t, "")
+		setFlags(t, map[string]string{"m": model, "i": list, "batch": "true", "format": "jsonl"})
+
+		err := runModel(http.DefaultClient)
+		assert.ErrorContains(t, err, "missing.html: ")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		data, err := os.ReadFile(stdout)
+		require.NoError(t, err)
+		// the output keeps the order of the list, the failed input is skipped
+		assert.Equal(t, "\"C\"\n\"URL\"\n\"A\"\n", string(data))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "out")
+		input := filepath.Join(t.TempDir(), "input")
+		require.NoError(t, os.Mkdir(input, 0o755))
+		writeFiles(t, input, map[string]string{"a.html": "<title>A</title>", "b.htm": "<title>B</title>"})
+		setFlags(t, map[string]string{"m": model, "i": input, "o": out, "format": "json"})
+
+		require.NoError(t, runModel(http.DefaultClient))
+		for name, want := range map[string]string{"a.json": "\"A\"\n", "b.json": "\"B\"\n"} {
+			data, err := os.ReadFile(filepath.Join(out, name))
+			if assert.NoError(t, err) {
+				assert.Equal(t, want, string(data))
+			}
+		}
+	})
+
+	t.Run("requires input", func(t *testing.T) {
+		setFlags(t, map[string]string{"m": model, "batch": "true"})
+		assert.EqualError(t, runModel(http.DefaultClient), "-batch requires -i")
+	})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/shiroyk/ski"
+)
+
+var (
+	logLevelFlag  = flag.String("log-level", "info", "log level: debug, info, warn, error")
+	logFormatFlag = flag.String("log-format", "text", "log format: text, json")
+	logOutputFlag = flag.String("log-output", "stderr", "log destination: stderr, stdout, or the file path")
+	logFilterFlag = flag.String("log-filter", "", "comma separated levels of the subsystems, such as fetch=warn,schedule=debug, "+
+		"the subsystems: model, js, fetch, crawl, schedule, serve, the level off disables the subsystem")
+)
+
+// logWriter the destination of the logs, opened by the log config.
+var logWriter io.Writer = os.Stderr
+
+// logFile the log file of the -log-output, closed when the logger is initialized again.
+var logFile *os.File
+
+// levelOff disables the logs.
+const levelOff = slog.Level(math.MaxInt32)
+
+// initLogger opens the log destination, parses the log filter,
+// and sets the slog default logger.
+func initLogger() (err error) {
+	if _, err = parseLevel(conf.Log.Level); err != nil {
+		return err
+	}
+	if f := conf.Log.Format; f != "text" && f != "json" {
+		return fmt.Errorf("invalid log format %q", f)
+	}
+	if _, err = parseLogFilter(conf.Log.Filter); err != nil {
+		return err
+	}
+	var (
+		writer io.Writer = os.Stderr
+		file   *os.File
+	)
+	switch conf.Log.Output {
+	case "", "stderr":
+	case "stdout":
+		writer = os.Stdout
+	default:
+		if file, err = os.OpenFile(conf.Log.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil { //nolint:gosec
+			return err
+		}
+		writer = file
+	}
+	prev := logFile
+	logWriter, logFile = writer, file
+	slog.SetDefault(slog.New(loggerHandler()))
+	// the previous log file is closed after the default logger is replaced
+	if prev != nil {
+		_ = prev.Close()
+	}
+	return nil
+}
+
+// newLogger returns the logger of the subsystem.
+func newLogger(subsystem string) *slog.Logger {
+	return slog.New(loggerHandler()).With(subsystemKey, subsystem)
+}
+
+// loggerHandler returns the handler of the log config.
+func loggerHandler() slog.Handler {
+	level, _ := parseLevel(conf.Log.Level)
+	levels, _ := parseLogFilter(conf.Log.Filter)
+	minLevel := level
+	for _, l := range levels {
+		minLevel = min(minLevel, l)
+	}
+	opt := &slog.HandlerOptions{Level: minLevel}
+	var handler slog.Handler
+	if conf.Log.Format == "json" {
+		handler = slog.NewJSONHandler(logWriter, opt)
+	} else {
+		handler = slog.NewTextHandler(logWriter, opt)
+	}
+	return &filterHandler{handler, level, levels, ""}
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	if strings.EqualFold(s, "off") {
+		return levelOff, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", s)
+	}
+	return level, nil
+}
+
+// parseLogFilter parses the filter "subsystem=level,...".
+func parseLogFilter(filter string) (map[string]slog.Level, error) {
+	levels := make(map[string]slog.Level)
+	for _, item := range strings.Split(filter, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		subsystem, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid log filter %q, requires subsystem=level", item)
+		}
+		l, err := parseLevel(strings.TrimSpace(level))
+		if err != nil {
+			return nil, err
+		}
+		levels[strings.TrimSpace(subsystem)] = l
+	}
+	return levels, nil
+}
+
+const subsystemKey = ski.LogSubsystemKey
+
+// filterHandler filters the records by the level of the subsystem, the subsystem is
+// the subsystem attribute of the record, such as the fetch logs of the library,
+// or the subsystem attribute of the logger.
+type filterHandler struct {
+	slog.Handler
+	level     slog.Level
+	levels    map[string]slog.Level
+	subsystem string
+}
+
+func (h *filterHandler) Handle(ctx context.Context, r slog.Record) error {
+	subsystem, recorded := h.subsystem, false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == subsystemKey {
+			subsystem, recorded = a.Value.String(), true
+			return false
+		}
+		return true
+	})
+	level, ok := h.levels[subsystem]
+	if !ok {
+		level = h.level
+	}
+	if r.Level < level {
+		return nil
+	}
+	if subsystem != "" && !recorded {
+		r = r.Clone()
+		r.AddAttrs(slog.String(subsystemKey, subsystem))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the subsystem attribute, which is added by the Handle.
+func (h *filterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	others := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		if attr.Key == subsystemKey {
+			c.subsystem = attr.Value.String()
+		} else {
+			others = append(others, attr)
+		}
+	}
+	c.Handler = h.Handler.WithAttrs(others)
+	return &c
+}
+
+func (h *filterHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.Handler = h.Handler.WithGroup(name)
+	return &c
+}
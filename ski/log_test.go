@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogFilter(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct {
+		filter string
+		want   map[string]slog.Level
+	}{
+		{"", map[string]slog.Level{}},
+		{" , ", map[string]slog.Level{}},
+		{"fetch=warn", map[string]slog.Level{"fetch": slog.LevelWarn}},
+		{" fetch = DEBUG , js=off,schedule=error ", map[string]slog.Level{
+			"fetch": slog.LevelDebug, "js": levelOff, "schedule": slog.LevelError,
+		}},
+		{"fetch=info+2", map[string]slog.Level{"fetch": slog.LevelInfo + 2}},
+	} {
+		levels, err := parseLogFilter(c.filter)
+		if assert.NoError(t, err, c.filter) {
+			assert.Equal(t, c.want, levels, c.filter)
+		}
+	}
+	for _, filter := range []string{"fetch", "fetch=verbose", "fetch=warn,js"} {
+		_, err := parseLogFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
+
+func TestFilterHandler(t *testing.T) {
+	t.Parallel()
+	buf := new(bytes.Buffer)
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	levels := map[string]slog.Level{"fetch": slog.LevelDebug, "js": levelOff}
+	logger := slog.New(&filterHandler{handler, slog.LevelInfo, levels, ""})
+
+	logger.Debug("dropped by the default level")
+	logger.Info("kept")
+	logger.Debug("fetch", "status", 200, subsystemKey, "fetch")
+	logger.Debug("fetch without the subsystem is dropped by the default level")
+	logger.Error("vm run error", subsystemKey, "js")
+	schedule := logger.With(subsystemKey, "schedule", "job", "hn")
+	schedule.Debug("dropped by the default level")
+	schedule.Info("scheduled")
+	// the subsystem of the record overrides the logger
+	schedule.WithGroup("g").Info("fetch", "status", 404, subsystemKey, "fetch")
+	logger.With(subsystemKey, "js").Warn("dropped by off")
+
+	assert.Equal(t, `level=INFO msg=kept
+level=DEBUG msg=fetch status=200 subsystem=fetch
+level=INFO msg=scheduled job=hn subsystem=schedule
+level=INFO msg=fetch job=hn g.status=404 g.subsystem=fetch
+`, buf.String())
+}
+
+// recordHandler records the messages and the subsystems of the records.
+type recordHandler struct {
+	mu         *sync.Mutex
+	subsystems map[string]string
+}
+
+func (h recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h recordHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h recordHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == subsystemKey {
+			h.subsystems[r.Message] = a.Value.String()
+			return false
+		}
+		return true
+	})
+	if _, ok := h.subsystems[r.Message]; !ok {
+		h.subsystems[r.Message] = ""
+	}
+	return nil
+}
+
+// failedCache the Cache fails to store.
+type failedCache struct{}
+
+func (failedCache) Get(context.Context, string) ([]byte, error) { return nil, nil }
+func (failedCache) Set(context.Context, string, []byte) error   { return errors.New("full") }
+func (failedCache) Del(context.Context, string) error           { return nil }
+
+// TestLibrarySubsystem logs the messages of the library, which have the subsystem attribute.
+func TestLibrarySubsystem(t *testing.T) {
+	t.Parallel()
+	rec := recordHandler{new(sync.Mutex), make(map[string]string)}
+	logger := slog.New(&filterHandler{rec, slog.LevelDebug, nil, ""})
+	ctx := ski.WithLogger(context.Background(), logger)
+
+	respond := func(status int, body string) ski.Fetch {
+		return ski.FetchFunc(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{"Cache-Control": {"max-age=60"}}
+			return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		})
+	}
+	do := func(fetch ski.Fetch) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+		if res, err := fetch.Do(req); err == nil {
+			_ = res.Body.Close()
+		}
+	}
+
+	do(ski.LogMiddleware(slog.LevelDebug)(respond(http.StatusOK, "")))
+	do(ski.LogMiddleware(slog.LevelDebug)(ski.FetchFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("failed")
+	})))
+	do(ski.RetryMiddleware(ski.RetryOptions{MaxAttempts: 2, MinBackoff: time.Millisecond})(respond(http.StatusServiceUnavailable, "")))
+	do(ski.NewCacheFetch(respond(http.StatusOK, ""), failedCache{}, ski.CacheOptions{}))
+	do(ski.OAuth2Middleware(ski.OAuth2Options{TokenURL: "http://example.com/token", Cache: failedCache{}})(
+		respond(http.StatusOK, `{"access_token":"token","expires_in":3600}`)))
+	js.NewVM().Run(ctx, func() { panic("failed") })
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for prefix, subsystem := range map[string]string{
+		"fetch":         "fetch",
+		"fetch failed":  "fetch",
+		"retry request": "fetch",
+		"http cache":    "fetch",
+		"oauth2":        "fetch",
+		"vm run error":  "js",
+	} {
+		found := false
+		for msg, s := range rec.subsystems {
+			if strings.HasPrefix(msg, prefix) {
+				found = true
+				assert.Equal(t, subsystem, s, msg)
+			}
+		}
+		assert.True(t, found, "no library message of the prefix %q", prefix)
+	}
+	for msg, subsystem := range rec.subsystems {
+		assert.NotEmpty(t, subsystem, "the library message %q has no subsystem", msg)
+	}
+}
+
+// the test can not be parallel, it modifies the log config.
+func TestInitLogger(t *testing.T) {
+	prev := conf.Log
+	t.Cleanup(func() {
+		conf.Log = prev
+		assert.NoError(t, initLogger())
+	})
+
+	dir := t.TempDir()
+	conf.Log = logConfig{Level: "info", Format: "json", Output: filepath.Join(dir, "1.log")}
+	if !assert.NoError(t, initLogger()) {
+		return
+	}
+	first := logFile
+	slog.Info("first")
+
+	conf.Log.Output = filepath.Join(dir, "2.log")
+	if !assert.NoError(t, initLogger()) {
+		return
+	}
+	slog.Info("second")
+	// the previous log file is closed
+	_, err := first.WriteString("closed")
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	data, _ := os.ReadFile(filepath.Join(dir, "1.log"))
+	assert.Contains(t, string(data), `"msg":"first"`)
+	data, _ = os.ReadFile(filepath.Join(dir, "2.log"))
+	assert.Contains(t, string(data), `"msg":"second"`)
+
+	// the log file is kept if the new destination fails
+	conf.Log.Output = filepath.Join(dir, "missing", "3.log")
+	assert.Error(t, initLogger())
+	_, err = logFile.WriteString("")
+	assert.NoError(t, err)
+
+	conf.Log.Output = "stderr"
+	assert.NoError(t, initLogger())
+	assert.Nil(t, logFile)
+	assert.Equal(t, io.Writer(os.Stderr), logWriter)
+}
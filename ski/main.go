@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -10,16 +10,17 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/shiroyk/ski"
 	"github.com/shiroyk/ski/js"
+	jshttp "github.com/shiroyk/ski/js/modules/http"
 
 	_ "github.com/shiroyk/ski/js/modules/cache"
 	_ "github.com/shiroyk/ski/js/modules/crypto"
 	_ "github.com/shiroyk/ski/js/modules/encoding"
-	_ "github.com/shiroyk/ski/js/modules/http"
 
 	_ "github.com/shiroyk/ski/gq"
 	_ "github.com/shiroyk/ski/jq"
@@ -34,21 +35,110 @@ var (
 	modelFlag   = flag.String("m", "", "run model")
 	timeoutFlag = flag.Duration("t", defaultTimeout, "run timeout")
 	outputFlag  = flag.String("o", "", "write to file instead of stdout")
+	inputFlag   = flag.String("i", "", "model input file, - for stdin, or URL")
+	inputFmt    = flag.String("input-format", "text", "model input format: text, json")
+	batchFlag   = flag.Bool("batch", false, "run the model over each line (URL or file) of the -i list, -i directory runs over each file, -o is the output directory")
+	formatFlag  = flag.String("format", "", "output format: json, jsonl, csv, tsv, yaml, xml (default from the -o extension, or json)")
 	versionFlag = flag.Bool("v", false, "output version")
+	charsetFlag = flag.String("charset", "", "charset of the response body, such as gbk, shift_jis, detected if empty")
+
+	caFileFlag          = flag.String("cacert", "", "trust the PEM encoded CA certificates file in addition to the system pool")
+	certFileFlag        = flag.String("cert", "", "client certificate file for mTLS (PEM)")
+	keyFileFlag         = flag.String("key", "", "client private key file for mTLS (PEM)")
+	tlsMinFlag          = flag.String("tls-min", "", "minimum TLS version: 1.0, 1.1, 1.2, 1.3 (default 1.2)")
+	insecureFlag        = flag.Bool("insecure", false, "skip the server certificate verification")
+	http1Flag           = flag.Bool("http1", false, "disable HTTP/2")
+	maxConnsPerHostFlag = flag.Int("max-conns-per-host", 0, "max connections of each host, 0 means unlimited")
+	maxIdleConnsFlag    = flag.Int("max-idle-conns", 100, "max idle connections of all hosts")
+	connectTimeoutFlag  = flag.Duration("connect-timeout", 30*time.Second, "timeout of the connection established")
+
+	maxIdleConnsPerHostFlag   = flag.Int("max-idle-conns-per-host", 2, "max idle connections of each host")
+	tlsHandshakeTimeoutFlag   = flag.Duration("tls-handshake-timeout", 10*time.Second, "timeout of the TLS handshake")
+	responseHeaderTimeoutFlag = flag.Duration("response-header-timeout", 0, "timeout of waiting the response headers, 0 means no timeout")
+	idleConnTimeoutFlag       = flag.Duration("idle-conn-timeout", 90*time.Second, "timeout of the idle connection closed")
+
+	maxRedirectsFlag   = flag.Int("max-redirects", ski.DefaultMaxRedirects, "max redirects to follow, 0 means not follow")
+	sameHostFlag       = flag.Bool("same-host-redirect", false, "only follow the redirects to the same host")
+	requestTimeoutFlag = flag.Duration("request-timeout", 0, "timeout of each HTTP request including reading the body, 0 means no timeout")
+
+	loadCookieFlag = flag.String("load-cookie", "", "load cookies from file (Netscape cookies.txt or .json)")
+	saveCookieFlag = flag.String("save-cookie", "", "save cookies to file after run (Netscape cookies.txt or .json)")
+
+	retryFlag           = flag.Int("retry", 1, "max attempts of the HTTP request, 1 means no retry")
+	retryBackoffFlag    = flag.Duration("retry-backoff", ski.DefaultRetryMinBackoff, "backoff of the first retry, doubled on each retry")
+	retryMaxBackoffFlag = flag.Duration("retry-max-backoff", ski.DefaultRetryMaxBackoff, "max backoff between retries")
+
+	rateFlag        = flag.Float64("rate", 0, "max requests per second of each host, 0 means unlimited")
+	concurrencyFlag = flag.Int("concurrency", 0, "max concurrent requests of each host, 0 means unlimited")
+	robotsFlag      = flag.Bool("robots", false, "honour the robots.txt Crawl-delay of each host")
+
+	httpCacheFlag = flag.String("http-cache", "", "cache the HTTP responses in the directory")
+	offlineFlag   = flag.Bool("offline", false, "serve the HTTP responses only from the -http-cache, never send requests")
+
+	proxyFileFlag     = flag.String("proxy-file", "", "read the proxies from the file, one proxy per line")
+	proxyStrategyFlag = flag.String("proxy-strategy", string(ski.ProxyRoundRobin), "proxy selection strategy: round-robin, random, sticky (same proxy for the same host)")
+
+	recordHARFlag = flag.String("record-har", "", "record the HTTP requests and responses to the HAR file")
+	replayHARFlag = flag.String("replay-har", "", "serve the HTTP responses from the HAR file, never send requests")
+	harMatchFlag  = flag.String("har-match", "method,url", "match the replayed requests by the comma separated method, url, path (url without query), body")
+
+	harUnredactedFlag = flag.Bool("har-unredacted", false, "record the credentials headers and cookies to the HAR file in plain text")
+
+	userFlag               = flag.String("u", "", "HTTP Basic authentication \"user:password\"")
+	digestFlag             = flag.Bool("digest", false, "use the HTTP Digest authentication with the -u credentials")
+	bearerFlag             = flag.String("bearer", "", "HTTP Bearer authentication token")
+	oauth2TokenURLFlag     = flag.String("oauth2-token-url", "", "OAuth2 client credentials token endpoint")
+	oauth2ClientIDFlag     = flag.String("oauth2-client-id", "", "OAuth2 client id")
+	oauth2ClientSecretFlag = flag.String("oauth2-client-secret", "", "OAuth2 client secret")
+	oauth2ScopeFlag        = flag.String("oauth2-scope", "", "OAuth2 space separated scopes")
+
+	headerFlag    stringsFlag
+	userAgentFlag stringsFlag
+	proxyFlag     stringsFlag
+	authHostFlag  stringsFlag
 )
 
-type _fetch string
+func init() {
+	flag.Var(&headerFlag, "H", "default request header \"Name: value\", can be repeated")
+	flag.Var(&proxyFlag, "x", "request proxy (http, https, socks5), can be repeated to rotate with -proxy-strategy")
+	flag.Var(&userAgentFlag, "A", "request user-agent, can be repeated to rotate in round-robin order")
+	flag.Var(&authHostFlag, "auth-host", "only send the credentials to the host and its subdomains, can be repeated")
+}
+
+// stringsFlag the flag can be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ", ") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+type _fetch struct {
+	fetch    ski.Fetch
+	str      string
+	response bool
+}
+
+func new_fetch(fetch ski.Fetch) ski.NewExecutor {
+	return ski.StringExecutor(func(str string) (ski.Executor, error) {
+		return _fetch{fetch: fetch, str: str}, nil
+	})
+}
 
-func new_fetch() ski.NewExecutor {
+// new_fetch_response the executor returns the response object contains
+// url, status, redirected, redirects, headers and body.
+func new_fetch_response(fetch ski.Fetch) ski.NewExecutor {
 	return ski.StringExecutor(func(str string) (ski.Executor, error) {
-		return _fetch(str), nil
+		return _fetch{fetch: fetch, str: str, response: true}, nil
 	})
 }
 
 func (f _fetch) Exec(ctx context.Context, _ any) (any, error) {
-	method, url, found := strings.Cut(string(f), " ")
+	method, url, found := strings.Cut(f.str, " ")
 	if !found {
-		url = string(f)
+		url = f.str
 		method = http.MethodGet
 	}
 
@@ -56,9 +146,8 @@ func (f _fetch) Exec(ctx context.Context, _ any) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", "ski")
 
-	res, err := http.DefaultClient.Do(req)
+	res, err := f.fetch.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -68,12 +157,42 @@ func (f _fetch) Exec(ctx context.Context, _ any) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	return string(data), nil
+	// the binary body is returned as is
+	if contentType := res.Header.Get("Content-Type"); ski.IsTextContent(data, contentType) {
+		data, err = ski.DecodeCharset(data, contentType, ski.CharsetFromContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !f.response {
+		return string(data), nil
+	}
+
+	chain := ski.RedirectChain(res)
+	redirects := make([]any, 0, len(chain))
+	for _, u := range chain {
+		redirects = append(redirects, u.String())
+	}
+	headers := make(map[string]any, len(res.Header))
+	for k, v := range res.Header {
+		headers[k] = strings.Join(v, ", ")
+	}
+	return map[string]any{
+		"url":        res.Request.URL.String(),
+		"status":     res.StatusCode,
+		"redirected": len(chain) > 1,
+		"redirects":  redirects,
+		"headers":    headers,
+		"body":       string(data),
+	}, nil
 }
 
-func runModel() (err error) {
+func runModel(fetch ski.Fetch) (err error) {
 	var bytes []byte
 	if *modelFlag == "-" {
+		if *inputFlag == "-" {
+			return errors.New("-m and -i can not both read from stdin")
+		}
 		bytes, err = io.ReadAll(os.Stdin)
 	} else {
 		bytes, err = os.ReadFile(*modelFlag) //nolint:gosec
@@ -81,29 +200,110 @@ func runModel() (err error) {
 	if err != nil {
 		return
 	}
-	fmt.Println(string(bytes))
-
-	ski.Register("fetch", new_fetch())
 
 	executor, err := ski.Compile(string(bytes))
 	if err != nil {
 		return err
 	}
 
+	sink, err := openSinks(context.Background(), fetch, sinkFlag)
+	if err != nil {
+		return err
+	}
+	if sink != nil {
+		defer func() { err = errors.Join(err, sink.Close()) }()
+	}
+
+	if *inputFlag == "" {
+		if *batchFlag {
+			return errors.New("-batch requires -i")
+		}
+		ret, err := execModel(executor, fetch, "")
+		if err != nil {
+			return err
+		}
+		return emit(sink, ret, func() error { return output(ret) })
+	}
+
+	batch := *batchFlag
+	if stat, err := os.Stat(*inputFlag); err == nil && stat.IsDir() {
+		batch = true
+	}
+	if !batch {
+		ret, err := execModel(executor, fetch, *inputFlag)
+		if err != nil {
+			return err
+		}
+		return emit(sink, ret, func() error { return output(ret) })
+	}
+
+	ctx, cancel := modelContext()
+	inputs, err := batchInputs(ctx, fetch, *inputFlag)
+	cancel()
+	if err != nil {
+		return err
+	}
+	format, err := outputFormat(*formatFlag, "")
+	if err != nil {
+		return err
+	}
+	if *outputFlag != "" {
+		if err = os.MkdirAll(*outputFlag, 0o755); err != nil {
+			return err
+		}
+	}
+
+	// the failed input does not stop the batch
+	var errs []error
+	used := make(map[string]bool)
+	for _, input := range inputs {
+		name := batchOutputName(input, used)
+		ret, err := execModel(executor, fetch, input)
+		if err == nil {
+			err = emit(sink, ret, func() error {
+				if *outputFlag == "" {
+					return writeOutput(os.Stdout, ret, format)
+				}
+				return outputFile(filepath.Join(*outputFlag, name+"."+format), ret, format)
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", input, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// modelContext returns the context of the model run with the -t timeout.
+func modelContext() (context.Context, context.CancelFunc) {
 	timeout := defaultTimeout
 	if timeoutFlag != nil {
 		timeout = *timeoutFlag
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if *charsetFlag != "" {
+		ctx = ski.WithCharset(ctx, *charsetFlag)
+	}
+	return ski.WithLogger(ctx, newLogger("model")), cancel
+}
+
+// execModel executes the model with the input, the empty input is nil.
+func execModel(executor ski.Executor, fetch ski.Fetch, input string) (any, error) {
+	ctx, cancel := modelContext()
 	defer cancel()
 
-	ret, err := executor.Exec(ski.WithLogger(ctx, slog.New(loggerHandler())), nil)
-	if err != nil {
-		return err
+	var arg any
+	if input != "" {
+		data, err := readInput(ctx, fetch, input)
+		if err != nil {
+			return nil, err
+		}
+		if arg, err = parseInput(data, *inputFmt); err != nil {
+			return nil, err
+		}
 	}
-
-	return outputJSON(ret)
+	return executor.Exec(ctx, arg)
 }
 
 func runScript() (err error) {
@@ -124,6 +324,9 @@ func runScript() (err error) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
+	if *charsetFlag != "" {
+		ctx = ski.WithCharset(ctx, *charsetFlag)
+	}
 	ctx = ski.NewContext(ctx, nil)
 
 	vm, err := js.GetScheduler().Get()
@@ -135,7 +338,7 @@ func runScript() (err error) {
 		return err
 	}
 
-	ret, err := vm.RunModule(ski.WithLogger(ctx, slog.New(loggerHandler())), module)
+	ret, err := vm.RunModule(ski.WithLogger(ctx, newLogger("js")), module)
 	if err != nil {
 		return err
 	}
@@ -145,33 +348,303 @@ func runScript() (err error) {
 		return err
 	}
 
-	return outputJSON(v)
+	return output(v)
 }
 
-func loggerHandler() slog.Handler {
-	return slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
-}
+// initFetch creates the shared Fetch and CookieJar, registers them
+// to the fetch executor and the JS http modules, returns the Fetch.
+// The returned done function saves the cookies and HAR after the run.
+func initFetch() (fetch ski.Fetch, done func() error, err error) {
+	jar := ski.NewEnumerableCookieJar()
+	if *loadCookieFlag != "" {
+		if err := ski.LoadCookies(jar, *loadCookieFlag); err != nil {
+			return nil, nil, err
+		}
+	}
 
-func outputJSON(data any) (err error) {
-	bytes, err := json.MarshalIndent(data, "", "\t")
+	fetchOptions := ski.FetchOptions{
+		CAFile:                *caFileFlag,
+		CertFile:              *certFileFlag,
+		KeyFile:               *keyFileFlag,
+		MinTLSVersion:         *tlsMinFlag,
+		InsecureSkipVerify:    *insecureFlag,
+		DisableHTTP2:          *http1Flag,
+		MaxIdleConns:          *maxIdleConnsFlag,
+		MaxIdleConnsPerHost:   *maxIdleConnsPerHostFlag,
+		MaxConnsPerHost:       *maxConnsPerHostFlag,
+		DialTimeout:           *connectTimeoutFlag,
+		TLSHandshakeTimeout:   *tlsHandshakeTimeoutFlag,
+		ResponseHeaderTimeout: *responseHeaderTimeoutFlag,
+		IdleConnTimeout:       *idleConnTimeoutFlag,
+		Timeout:               *requestTimeoutFlag,
+		Redirect: ski.RedirectOptions{
+			Disable:      *maxRedirectsFlag <= 0 || conf.Fetch.Redirect.Disable,
+			MaxRedirects: *maxRedirectsFlag,
+			SameHost:     *sameHostFlag,
+		},
+	}
+	client, err := ski.NewFetchWithOptions(fetchOptions)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if c, ok := client.(*http.Client); ok {
+		c.Jar = jar
 	}
 
-	if *outputFlag == "" {
-		fmt.Println(string(bytes)) //nolint:forbidigo
+	var base ski.Fetch = client
+	if *replayHARFlag != "" {
+		har, err := ski.LoadHAR(*replayHARFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		opt, err := harMatch(*harMatchFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		base = ski.NewHARReplay(har, opt)
+	}
+	var recorder *ski.HARRecorder
+	if *recordHARFlag != "" {
+		recorder = ski.NewHARRecorder(base, ski.HARRecorderOptions{Unredacted: *harUnredactedFlag})
+		base = recorder
+	}
+
+	header := make(http.Header)
+	for _, h := range headerFlag {
+		name, value, found := strings.Cut(h, ":")
+		if !found {
+			return nil, nil, fmt.Errorf("invalid header %q", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if header.Get("User-Agent") == "" && len(userAgentFlag) == 0 {
+		header.Set("User-Agent", "ski")
+	}
+
+	mws := []ski.FetchMiddleware{
+		ski.HeaderMiddleware(header),
+		ski.UserAgentMiddleware(userAgentFlag...),
+	}
+	var cache ski.Cache
+	if *httpCacheFlag != "" {
+		if cache, err = ski.NewFileCache(*httpCacheFlag); err != nil {
+			return nil, nil, err
+		}
+	}
+	if auth, err := authMiddleware(cache); err != nil {
+		return nil, nil, err
+	} else if auth != nil {
+		mws = append(mws, ski.HostMiddleware(auth, authHostFlag...))
+	}
+	if cache != nil {
+		mws = append(mws, ski.CacheMiddleware(cache, ski.CacheOptions{Offline: *offlineFlag}))
+	} else if *offlineFlag {
+		return nil, nil, errors.New("-offline requires -http-cache")
+	}
+	mws = append(mws, ski.RetryMiddleware(ski.RetryOptions{
+		MaxAttempts: max(*retryFlag, 1),
+		MinBackoff:  *retryBackoffFlag,
+		MaxBackoff:  *retryMaxBackoffFlag,
+	}))
+	if *rateFlag > 0 || *concurrencyFlag > 0 || *robotsFlag {
+		mws = append(mws, ski.RateLimitMiddleware(ski.RateLimitOptions{
+			RequestsPerSecond: *rateFlag,
+			MaxConcurrency:    *concurrencyFlag,
+			Robots:            *robotsFlag,
+			UserAgent:         "ski",
+		}))
+	}
+	if pool, err := proxyPool(); err != nil {
+		return nil, nil, err
+	} else if pool != nil {
+		mws = append(mws, ski.ProxyMiddleware(pool))
+		jshttp.RegisterProxyPool(pool)
+	}
+	mws = append(mws, ski.LogMiddleware(slog.LevelDebug))
+
+	fetch = ski.ChainFetch(base, mws...)
+
+	ski.Register("fetch", new_fetch(fetch))
+	ski.Register("fetch.response", new_fetch_response(fetch))
+	jshttp.Register(fetch, jar)
+
+	return fetch, func() (err error) {
+		if *saveCookieFlag != "" {
+			err = ski.SaveCookies(jar, *saveCookieFlag)
+		}
+		if recorder != nil {
+			err = errors.Join(err, recorder.HAR().Save(*recordHARFlag))
+		}
 		return
+	}, nil
+}
+
+// authMiddleware returns the auth FetchMiddleware of the flags, nil if no auth.
+// The OAuth2 token is stored in the cache if not nil, such as shared by the runs.
+func authMiddleware(cache ski.Cache) (ski.FetchMiddleware, error) {
+	var mws []ski.FetchMiddleware
+	if *userFlag != "" {
+		user, password, _ := strings.Cut(*userFlag, ":")
+		if *digestFlag {
+			mws = append(mws, ski.DigestAuthMiddleware(user, password))
+		} else {
+			mws = append(mws, ski.BasicAuthMiddleware(user, password))
+		}
+	} else if *digestFlag {
+		return nil, errors.New("-digest requires -u")
+	}
+	if *bearerFlag != "" {
+		mws = append(mws, ski.BearerAuthMiddleware(*bearerFlag))
+	}
+	if *oauth2TokenURLFlag != "" {
+		mws = append(mws, ski.OAuth2Middleware(ski.OAuth2Options{
+			TokenURL:     *oauth2TokenURLFlag,
+			ClientID:     *oauth2ClientIDFlag,
+			ClientSecret: *oauth2ClientSecretFlag,
+			Scopes:       strings.Fields(*oauth2ScopeFlag),
+			Cache:        cache,
+		}))
+	}
+	switch len(mws) {
+	case 0:
+		return nil, nil
+	case 1:
+		return mws[0], nil
+	default:
+		return nil, errors.New("only one of -u, -bearer, -oauth2-token-url can be used")
 	}
+}
 
-	ext := filepath.Ext(*outputFlag)
-	if ext == "" {
-		*outputFlag += ".json"
+// proxyPool returns the ProxyPool of the -x and -proxy-file flags, nil if no proxy.
+func proxyPool() (*ski.ProxyPool, error) {
+	proxies := slices.Clone(proxyFlag)
+	if *proxyFileFlag != "" {
+		data, err := os.ReadFile(*proxyFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				proxies = append(proxies, line)
+			}
+		}
+	}
+	if len(proxies) == 0 {
+		return nil, nil
 	}
-	return os.WriteFile(*outputFlag, bytes, 0o600)
+	return ski.NewProxyPool(proxies, ski.ProxyPoolOptions{Strategy: ski.ProxyStrategy(*proxyStrategyFlag)})
+}
+
+// harMatch parses the -har-match flag to the HARReplayOptions.
+func harMatch(match string) (opt ski.HARReplayOptions, err error) {
+	opt.IgnoreMethod = true
+	var url, path bool
+	for _, m := range strings.Split(match, ",") {
+		switch strings.TrimSpace(m) {
+		case "method":
+			opt.IgnoreMethod = false
+		case "url":
+			url = true
+		case "path":
+			// the url without the query
+			path = true
+		case "body":
+			opt.MatchBody = true
+		default:
+			return opt, fmt.Errorf("invalid -har-match %q", m)
+		}
+	}
+	if url && path {
+		return opt, fmt.Errorf("invalid -har-match %q, url and path are exclusive", match)
+	}
+	// the path is matched always, the query is matched only by the url
+	opt.IgnoreQuery = !url
+	return
+}
+
+// args the positional arguments.
+var args []string
+
+// parseFlags parses the flags interspersed with the positional arguments,
+// such as "ski schedule jobs.yaml -t 10s", returns the positional arguments.
+func parseFlags(arguments []string) (positional []string) {
+	for {
+		_ = flag.CommandLine.Parse(arguments)
+		rest := flag.Args()
+		if len(rest) == 0 {
+			return
+		}
+		// the arguments after the terminator "--" are positional
+		if i := len(arguments) - len(rest) - 1; i >= 0 && arguments[i] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		arguments = rest[1:]
+	}
+}
+
+// commands the subcommands, such as "ski repl -i page.html".
+var commands = map[string]func(fetch ski.Fetch) error{
+	"repl":     runRepl,
+	"serve":    runServe,
+	"crawl":    runCrawl,
+	"schedule": runSchedule,
+	"diff":     runDiff,
+	"config":   runConfig,
+}
+
+func run(command func(fetch ski.Fetch) error) error {
+	if _, err := outputFormat(*formatFlag, *outputFlag); err != nil {
+		return err
+	}
+
+	fetch, done, err := initFetch()
+	if err != nil {
+		return err
+	}
+	if err = initScheduler(fetch); err != nil {
+		return errors.Join(err, done())
+	}
+
+	return errors.Join(command(fetch), done())
+}
+
+// runMain runs the -s script or -m model.
+func runMain(fetch ski.Fetch) error {
+	if *scriptFlag != "" {
+		return runScript()
+	}
+	return runModel(fetch)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintln(out, "Usage: ski [command] [flags]")
+	_, _ = fmt.Fprintln(out, "\nCommands:")
+	_, _ = fmt.Fprintln(out, "  repl\tinteractive model development with the -i input")
+	_, _ = fmt.Fprintln(out, "  serve\tserve the -models and -scripts directories as the HTTP API on -addr")
+	_, _ = fmt.Fprintln(out, "  crawl\tcrawl from the seed URLs with the -links and -m models")
+	_, _ = fmt.Fprintln(out, "  schedule\trun the jobs of the config file by the cron expression or interval")
+	_, _ = fmt.Fprintln(out, "  diff\trun the -m model over the -i input and output only the changes against the previous run")
+	_, _ = fmt.Fprintln(out, "  config print\tprint the effective configuration of the -config file, SKI_ environment variables and flags")
+	_, _ = fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
 }
 
 func main() {
-	flag.Parse()
+	flag.Usage = usage
+	var command func(fetch ski.Fetch) error
+	if len(os.Args) > 1 {
+		if command = commands[os.Args[1]]; command != nil {
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+	args = parseFlags(os.Args[1:])
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
 
 	if *versionFlag {
 		fmt.Println(fmt.Sprintf("ski %v/%v", Version, CommitSHA))
@@ -179,17 +652,16 @@ func main() {
 		return
 	}
 
-	if scriptFlag != nil && *scriptFlag != "" {
-		if err := runScript(); err != nil {
-			fmt.Println(err.Error())
-			os.Exit(1)
-		}
-	} else if modelFlag != nil && *modelFlag != "" {
-		if err := runModel(); err != nil {
-			fmt.Println(err.Error())
-			os.Exit(1)
+	if command == nil {
+		if *scriptFlag == "" && *modelFlag == "" {
+			flag.Usage()
+			return
 		}
-	} else {
-		flag.Usage()
+		command = runMain
+	}
+
+	if err := run(command); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchExecutor(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+		default:
+			w.Header().Set("Content-Type", "image/png")
+		}
+		w.Header().Set("X-Method", r.Method)
+		_, _ = w.Write([]byte("caf\xe9"))
+	}))
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	exec := func(executor _fetch) any {
+		ret, err := executor.Exec(ctx, nil)
+		assert.NoError(t, err)
+		return ret
+	}
+	assert.Equal(t, "café", exec(_fetch{fetch: http.DefaultClient, str: ts.URL + "/text"}))
+	// the invalid UTF-8 JSON without the charset is decoded as windows-1252
+	assert.Equal(t, "café", exec(_fetch{fetch: http.DefaultClient, str: ts.URL + "/json"}))
+	// the binary body is not transcoded
+	assert.Equal(t, "caf\xe9", exec(_fetch{fetch: http.DefaultClient, str: ts.URL + "/png"}))
+
+	ret := exec(_fetch{fetch: http.DefaultClient, str: "POST " + ts.URL + "/text", response: true})
+	if res, ok := ret.(map[string]any); assert.True(t, ok) {
+		assert.Equal(t, http.StatusOK, res["status"])
+		assert.Equal(t, "café", res["body"])
+		assert.Equal(t, "POST", res["headers"].(map[string]any)["X-Method"])
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// output formats
+const (
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+	formatYAML  = "yaml"
+	formatXML   = "xml"
+)
+
+// outputFormat returns the format of the -format flag, or the extension of the output file.
+func outputFormat(format, output string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(output)) {
+		case ".jsonl", ".ndjson":
+			return formatJSONL, nil
+		case ".csv":
+			return formatCSV, nil
+		case ".tsv":
+			return formatTSV, nil
+		case ".yaml", ".yml":
+			return formatYAML, nil
+		case ".xml":
+			return formatXML, nil
+		default:
+			return formatJSON, nil
+		}
+	}
+	switch format = strings.ToLower(format); format {
+	case formatJSON, formatJSONL, formatCSV, formatTSV, formatYAML, formatXML:
+		return format, nil
+	case "ndjson":
+		return formatJSONL, nil
+	case "yml":
+		return formatYAML, nil
+	default:
+		return "", fmt.Errorf("invalid -format %q", format)
+	}
+}
+
+// output writes the data to the -o file or stdout with the -format.
+func output(data any) error {
+	format, err := outputFormat(*formatFlag, *outputFlag)
+	if err != nil {
+		return err
+	}
+
+	if *outputFlag == "" {
+		return writeOutput(os.Stdout, data, format)
+	}
+
+	if filepath.Ext(*outputFlag) == "" {
+		*outputFlag += "." + format
+	}
+	return outputFile(*outputFlag, data, format)
+}
+
+// outputFile writes the data to the file with the format.
+func outputFile(name string, data any, format string) error {
+	buf := new(bytes.Buffer)
+	if err := writeOutput(buf, data, format); err != nil {
+		return err
+	}
+	return os.WriteFile(name, buf.Bytes(), 0o600)
+}
+
+// writeOutput writes the data with the format. The csv and tsv write the records
+// of the array, the nested objects are flattened to the dotted columns.
+func writeOutput(w io.Writer, data any, format string) error {
+	switch format {
+	case formatJSON:
+		bytes, err := json.MarshalIndent(data, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(bytes))
+		return err
+	case formatJSONL:
+		records, err := toRecords(data)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		for _, record := range records {
+			if err = enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV, formatTSV:
+		records, err := toRecords(data)
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if format == formatTSV {
+			cw.Comma = '\t'
+		}
+		if err = writeTable(cw, records); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	case formatYAML:
+		v, err := normalize(data)
+		if err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err = enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case formatXML:
+		v, err := normalize(data)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		return writeXML(w, "result", v, 0)
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+}
+
+// normalize converts the data to the JSON values, such as the map[string]any,
+// []any, string, int64, float64, bool and nil.
+func normalize(data any) (any, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(bytes)))
+	dec.UseNumber()
+	var v any
+	if err = dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertNumber(v), nil
+}
+
+// convertNumber converts the json.Number to the int64 if possible, otherwise float64.
+func convertNumber(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		for k, e := range t {
+			t[k] = convertNumber(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = convertNumber(e)
+		}
+	}
+	return v
+}
+
+// toRecords returns the elements of the array, or the data as one record.
+func toRecords(data any) ([]any, error) {
+	v, err := normalize(data)
+	if err != nil {
+		return nil, err
+	}
+	if records, ok := v.([]any); ok {
+		return records, nil
+	}
+	return []any{v}, nil
+}
+
+// writeTable writes the header of all columns and the flattened records.
+func writeTable(w *csv.Writer, records []any) error {
+	var (
+		columns []string
+		seen    = make(map[string]bool)
+		rows    = make([]map[string]string, 0, len(records))
+	)
+	for _, record := range records {
+		row := make(map[string]string)
+		flatten(row, "", record)
+		keys := make([]string, 0, len(row))
+		for k := range row {
+			if !seen[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			seen[k] = true
+			columns = append(columns, k)
+		}
+		rows = append(rows, row)
+	}
+
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	line := make([]string, len(columns))
+	for _, row := range rows {
+		for i, c := range columns {
+			line[i] = row[c]
+		}
+		if err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatten flattens the nested objects and arrays to the dotted keys,
+// the value which is not an object is the column "value".
+func flatten(row map[string]string, prefix string, v any) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			flatten(row, key(k), e)
+		}
+	case []any:
+		for i, e := range t {
+			flatten(row, key(strconv.Itoa(i)), e)
+		}
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		row[prefix] = scalarString(t)
+	}
+}
+
+func scalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// writeXML writes the value as the element, the array elements are the <item>.
+func writeXML(w io.Writer, name string, v any, depth int) (err error) {
+	indent := strings.Repeat("\t", depth)
+	name = xmlName(name)
+	switch t := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if _, err = fmt.Fprintf(w, "%s<%s>\n", indent, name); err != nil {
+			return
+		}
+		for _, k := range keys {
+			if err = writeXML(w, k, t[k], depth+1); err != nil {
+				return
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s</%s>\n", indent, name)
+	case []any:
+		if _, err = fmt.Fprintf(w, "%s<%s>\n", indent, name); err != nil {
+			return
+		}
+		for _, e := range t {
+			if err = writeXML(w, "item", e, depth+1); err != nil {
+				return
+			}
+		}
+		_, err = fmt.Fprintf(w, "%s</%s>\n", indent, name)
+	case nil:
+		_, err = fmt.Fprintf(w, "%s<%s/>\n", indent, name)
+	default:
+		if _, err = fmt.Fprintf(w, "%s<%s>", indent, name); err != nil {
+			return
+		}
+		if err = xml.EscapeText(w, []byte(scalarString(t))); err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "</%s>\n", name)
+	}
+	return
+}
+
+// xmlName returns the valid XML element name, the invalid characters are replaced by "_".
+func xmlName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f:
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			if i == 0 && r >= '0' && r <= '9' {
+				sb.WriteByte('_')
+				sb.WriteRune(r)
+				continue
+			}
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
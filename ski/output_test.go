@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the tests")
+
+var outputData = []any{
+	map[string]any{
+		"title": `Go, "the" language`,
+		"price": 1.5,
+		"stock": 10,
+		"tags":  []string{"a", "b"},
+		"meta":  map[string]any{"author": "<Rob & Ken>", "year": nil},
+	},
+	map[string]any{
+		"title": "ski",
+		"1st":   true,
+		"meta":  map[string]any{"author": "shiroyk"},
+	},
+}
+
+func TestWriteOutputGolden(t *testing.T) {
+	t.Parallel()
+	for _, format := range []string{formatCSV, formatTSV, formatXML} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+			buf := new(bytes.Buffer)
+			if !assert.NoError(t, writeOutput(buf, outputData, format)) {
+				return
+			}
+			golden := filepath.Join("testdata", "output."+format)
+			if *updateGolden {
+				assert.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o600))
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(want), buf.String())
+			}
+		})
+	}
+}
+
+// limitWriter fails after writing n bytes.
+type limitWriter struct{ n int }
+
+var errWriteLimit = errors.New("write limit")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWriteLimit
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteOutputError(t *testing.T) {
+	t.Parallel()
+	for _, format := range []string{formatJSON, formatJSONL, formatCSV, formatTSV, formatYAML, formatXML} {
+		for _, n := range []int{0, 60, 120} {
+			err := writeOutput(&limitWriter{n}, outputData, format)
+			assert.ErrorContains(t, err, errWriteLimit.Error(), "%s %d", format, n)
+		}
+	}
+}
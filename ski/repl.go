@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shiroyk/ski"
+	"gopkg.in/yaml.v3"
+)
+
+const replHelp = `Evaluate the executor snippet or JS expression with the input:
+  $gq: .title -> text           the executor snippet, any YAML of the model
+  {$gq: .price, $kind: float}   the pipeline of executors
+  content.length                the JS expression, content is the input
+  title: $gq: .title -> text    evaluate and add the field to the model
+Commands:
+  .load <input>     load the input from the file, - for stdin, or URL
+  .input            print the input
+  .model            print the model of the fields
+  .save <file>      save the model to the file
+  .del <name>       delete the field of the model
+  .clear            delete all fields of the model
+  .history          print the history
+  !<n>              evaluate the line <n> of the history
+  .help             print this help
+  .exit             exit the REPL`
+
+var replHistoryFlag = flag.String("repl-history", "", "repl history file (default ~/.ski_history), - disables the history")
+
+// replField matches the line adds the field to the model, such as "title: $gq: .title".
+var replField = regexp.MustCompile(`^([A-Za-z_][\w-]*):\s+(.+)$`)
+
+// repl the interactive model development.
+type repl struct {
+	fetch   ski.Fetch
+	input   any
+	fields  []string
+	model   map[string]*yaml.Node
+	history []string
+	out     io.Writer
+}
+
+// runRepl runs the REPL, the input is loaded from the -i once.
+func runRepl(fetch ski.Fetch) error {
+	r := &repl{
+		fetch: fetch,
+		model: make(map[string]*yaml.Node),
+		out:   os.Stdout,
+	}
+	_, _ = fmt.Fprintln(r.out, `ski repl, type ".help" for more information`)
+	if *inputFlag != "" {
+		if err := r.load(*inputFlag); err != nil {
+			return err
+		}
+	}
+	return r.run(os.Stdin, replHistoryFile(*replHistoryFlag))
+}
+
+// run reads and evaluates the lines of in until exit or EOF,
+// the lines are appended to the history file if not empty.
+func (r *repl) run(in io.Reader, historyFile string) error {
+	if data, err := os.ReadFile(historyFile); err == nil && len(bytes.TrimSpace(data)) > 0 {
+		r.history = strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	var history *os.File
+	if historyFile != "" {
+		if f, err := os.OpenFile(historyFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600); err == nil {
+			history = f
+			defer history.Close()
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for {
+		_, _ = fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			_, _ = fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			n, err := strconv.Atoi(line[1:])
+			if err != nil || n < 1 || n > len(r.history) {
+				_, _ = fmt.Fprintf(r.out, "no history %s\n", line)
+				continue
+			}
+			line = r.history[n-1]
+			_, _ = fmt.Fprintln(r.out, line)
+		}
+		r.history = append(r.history, line)
+		if history != nil {
+			_, _ = fmt.Fprintln(history, line)
+		}
+
+		exit, err := r.eval(line)
+		if err != nil {
+			_, _ = fmt.Fprintf(r.out, "error: %s\n", err)
+		}
+		if exit {
+			return nil
+		}
+	}
+}
+
+// replHistoryFile returns the history file of the -repl-history, "-" disables the history,
+// the default is the .ski_history of the user home directory.
+func replHistoryFile(name string) string {
+	if name == "-" {
+		return ""
+	}
+	if name != "" {
+		return name
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ski_history")
+}
+
+// eval evaluates the line, returns true if exit.
+func (r *repl) eval(line string) (bool, error) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case ".exit", ".quit":
+		return true, nil
+	case ".help":
+		_, _ = fmt.Fprintln(r.out, replHelp)
+	case ".load":
+		return false, r.load(arg)
+	case ".input":
+		return false, writeOutput(r.out, r.input, formatJSON)
+	case ".model":
+		_, err := r.out.Write(r.dump())
+		return false, err
+	case ".save":
+		if arg == "" {
+			return false, errors.New(".save requires the file")
+		}
+		if err := os.WriteFile(arg, r.dump(), 0o600); err != nil {
+			return false, err
+		}
+		_, _ = fmt.Fprintf(r.out, "saved %d fields to %s\n", len(r.fields), arg)
+	case ".del":
+		if _, ok := r.model[arg]; !ok {
+			return false, fmt.Errorf("field %q not found", arg)
+		}
+		delete(r.model, arg)
+		r.fields = slices.DeleteFunc(r.fields, func(f string) bool { return f == arg })
+	case ".clear":
+		r.fields, r.model = nil, make(map[string]*yaml.Node)
+	case ".history":
+		for i, h := range r.history {
+			_, _ = fmt.Fprintf(r.out, "%5d  %s\n", i+1, h)
+		}
+	default:
+		if strings.HasPrefix(cmd, ".") {
+			return false, fmt.Errorf("unknown command %s", cmd)
+		}
+		var name string
+		if m := replField.FindStringSubmatch(line); m != nil {
+			name, line = m[1], m[2]
+		}
+		node, err := replSnippet(line)
+		if err != nil {
+			return false, err
+		}
+		ret, err := r.exec(node)
+		if err != nil {
+			return false, err
+		}
+		if err = writeOutput(r.out, ret, formatJSON); err != nil {
+			return false, err
+		}
+		if name != "" {
+			if _, ok := r.model[name]; !ok {
+				r.fields = append(r.fields, name)
+			}
+			r.model[name] = node
+		}
+	}
+	return false, nil
+}
+
+// load loads the input with the -input-format.
+func (r *repl) load(name string) error {
+	if name == "" {
+		return errors.New(".load requires the input")
+	}
+	ctx, cancel := modelContext()
+	defer cancel()
+	data, err := readInput(ctx, r.fetch, name)
+	if err != nil {
+		return err
+	}
+	if r.input, err = parseInput(data, *inputFmt); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(r.out, "loaded %s (%d bytes)\n", name, len(data))
+	return nil
+}
+
+// exec compiles the model node and executes it with the input.
+func (r *repl) exec(node *yaml.Node) (any, error) {
+	source, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	executor, err := ski.Compile(string(source))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := modelContext()
+	defer cancel()
+	return executor.Exec(ctx, r.input)
+}
+
+// dump returns the model YAML of the fields, the fields are the $map arguments.
+func (r *repl) dump() []byte {
+	fields := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range r.fields {
+		fields.Content = append(fields.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: name}, blockStyle(r.model[name]))
+	}
+	if len(fields.Content) == 0 {
+		return []byte("# the model has no fields\n")
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "$map"}, fields,
+	}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	_ = enc.Encode(root)
+	return buf.Bytes()
+}
+
+// blockStyle returns the copy of node in the block style.
+func blockStyle(node *yaml.Node) *yaml.Node {
+	n := *node
+	n.Style &^= yaml.FlowStyle
+	n.Content = make([]*yaml.Node, len(node.Content))
+	for i, c := range node.Content {
+		n.Content[i] = blockStyle(c)
+	}
+	return &n
+}
+
+// replSnippet returns the model node of the snippet, the snippet starts with $ or {
+// is the YAML of the model, otherwise the JS expression with the content of input.
+func replSnippet(snippet string) (*yaml.Node, error) {
+	if strings.HasPrefix(snippet, "$") || strings.HasPrefix(snippet, "{") {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(snippet), &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			return nil, errors.New("the snippet must be the executor mapping")
+		}
+		return doc.Content[0], nil
+	}
+	return &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "$js"},
+		{Kind: yaml.ScalarNode, Value: `export default (ctx) => { const content = ctx.get("content"); return (` + snippet + `); }`},
+	}}, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func newTestRepl(input any) (*repl, *bytes.Buffer) {
+	out := new(bytes.Buffer)
+	return &repl{input: input, model: make(map[string]*yaml.Node), out: out}, out
+}
+
+func TestReplRun(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.yaml")
+	r, out := newTestRepl(`<title>Hello</title><p class="a">1</p>`)
+
+	in := strings.NewReader(strings.Join([]string{
+		"$gq: title -> text",
+		"",
+		"title: $gq: title -> text",
+		"len: content.length",
+		".del len",
+		".unknown",
+		"!9",
+		"!1",
+		".save " + model,
+		".exit",
+		"ignored",
+	}, "\n"))
+	require.NoError(t, r.run(in, ""))
+
+	assert.Equal(t, `> "Hello"
+> > "Hello"
+> 38
+> > error: unknown command .unknown
+> no history !9
+> $gq: title -> text
+"Hello"
+> saved 1 fields to `+model+`
+> `, out.String())
+	assert.Equal(t, []string{"title"}, r.fields)
+	assert.Len(t, r.history, 8)
+
+	data, err := os.ReadFile(model)
+	require.NoError(t, err)
+	assert.Equal(t, "$map:\n  title:\n    $gq: title -> text\n", string(data))
+}
+
+func TestReplRunEOF(t *testing.T) {
+	t.Parallel()
+	r, out := newTestRepl(nil)
+	require.NoError(t, r.run(strings.NewReader("content"), ""))
+	assert.Equal(t, "> null\n> \n", out.String())
+}
+
+func TestReplHistory(t *testing.T) {
+	t.Parallel()
+	history := filepath.Join(t.TempDir(), "history")
+	require.NoError(t, os.WriteFile(history, []byte("1 + 1\n"), 0o600))
+
+	r, out := newTestRepl(nil)
+	require.NoError(t, r.run(strings.NewReader("!1\n.history\n"), history))
+	assert.Equal(t, "> 1 + 1\n2\n>     1  1 + 1\n    2  1 + 1\n    3  .history\n> \n", out.String())
+
+	data, err := os.ReadFile(history)
+	require.NoError(t, err)
+	assert.Equal(t, "1 + 1\n1 + 1\n.history\n", string(data))
+
+	assert.Equal(t, "", replHistoryFile("-"))
+	assert.Equal(t, history, replHistoryFile(history))
+	if home, err := os.UserHomeDir(); err == nil {
+		assert.Equal(t, filepath.Join(home, ".ski_history"), replHistoryFile(""))
+	}
+}
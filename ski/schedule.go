@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+	"gopkg.in/yaml.v3"
+)
+
+// scheduleConfig the jobs config file of the schedule:
+//
+//	jobs:
+//	  - name: hn
+//	    model: hn.yaml
+//	    input: https://news.ycombinator.com/best
+//	    cron: "*/30 * * * *"
+//	    jitter: 1m
+//	    output: hn.json
+//	  - name: stats
+//	    sinks: [ "webhook:https://example.com/hook" ]
+//	    script: stats.js
+//	    every: 10m
+type scheduleConfig struct {
+	Jobs []*scheduleJob `yaml:"jobs"`
+}
+
+// scheduleJob the job runs the model or script with the input.
+type scheduleJob struct {
+	Name string `yaml:"name"`
+	// Model or Script the file path, relative to the config file.
+	Model  string `yaml:"model"`
+	Script string `yaml:"script"`
+	// Input the file path or URL, InputFormat is text or json.
+	Input       string `yaml:"input"`
+	InputFormat string `yaml:"input-format"`
+	// Cron the five fields cron expression "minute hour day-of-month month day-of-week",
+	// or one of @hourly, @daily, @weekly, @monthly, @yearly.
+	Cron string `yaml:"cron"`
+	// Every the interval between the runs.
+	Every time.Duration `yaml:"every"`
+	// Jitter the random delay before each run.
+	Jitter time.Duration `yaml:"jitter"`
+	// Timeout the timeout of each run, default is the -t.
+	Timeout time.Duration `yaml:"timeout"`
+	// Output the output file, the format is chosen from the extension, default is stdout.
+	Output string `yaml:"output"`
+	// Format the output format.
+	Format string `yaml:"format"`
+	// Sinks the result sinks same as the -sink, the stdout is skipped if set.
+	Sinks []string `yaml:"sinks"`
+
+	executor ski.Executor
+	sink     ski.Sink
+	cron     *cronSchedule
+	running  atomic.Bool
+}
+
+// runSchedule runs the jobs of the config file argument until interrupted.
+// The run is skipped if the previous run of the job is still running.
+func runSchedule(fetch ski.Fetch) (err error) {
+	if len(args) == 0 {
+		return errors.New("schedule requires the jobs config file")
+	}
+	jobs, err := loadSchedule(args[0], fetch)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		for _, job := range jobs {
+			if job.sink != nil {
+				err = errors.Join(err, job.sink.Close())
+			}
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := newLogger("schedule")
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *scheduleJob) {
+			defer wg.Done()
+			job.loop(ctx, fetch, logger)
+		}(job)
+	}
+	logger.Info(fmt.Sprintf("schedule %d jobs", len(jobs)))
+	wg.Wait()
+	return
+}
+
+// loadSchedule loads the jobs of the config file and compiles the models and scripts.
+func loadSchedule(name string, fetch ski.Fetch) ([]*scheduleJob, error) {
+	data, err := os.ReadFile(name) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	var config scheduleConfig
+	if err = yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if len(config.Jobs) == 0 {
+		return nil, fmt.Errorf("no jobs in %s", name)
+	}
+
+	dir := filepath.Dir(name)
+	path := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || isURL(p) || p == "-" {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i, job := range config.Jobs {
+		if job.Name == "" {
+			job.Name = "job" + strconv.Itoa(i+1)
+		}
+		if err = job.init(path, fetch); err != nil {
+			for _, job := range config.Jobs[:i] {
+				if job.sink != nil {
+					_ = job.sink.Close()
+				}
+			}
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+	return config.Jobs, nil
+}
+
+func (job *scheduleJob) init(path func(string) string, fetch ski.Fetch) (err error) {
+	switch {
+	case job.Cron != "" && job.Every > 0:
+		return errors.New("only one of cron and every can be set")
+	case job.Cron != "":
+		if job.cron, err = parseCron(job.Cron); err != nil {
+			return err
+		}
+	case job.Every <= 0:
+		return errors.New("cron or every is required")
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = *timeoutFlag
+	}
+	if job.Format, err = outputFormat(job.Format, job.Output); err != nil {
+		return err
+	}
+	job.Input, job.Output = path(job.Input), path(job.Output)
+	for i, spec := range job.Sinks {
+		// the sink target is relative to the config file
+		if kind, target, ok := strings.Cut(spec, ":"); ok && !isURL(spec) && kind != "webhook" {
+			job.Sinks[i] = kind + ":" + path(target)
+		}
+	}
+
+	switch {
+	case job.Model != "" && job.Script != "":
+		return errors.New("only one of model and script can be set")
+	case job.Model != "":
+		job.executor, err = compileModel(path(job.Model))
+	case job.Script != "":
+		source, err := os.ReadFile(path(job.Script))
+		if err != nil {
+			return err
+		}
+		module, err := js.GetScheduler().Loader().CompileModule(job.Script, string(source))
+		if err != nil {
+			return err
+		}
+		job.executor = js.Executor{CyclicModuleRecord: module}
+	default:
+		return errors.New("model or script is required")
+	}
+	if err != nil {
+		return
+	}
+	job.sink, err = openSinks(context.Background(), fetch, job.Sinks)
+	return
+}
+
+// loop runs the job at the scheduled time until the context is done.
+func (job *scheduleJob) loop(ctx context.Context, fetch ski.Fetch, logger *slog.Logger) {
+	logger = logger.With("job", job.Name)
+	// waits for the running run when the context is done
+	var running sync.WaitGroup
+	defer running.Wait()
+	for {
+		now := time.Now()
+		next := now.Add(job.Every)
+		if job.cron != nil {
+			var err error
+			if next, err = job.cron.next(now); err != nil {
+				logger.Error("schedule stopped", "error", err)
+				return
+			}
+		}
+		if job.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(job.Jitter)))) //nolint:gosec
+		}
+		logger.Debug("schedule", "next", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !job.running.CompareAndSwap(false, true) {
+			logger.Warn("schedule skipped, the previous run is still running")
+			continue
+		}
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer job.running.Store(false)
+			start := time.Now()
+			if err := job.run(ctx, fetch, logger); err != nil {
+				logger.Error("schedule failed", "duration", time.Since(start), "error", err)
+				return
+			}
+			logger.Info("schedule succeeded", "duration", time.Since(start))
+		}()
+	}
+}
+
+// run executes the job once and writes the output.
+func (job *scheduleJob) run(ctx context.Context, fetch ski.Fetch, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+	if *charsetFlag != "" {
+		ctx = ski.WithCharset(ctx, *charsetFlag)
+	}
+	ctx = ski.NewContext(ski.WithLogger(ctx, logger), nil)
+
+	var input any
+	if job.Input != "" {
+		data, err := readInput(ctx, fetch, job.Input)
+		if err != nil {
+			return err
+		}
+		if input, err = parseInput(data, job.InputFormat); err != nil {
+			return err
+		}
+	}
+	ret, err := job.executor.Exec(ctx, input)
+	if err != nil {
+		return err
+	}
+	if job.sink != nil {
+		if err = job.sink.Write(ctx, ret); err != nil || job.Output == "" {
+			return err
+		}
+	}
+	if job.Output == "" {
+		return writeOutput(os.Stdout, ret, job.Format)
+	}
+	return outputFile(job.Output, ret, job.Format)
+}
+
+// cronSchedule the parsed cron expression, each field is the bitset of the allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// the day matches the day-of-month or day-of-week if both are restricted,
+	// the field starts with * such as */2 is unrestricted like the Vixie cron
+	domStar, dowStar bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses the five fields cron expression, the field is *, the number,
+// the range a-b, the step */n or a-b/n, and the comma separated list of them.
+// The subset of the standard cron is small enough to parse here, the job only needs
+// the next time of the schedule, not the scheduler of a cron library.
+func parseCron(spec string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron %q, requires 5 fields", spec)
+	}
+	var (
+		s   cronSchedule
+		err error
+	)
+	bounds := []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.set, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("invalid cron %q: %w", spec, err)
+		}
+	}
+	// the 7 is also Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar, s.dowStar = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	if _, err = s.next(time.Now()); err != nil {
+		return nil, fmt.Errorf("invalid cron %q: %w", spec, err)
+	}
+	return &s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+		lo, hi := min, max
+		if expr != "*" {
+			from, to, isRange := strings.Cut(expr, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// errCronNeverMatch the cron expression never matches, such as February 30.
+var errCronNeverMatch = errors.New("the schedule never matches")
+
+// next returns the next time after t matches the schedule,
+// returns errCronNeverMatch if no time matches in nine years.
+func (s *cronSchedule) next(t time.Time) (time.Time, error) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// the February 29 matches at least once in eight years, such as 2096 and 2104
+	limit := t.AddDate(9, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, errCronNeverMatch
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
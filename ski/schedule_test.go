@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	t.Parallel()
+	for _, spec := range []string{
+		"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *",
+		"1-a * * * *", "@every", "0 0 30 2 *", "0 0 31 4,6,9,11 *",
+	} {
+		_, err := parseCron(spec)
+		assert.Error(t, err, spec)
+	}
+	_, err := parseCron("0 0 30 2 *")
+	assert.ErrorIs(t, err, errCronNeverMatch)
+
+	s, err := parseCron("0-10/5,30 */6 1 1-3 *")
+	if assert.NoError(t, err) {
+		assert.Equal(t, uint64(1<<0|1<<5|1<<10|1<<30), s.minute)
+		assert.Equal(t, uint64(1<<0|1<<6|1<<12|1<<18), s.hour)
+		assert.Equal(t, uint64(1<<1), s.dom)
+		assert.Equal(t, uint64(1<<1|1<<2|1<<3), s.month)
+		assert.True(t, s.dowStar)
+		assert.False(t, s.domStar)
+	}
+	s, err = parseCron("0 0 */2 * 1")
+	if assert.NoError(t, err) {
+		assert.True(t, s.domStar, "*/2 is unrestricted")
+		assert.False(t, s.dowStar)
+	}
+	s, err = parseCron("0 0 * * 7")
+	if assert.NoError(t, err) {
+		assert.NotZero(t, s.dow&1, "7 is Sunday")
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	t.Parallel()
+	// 2024-01-15 is Monday
+	from := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+	for _, c := range []struct{ spec, want string }{
+		{"* * * * *", "2024-01-15 10:31"},
+		{"30 10 * * *", "2024-01-16 10:30"},
+		{"*/20 * * * *", "2024-01-15 10:40"},
+		{"10-50/20 * * * *", "2024-01-15 10:50"},
+		{"5,7 9-11 * * *", "2024-01-15 11:05"},
+		{"0 0 1 */3 *", "2024-04-01 00:00"},
+		{"@hourly", "2024-01-15 11:00"},
+		{"@daily", "2024-01-16 00:00"},
+		{"@weekly", "2024-01-21 00:00"},
+		{"@monthly", "2024-02-01 00:00"},
+		{"@yearly", "2025-01-01 00:00"},
+		{"0 0 * * 7", "2024-01-21 00:00"},
+		{"0 0 * * 1-5", "2024-01-16 00:00"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		// the day-of-month or the day-of-week if both are restricted
+		{"0 0 20 * 3", "2024-01-17 00:00"},
+		{"0 0 16 * 5", "2024-01-16 00:00"},
+		// the day-of-month and the day-of-week if one of them is *
+		{"0 0 * 2 3", "2024-02-07 00:00"},
+		// the field starts with * is unrestricted
+		{"0 0 */2 * 1", "2024-01-29 00:00"},
+		{"0 0 1 * */2", "2024-02-01 00:00"},
+	} {
+		s, err := parseCron(c.spec)
+		if !assert.NoError(t, err, c.spec) {
+			continue
+		}
+		next, err := s.next(from)
+		if assert.NoError(t, err, c.spec) {
+			assert.Equal(t, c.want, next.Format("2006-01-02 15:04"), c.spec)
+		}
+	}
+
+	s := &cronSchedule{minute: 1, hour: 1, dom: 1 << 30, month: 1 << 2, dow: 1<<7 - 1, dowStar: true}
+	_, err := s.next(from)
+	assert.ErrorIs(t, err, errCronNeverMatch)
+
+	// the leap day after the century year
+	s, _ = parseCron("0 0 29 2 *")
+	next, err := s.next(time.Date(2096, 3, 1, 0, 0, 0, 0, time.UTC))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2104-02-29", next.Format(time.DateOnly))
+	}
+}
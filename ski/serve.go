@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+)
+
+var (
+	addrFlag       = flag.String("addr", ":8080", "serve listen address")
+	modelsDirFlag  = flag.String("models", "", "serve the models (*.yaml, *.yml) of the directory")
+	scriptsDirFlag = flag.String("scripts", "", "serve the scripts (*.js, *.mjs) of the directory")
+	serveURLFlag   = flag.Bool("serve-url", false, "serve allows the input of the query url, the loopback, private and link-local addresses are refused")
+)
+
+// errURLDisabled the query url is not allowed without the -serve-url.
+var errURLDisabled = errors.New("the url input is disabled, enable it by -serve-url")
+
+// maxServeBody the max size of the request body.
+const maxServeBody = 10 << 20
+
+// server serves the models and scripts as the HTTP API.
+type server struct {
+	// fetch fetches the input of the query url, nil if the url input is disabled.
+	fetch   ski.Fetch
+	models  map[string]ski.Executor
+	scripts map[string]ski.Executor
+	timeout time.Duration
+	logger  *slog.Logger
+}
+
+// runServe runs the HTTP server until interrupted:
+//
+//	POST /models/{name}    executes the model with the body, or the input of the query url
+//	POST /scripts/{name}   executes the script with the body, or the input of the query url
+//	GET  /admin/health     reports the health and the js.Scheduler stats
+//
+// The query url is only allowed with the -serve-url, the url is fetched by the publicFetch.
+func runServe(ski.Fetch) error {
+	s := &server{
+		models:  make(map[string]ski.Executor),
+		scripts: make(map[string]ski.Executor),
+		timeout: *timeoutFlag,
+		logger:  newLogger("serve"),
+	}
+	if err := s.load(*modelsDirFlag, *scriptsDirFlag); err != nil {
+		return err
+	}
+	if len(s.models) == 0 && len(s.scripts) == 0 {
+		return errors.New("serve requires -models or -scripts directory")
+	}
+	if *serveURLFlag {
+		s.fetch = publicFetch()
+	}
+
+	srv := &http.Server{
+		Addr:              *addrFlag,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+	s.logger.Info(fmt.Sprintf("serve %d models and %d scripts on %s", len(s.models), len(s.scripts), *addrFlag))
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// handler returns the handler of the API.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/models/", s.handle("/models/", s.models))
+	mux.HandleFunc("/scripts/", s.handle("/scripts/", s.scripts))
+	mux.HandleFunc("/admin/health", s.health)
+	return mux
+}
+
+// load compiles the models and scripts of the directories.
+func (s *server) load(modelsDir, scriptsDir string) error {
+	if modelsDir != "" {
+		err := loadDir(modelsDir, []string{".yaml", ".yml"}, func(name string, source []byte) (err error) {
+			s.models[name], err = ski.Compile(string(source))
+			return
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if scriptsDir != "" {
+		loader := js.GetScheduler().Loader()
+		return loadDir(scriptsDir, []string{".js", ".mjs"}, func(name string, source []byte) error {
+			module, err := loader.CompileModule(name, string(source))
+			if err != nil {
+				return err
+			}
+			s.scripts[name] = js.Executor{CyclicModuleRecord: module}
+			return nil
+		})
+	}
+	return nil
+}
+
+// loadDir calls the fn with the name without extension and the content of the files.
+func loadDir(dir string, exts []string, fn func(name string, source []byte) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains(exts, ext) {
+			continue
+		}
+		source, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err = fn(strings.TrimSuffix(entry.Name(), ext), source); err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// handle returns the handler executes the executor of the name with the input.
+// The input is the request body, which is parsed as JSON if the Content-Type is JSON,
+// or fetched from the query url. The query timeout shortens the -t timeout.
+func (s *server) handle(prefix string, executors map[string]ski.Executor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		executor, ok := executors[name]
+		if !ok {
+			s.error(w, r, http.StatusNotFound, fmt.Errorf("%s not found", name))
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			s.error(w, r, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		timeout := s.timeout
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, fmt.Errorf("invalid timeout: %w", err))
+				return
+			}
+			timeout = min(d, timeout)
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if *charsetFlag != "" {
+			ctx = ski.WithCharset(ctx, *charsetFlag)
+		}
+		ctx = ski.NewContext(ski.WithLogger(ctx, s.logger), nil)
+
+		input, err := s.input(ctx, w, r)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.Is(err, errURLDisabled):
+				s.error(w, r, http.StatusForbidden, err)
+			case errors.As(err, &maxBytesErr):
+				s.error(w, r, http.StatusRequestEntityTooLarge, err)
+			default:
+				s.error(w, r, http.StatusBadRequest, err)
+			}
+			return
+		}
+
+		ret, err := executor.Exec(ctx, input)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			s.error(w, r, status, err)
+			return
+		}
+		s.json(w, http.StatusOK, ret)
+		s.logger.Debug("serve", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start))
+	}
+}
+
+// input returns the input of the request.
+func (s *server) input(ctx context.Context, w http.ResponseWriter, r *http.Request) (any, error) {
+	if url := r.URL.Query().Get("url"); url != "" {
+		if s.fetch == nil {
+			return nil, errURLDisabled
+		}
+		if !isURL(url) {
+			return nil, fmt.Errorf("invalid url %q", url)
+		}
+		data, err := readInput(ctx, s.fetch, url)
+		if err != nil {
+			return nil, err
+		}
+		return parseInput(data, *inputFmt)
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxServeBody))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return parseInput(data, "json")
+	}
+	return parseInput(data, *inputFmt)
+}
+
+// health reports the health, the loaded models and scripts, and the js.Scheduler stats.
+func (s *server) health(w http.ResponseWriter, _ *http.Request) {
+	health := map[string]any{
+		"status":  "ok",
+		"models":  len(s.models),
+		"scripts": len(s.scripts),
+	}
+	if m, ok := js.GetScheduler().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			health["scheduler"] = json.RawMessage(text)
+		}
+	}
+	s.json(w, http.StatusOK, health)
+}
+
+func (s *server) error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	s.logger.Error("serve", "method", r.Method, "path", r.URL.Path, "status", status, "error", err)
+	s.json(w, status, map[string]any{"error": err.Error()})
+}
+
+func (s *server) json(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(map[string]any{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// publicFetch returns the Fetch only connects to the public addresses, the address
+// is checked when dialing, so the redirects to the internal addresses are refused also.
+// The proxies are not used, the proxy connects to the address instead.
+func publicFetch() ski.Fetch {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: publicAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// publicAddress refuses to connect to the loopback, private, link-local and unspecified addresses.
+func publicAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("refused to connect to the non-public address %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroyk/ski"
+	"github.com/shiroyk/ski/js"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T) (*server, *httptest.Server) {
+	t.Helper()
+	s := &server{
+		models:  make(map[string]ski.Executor),
+		scripts: make(map[string]ski.Executor),
+		timeout: 5 * time.Second,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	var err error
+	if s.models["title"], err = ski.Compile(`$gq: title`); err != nil {
+		t.Fatal(err)
+	}
+	loader := js.GetScheduler().Loader()
+	for name, source := range map[string]string{
+		"echo":  `export default (ctx) => ({ content: ctx.get('content') })`,
+		"loop":  `export default () => { while (true) {} }`,
+		"throw": `export default () => { throw new Error("failed") }`,
+	} {
+		module, err := loader.CompileModule(name, source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.scripts[name] = js.Executor{CyclicModuleRecord: module}
+	}
+	ts := httptest.NewServer(s.handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// post returns the status and the JSON body of the response.
+func post(t *testing.T, u, contentType string, body io.Reader) (int, map[string]any) {
+	t.Helper()
+	res, err := http.Post(u, contentType, body) //nolint:noctx
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer res.Body.Close()
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	var v map[string]any
+	data, _ := io.ReadAll(res.Body)
+	if err = json.Unmarshal(data, &v); err != nil {
+		// the result is not an object
+		v = map[string]any{"result": string(data)}
+	}
+	return res.StatusCode, v
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+	_, ts := newTestServer(t)
+
+	status, v := post(t, ts.URL+"/models/title", "text/html", strings.NewReader(`<title>Hello</title>`))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"Hello"`, v["result"])
+
+	status, v = post(t, ts.URL+"/scripts/echo", "application/json", strings.NewReader(`{"a":1}`))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"content": map[string]any{"a": float64(1)}}, v)
+
+	status, v = post(t, ts.URL+"/scripts/echo", "text/plain", strings.NewReader(`{"a":1}`))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]any{"content": `{"a":1}`}, v)
+
+	res, err := http.Get(ts.URL + "/admin/health") //nolint:noctx
+	if assert.NoError(t, err) {
+		var health map[string]any
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&health))
+		_ = res.Body.Close()
+		assert.Equal(t, "ok", health["status"])
+		assert.EqualValues(t, 1, health["models"])
+		assert.EqualValues(t, 3, health["scripts"])
+	}
+}
+
+func TestServeError(t *testing.T) {
+	t.Parallel()
+	_, ts := newTestServer(t)
+
+	status, v := post(t, ts.URL+"/models/unknown", "text/plain", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "unknown not found", v["error"])
+
+	res, err := http.Get(ts.URL + "/models/title") //nolint:noctx
+	if assert.NoError(t, err) {
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+		assert.Equal(t, http.MethodPost, res.Header.Get("Allow"))
+	}
+
+	status, _ = post(t, ts.URL+"/scripts/echo", "application/json", strings.NewReader(`{`))
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, v = post(t, ts.URL+"/scripts/throw", "text/plain", nil)
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Contains(t, v["error"], "failed")
+
+	status, _ = post(t, ts.URL+"/scripts/echo", "text/plain", strings.NewReader(strings.Repeat("a", maxServeBody+1)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	status, _ = post(t, ts.URL+"/scripts/echo", "text/plain", strings.NewReader(strings.Repeat("a", maxServeBody)))
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestServeTimeout(t *testing.T) {
+	t.Parallel()
+	_, ts := newTestServer(t)
+
+	status, _ := post(t, ts.URL+"/scripts/echo?timeout=abc", "text/plain", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	start := time.Now()
+	status, _ = post(t, ts.URL+"/scripts/loop?timeout=100ms", "text/plain", nil)
+	assert.Equal(t, http.StatusGatewayTimeout, status)
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
+
+func TestServeURL(t *testing.T) {
+	t.Parallel()
+	s, ts := newTestServer(t)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `<title>Page</title>`)
+	}))
+	t.Cleanup(page.Close)
+	query := "?url=" + url.QueryEscape(page.URL)
+
+	// the url input is disabled by default
+	status, v := post(t, ts.URL+"/models/title"+query, "text/plain", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Contains(t, v["error"], "-serve-url")
+
+	// the loopback address is refused
+	s.fetch = publicFetch()
+	status, v = post(t, ts.URL+"/models/title"+query, "text/plain", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, v["error"], "non-public address 127.0.0.1")
+
+	status, _ = post(t, ts.URL+"/models/title?url=file:///etc/passwd", "text/plain", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestServeURLFetch(t *testing.T) {
+	t.Parallel()
+	s, ts := newTestServer(t)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, `<title>Page</title>`)
+	}))
+	t.Cleanup(page.Close)
+
+	s.fetch = http.DefaultClient
+	status, v := post(t, ts.URL+"/models/title?url="+url.QueryEscape(page.URL), "text/plain", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `"Page"`, v["result"])
+}
+
+func TestPublicAddress(t *testing.T) {
+	t.Parallel()
+	for _, addr := range []string{
+		"127.0.0.1:80", "[::1]:80", "10.0.0.1:80", "172.16.0.1:80", "192.168.1.1:80",
+		"169.254.169.254:80", "[fe80::1]:80", "0.0.0.0:80", "[::]:80", "[::ffff:127.0.0.1]:80",
+		"[fd00::1]:80", "224.0.0.1:80", "localhost:80", "invalid",
+	} {
+		assert.Error(t, publicAddress("tcp", addr, nil), addr)
+	}
+	for _, addr := range []string{"93.184.216.34:443", "[2606:2800:220:1:248:1893:25c8:1946]:443"} {
+		assert.NoError(t, publicAddress("tcp", addr, nil), addr)
+	}
+}
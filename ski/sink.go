@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/shiroyk/ski"
+)
+
+var sinkFlag stringsFlag
+
+func init() {
+	flag.Var(&sinkFlag, "sink", "write the results to the sink, can be repeated: "+
+		"file:out.jsonl?max-size=10MB&max-backups=5 appends JSON Lines and rotates, "+
+		"dir:items/?key=id writes each item to the JSON file, "+
+		"webhook:https://example.com/hook posts the result as JSON, "+
+		"sqlite:out.db?table=results inserts each item as the JSON row (requires the build tag sqlite)")
+}
+
+// openSinks opens the sinks of the specs, the nil Sink is returned if no specs.
+func openSinks(ctx context.Context, fetch ski.Fetch, specs []string) (ski.Sink, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	sinks := make([]ski.Sink, 0, len(specs))
+	for _, spec := range specs {
+		sink, err := openSink(ctx, fetch, spec)
+		if err != nil {
+			_ = ski.MultiSink(sinks...).Close()
+			return nil, fmt.Errorf("invalid sink %q: %w", spec, err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return ski.MultiSink(sinks...), nil
+}
+
+// emit writes the result to the sink, then to the -o file or stdout by the write,
+// the stdout is skipped if the sink is given.
+func emit(sink ski.Sink, ret any, write func() error) error {
+	if sink == nil {
+		return write()
+	}
+	ctx, cancel := modelContext()
+	defer cancel()
+	if err := sink.Write(ctx, ret); err != nil {
+		return err
+	}
+	if *outputFlag == "" {
+		return nil
+	}
+	return write()
+}
+
+// openSink opens the sink of the spec "kind:target?options".
+func openSink(ctx context.Context, fetch ski.Fetch, spec string) (ski.Sink, error) {
+	if isURL(spec) {
+		return ski.NewWebhookSink(fetch, spec, ski.WebhookOptions{}), nil
+	}
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, errors.New("requires kind:target")
+	}
+	if kind == "webhook" {
+		if !isURL(target) {
+			return nil, fmt.Errorf("invalid webhook url %q", target)
+		}
+		return ski.NewWebhookSink(fetch, target, ski.WebhookOptions{}), nil
+	}
+
+	target, rawQuery, _ := strings.Cut(target, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "file":
+		var opt ski.FileSinkOptions
+		if size := query.Get("max-size"); size != "" {
+			if opt.MaxSize, err = parseSize(size); err != nil {
+				return nil, err
+			}
+		}
+		if backups := query.Get("max-backups"); backups != "" {
+			if opt.MaxBackups, err = strconv.Atoi(backups); err != nil {
+				return nil, fmt.Errorf("invalid max-backups %q", backups)
+			}
+		}
+		return ski.NewFileSink(target, opt)
+	case "dir":
+		return ski.NewDirSink(target, ski.DirSinkOptions{Key: query.Get("key")})
+	case "sqlite":
+		if !slices.Contains(sql.Drivers(), "sqlite") {
+			return nil, errors.New("no sqlite driver is registered, build with -tags sqlite")
+		}
+		table := query.Get("table")
+		if table == "" {
+			table = "results"
+		}
+		db, err := sql.Open("sqlite", target)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := ski.NewSQLSink(ctx, db, table)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		return dbSink{sink, db}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink %q", kind)
+	}
+}
+
+// dbSink closes the database opened by the sink.
+type dbSink struct {
+	ski.Sink
+	db *sql.DB
+}
+
+func (s dbSink) Close() error { return errors.Join(s.Sink.Close(), s.db.Close()) }
+
+// parseSize parses the size such as 1024, 512KB, 10MB, 1GB.
+func parseSize(s string) (int64, error) {
+	num, unit := strings.ToUpper(s), int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(num, u.suffix) {
+			num, unit = strings.TrimSuffix(num, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteSink(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "out.db")
+
+	if !slices.Contains(sql.Drivers(), "sqlite") {
+		_, err := openSink(ctx, nil, "sqlite:"+name)
+		assert.ErrorContains(t, err, "-tags sqlite")
+		return
+	}
+
+	_, err := openSink(ctx, nil, "sqlite:"+name+"?table=1items")
+	assert.ErrorContains(t, err, "invalid table name")
+
+	sink, err := openSink(ctx, nil, "sqlite:"+name+"?table=items")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, sink.Write(ctx, []any{map[string]any{"a": 1}, "b"}))
+	assert.NoError(t, sink.Close())
+
+	db, err := sql.Open("sqlite", name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+	var count int
+	assert.NoError(t, db.QueryRowContext(ctx, `SELECT count(*) FROM "items"`).Scan(&count))
+	assert.Equal(t, 2, count)
+}
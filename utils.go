@@ -9,6 +9,13 @@ import (
 
 var loggerKey byte
 
+// LogSubsystemKey the attribute key of the subsystem of the library logs,
+// such as slog.String(LogSubsystemKey, "fetch") of the fetch logs.
+const LogSubsystemKey = "subsystem"
+
+// fetchSubsystem the subsystem attribute of the fetch logs.
+var fetchSubsystem = slog.String(LogSubsystemKey, "fetch")
+
 // Logger get slog.Logger from the context
 func Logger(ctx context.Context) *slog.Logger {
 	if logger := ctx.Value(&loggerKey); logger != nil {